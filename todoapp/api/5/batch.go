@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"todoapp/5/storage"
+)
+
+// batchRequest is the body of POST /todos/batch
+type batchRequest struct {
+	Operations []storage.BatchOp `json:"operations"`
+}
+
+// batchTodosHandler applies every operation of the request or none of them.
+// On success the response lists one result per operation, in request order.
+func batchTodosHandler(w http.ResponseWriter, r *http.Request) {
+	var request batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid request payload"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("batch"))
+	defer cancel()
+
+	results, err := todoList.ApplyBatch(ctx, request.Operations)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
+	})
+}
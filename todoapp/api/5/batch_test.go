@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchTodos(t *testing.T) {
+	server := newTestServer(t)
+	addTodo(t, server, `{"description": "Parent"}`)
+	addTodo(t, server, `{"description": "Child", "parent_id": 1}`)
+	addTodo(t, server, `{"description": "Rename me"}`)
+
+	resp := do(t, http.MethodPost, server.URL+"/todos/batch", `{"operations": [
+		{"op": "create", "description": "Created"},
+		{"op": "update", "id": 3, "description": "Renamed"},
+		{"op": "delete", "id": 1}
+	]}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var body struct {
+		Results []*storage.BatchResult `json:"results"`
+	}
+	decode(t, resp, &body)
+	require.Len(t, body.Results, 3)
+	assert.Equal(t, "Created", body.Results[0].Todo.Description)
+	assert.Equal(t, "Renamed", body.Results[1].Todo.Description)
+	assert.Equal(t, 1, body.Results[2].ID)
+
+	// The delete cascaded to the subtask, as DELETE /todos/{id} does
+	resp = do(t, http.MethodGet, server.URL+"/todos/2", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestBatchTodos_AllOrNothing(t *testing.T) {
+	server := newTestServer(t)
+	addTodo(t, server, `{"description": "Existing"}`)
+
+	resp := do(t, http.MethodPost, server.URL+"/todos/batch", `{"operations": [
+		{"op": "create", "description": "Never written"},
+		{"op": "update", "id": 42, "description": "Missing"}
+	]}`)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	var problem ProblemDetails
+	decode(t, resp, &problem)
+	assert.Equal(t, storage.ErrTodoNotFound, problem.Code)
+	assert.Contains(t, problem.Detail, "Batch operation 1 (update) failed")
+
+	resp = do(t, http.MethodGet, server.URL+"/todos", "")
+	var page storage.TodoPage
+	decode(t, resp, &page)
+	assert.Len(t, page.Todos, 1)
+
+	resp = do(t, http.MethodPost, server.URL+"/todos/batch", `{"operations": [{"op": "create"}]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	decode(t, resp, &problem)
+	require.NotEmpty(t, problem.Errors)
+	assert.Equal(t, "operations[0].description", problem.Errors[0].Field)
+}
+
+func TestBatchTodos_MethodNotAllowed(t *testing.T) {
+	server := newTestServer(t)
+
+	// Not taken for GET /todos/{id}
+	resp := do(t, http.MethodGet, server.URL+"/todos/batch", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, http.MethodPost, resp.Header.Get("Allow"))
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+}
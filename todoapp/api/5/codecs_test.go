@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inTempDir runs the test in a temporary directory, where downloads write their files.
+func inTempDir(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// inboxDescriptions lists the descriptions of the todos in the inbox.
+func inboxDescriptions(t *testing.T, server *httptest.Server) []string {
+	t.Helper()
+	resp := do(t, http.MethodGet, server.URL+"/todos", "")
+	var page storage.TodoPage
+	decode(t, resp, &page)
+	descriptions := []string{}
+	for _, todo := range page.Todos {
+		descriptions = append(descriptions, todo.Description)
+	}
+	return descriptions
+}
+
+func TestDownloadTodos_Negotiation(t *testing.T) {
+	inTempDir(t)
+	server := newTestServer(t)
+	addTodo(t, server, `{"description": "Exported"}`)
+
+	tests := []struct {
+		accept, mediaType, contains string
+	}{
+		{"", "application/json", `"Exported"`},
+		{"text/csv", "text/csv", "description"},
+		{"text/html;q=0.9, text/markdown", "text/markdown", "- [ ] Exported"},
+		{"text/*;q=0.5, text/calendar", "text/calendar", "SUMMARY:Exported"},
+		{"text/plain", "text/plain", "Exported"},
+	}
+	for _, tt := range tests {
+		resp := do(t, http.MethodGet, server.URL+"/todos/download", "", "Accept", tt.accept)
+		require.Equal(t, http.StatusOK, resp.StatusCode, tt.accept)
+		assert.Equal(t, tt.mediaType, resp.Header.Get("Content-Type"), tt.accept)
+		assert.Equal(t, "Accept", resp.Header.Get("Vary"), tt.accept)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), tt.contains, tt.accept)
+	}
+
+	resp := do(t, http.MethodGet, server.URL+"/todos/download", "", "Accept", "image/png")
+	assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+	var problem ProblemDetails
+	decode(t, resp, &problem)
+	assert.Equal(t, storage.ErrNotAcceptable, problem.Code)
+}
+
+func TestUploadTodos_ContentType(t *testing.T) {
+	server := newTestServer(t)
+
+	resp := do(t, http.MethodPost, server.URL+"/todos/upload", "description\nFrom CSV\n",
+		"Content-Type", "text/csv; charset=utf-8")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = do(t, http.MethodPost, server.URL+"/todos/upload?columns=Task:description", "Task\nMapped\n",
+		"Content-Type", "text/csv")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// A multipart file is decoded by its part's type, or else by its name
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "todos.md")
+	require.NoError(t, err)
+	io.WriteString(part, "- [ ] From Markdown\n")
+	require.NoError(t, form.Close())
+	resp = do(t, http.MethodPost, server.URL+"/todos/upload", body.String(), "Content-Type", form.FormDataContentType())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.ElementsMatch(t, []string{"From CSV", "Mapped", "From Markdown"}, inboxDescriptions(t, server))
+
+	// Skipped todos are reported, and an upload that creates none is rejected
+	resp = do(t, http.MethodPost, server.URL+"/todos/upload", "description,list_id\nPartial,\n,1\n", "Content-Type", "text/csv")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result storage.ImportResult
+	decode(t, resp, &result)
+	assert.Equal(t, 1, result.Created)
+	assert.Equal(t, 1, result.Skipped)
+	resp = do(t, http.MethodPost, server.URL+"/todos/upload", "description,list_id\n,1\n", "Content-Type", "text/csv")
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	var problem ProblemDetails
+	decode(t, resp, &problem)
+	assert.Equal(t, storage.ErrImportRejected, problem.Code)
+	require.NotEmpty(t, problem.Errors)
+	assert.Equal(t, "todos[0].description", problem.Errors[0].Field)
+
+	resp = do(t, http.MethodPost, server.URL+"/todos/upload", "Task\nNo description column\n", "Content-Type", "text/tab-separated-values")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = do(t, http.MethodPost, server.URL+"/todos/upload?columns=Task:owner", "Task\nUnknown\n", "Content-Type", "text/csv")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
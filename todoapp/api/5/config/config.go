@@ -0,0 +1,445 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"todoapp/5/storage"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Supported storage backends
+const (
+	BackendMemory = "memory"
+	BackendSQLite = "sqlite"
+)
+
+// Config holds the server settings. Values are layered in increasing priority:
+// defaults, the optional JSON config file, TODO_* environment variables and command line flags.
+type Config struct {
+	Backend     string    `json:"backend"`
+	DSN         string    `json:"dsn"`
+	ListenAddr  string    `json:"listen_addr"`
+	LogLevel    string    `json:"log_level"`
+	Timeouts    Timeouts  `json:"timeouts"`
+	Trash       Trash     `json:"trash"`
+	HistoryFile string    `json:"history_file"` // JSON lines file the memory backend appends the todo history to
+	Subtasks    Subtasks  `json:"subtasks"`
+	Reminders   Reminders `json:"reminders"`
+
+	// WebSocketOrigins are the origins besides the server's own that browsers may open
+	// /todos/ws from, such as https://app.example.com, or * for any
+	WebSocketOrigins []string `json:"websocket_origins"`
+}
+
+// Reminders controls how often the scheduler looks for due reminders and where their
+// notifications go besides the log. A zero Interval disables the scheduler.
+type Reminders struct {
+	Interval      Duration `json:"interval"`
+	WebhookURL    string   `json:"webhook_url"`
+	WebhookSecret string   `json:"webhook_secret"`
+	File          string   `json:"file"` // JSON lines file notifications are appended to
+}
+
+// Subtasks controls what deleting a todo does to its subtasks (cascade, promote or
+// restrict) and whether completing every subtask completes the parent.
+type Subtasks struct {
+	OnDelete string `json:"on_delete"`
+	RollUp   bool   `json:"roll_up"`
+}
+
+// Trash controls how long deleted todos are kept before the sweeper purges them.
+// A zero Retention keeps them until they are purged by hand.
+type Trash struct {
+	Retention     Duration `json:"retention"`
+	SweepInterval Duration `json:"sweep_interval"`
+}
+
+// Routes are the route names the handlers look their timeout up by; create, update and
+// delete also time the commands of the WebSocket.
+var Routes = []string{
+	"batch", "create", "delete", "dependencies", "download", "feed", "get", "history", "list",
+	"lists", "order", "patch", "purge", "reminders", "restore", "search", "trash", "tree",
+	"update", "upload",
+}
+
+// Timeouts holds the request timeout per route, falling back to Default for unlisted routes.
+type Timeouts struct {
+	Default Duration            `json:"default"`
+	Routes  map[string]Duration `json:"routes"`
+}
+
+// For returns the timeout configured for the named route.
+func (t Timeouts) For(route string) time.Duration {
+	if d, ok := t.Routes[route]; ok && d > 0 {
+		return time.Duration(d)
+	}
+	return time.Duration(t.Default)
+}
+
+// Duration is a time.Duration that reads and writes strings such as "5s" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Default returns the configuration used when nothing else is specified.
+func Default() *Config {
+	return &Config{
+		Backend:    BackendMemory,
+		DSN:        "todos.db",
+		ListenAddr: ":8080",
+		LogLevel:   "info",
+		Timeouts: Timeouts{
+			Default: Duration(10 * time.Second),
+			Routes:  map[string]Duration{},
+		},
+		Trash: Trash{
+			Retention:     Duration(30 * 24 * time.Hour),
+			SweepInterval: Duration(time.Hour),
+		},
+		Subtasks: Subtasks{
+			OnDelete: string(storage.DeleteCascade),
+			RollUp:   true,
+		},
+		Reminders: Reminders{
+			Interval: Duration(30 * time.Second),
+		},
+	}
+}
+
+// Load builds the configuration from args (without the program name) and the environment.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("todoapp", flag.ContinueOnError)
+	configPath := fs.String("config", getenv("TODO_CONFIG"), "path to a JSON config file")
+	backend := fs.String("backend", "", "storage backend: memory or sqlite")
+	dsn := fs.String("dsn", "", "SQLite data source name")
+	listenAddr := fs.String("addr", "", "address the HTTP server listens on")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn or error")
+	timeout := fs.Duration("timeout", 0, "default request timeout")
+	routeTimeouts := fs.String("route-timeouts", "", "per-route timeouts, e.g. list=5s,upload=30s")
+	trashRetention := fs.Duration("trash-retention", 0, "how long deleted todos stay in the trash, 0 to keep them")
+	historyFile := fs.String("history-file", "", "file the memory backend appends the todo history to")
+	subtaskDelete := fs.String("subtask-delete", "", "what deleting a todo does to its subtasks: cascade, promote or restrict")
+	subtaskRollUp := fs.Bool("subtask-rollup", false, "complete a todo once all its subtasks are completed")
+	reminderInterval := fs.Duration("reminder-interval", 0, "how often due reminders are fired, 0 to disable reminders")
+	reminderWebhook := fs.String("reminder-webhook", "", "URL reminder notifications are posted to")
+	reminderFile := fs.String("reminder-file", "", "file reminder notifications are appended to")
+	wsOrigins := fs.String("ws-origins", "", "comma separated origins allowed to open WebSockets besides the server's own")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	// Only flags given explicitly override the earlier layers
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "backend":
+			cfg.Backend = *backend
+		case "dsn":
+			cfg.DSN = *dsn
+		case "addr":
+			cfg.ListenAddr = *listenAddr
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "timeout":
+			cfg.Timeouts.Default = Duration(*timeout)
+		case "trash-retention":
+			cfg.Trash.Retention = Duration(*trashRetention)
+		case "history-file":
+			cfg.HistoryFile = *historyFile
+		case "subtask-delete":
+			cfg.Subtasks.OnDelete = *subtaskDelete
+		case "subtask-rollup":
+			cfg.Subtasks.RollUp = *subtaskRollUp
+		case "reminder-interval":
+			cfg.Reminders.Interval = Duration(*reminderInterval)
+		case "reminder-webhook":
+			cfg.Reminders.WebhookURL = *reminderWebhook
+		case "reminder-file":
+			cfg.Reminders.File = *reminderFile
+		case "ws-origins":
+			cfg.WebSocketOrigins = splitList(*wsOrigins)
+		case "route-timeouts":
+			if err := cfg.Timeouts.parseRoutes(*routeTimeouts); err != nil {
+				flagErr = err
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	if c.Timeouts.Routes == nil {
+		c.Timeouts.Routes = map[string]Duration{}
+	}
+	return nil
+}
+
+func (c *Config) applyEnv(getenv func(string) string) error {
+	if v := getenv("TODO_BACKEND"); v != "" {
+		c.Backend = v
+	}
+	if v := getenv("TODO_DSN"); v != "" {
+		c.DSN = v
+	}
+	if v := getenv("TODO_ADDR"); v != "" {
+		c.ListenAddr = v
+	}
+	if v := getenv("TODO_LOG_LEVEL"); v != "" {
+		c.LogLevel = v
+	}
+	if v := getenv("TODO_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("TODO_TIMEOUT: %w", err)
+		}
+		c.Timeouts.Default = Duration(d)
+	}
+	if v := getenv("TODO_TRASH_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("TODO_TRASH_RETENTION: %w", err)
+		}
+		c.Trash.Retention = Duration(d)
+	}
+	if v := getenv("TODO_HISTORY_FILE"); v != "" {
+		c.HistoryFile = v
+	}
+	if v := getenv("TODO_SUBTASK_DELETE"); v != "" {
+		c.Subtasks.OnDelete = v
+	}
+	if v := getenv("TODO_SUBTASK_ROLLUP"); v != "" {
+		rollUp, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("TODO_SUBTASK_ROLLUP: %w", err)
+		}
+		c.Subtasks.RollUp = rollUp
+	}
+	if v := getenv("TODO_REMINDER_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("TODO_REMINDER_INTERVAL: %w", err)
+		}
+		c.Reminders.Interval = Duration(d)
+	}
+	if v := getenv("TODO_REMINDER_WEBHOOK"); v != "" {
+		c.Reminders.WebhookURL = v
+	}
+	if v := getenv("TODO_REMINDER_WEBHOOK_SECRET"); v != "" {
+		c.Reminders.WebhookSecret = v
+	}
+	if v := getenv("TODO_REMINDER_FILE"); v != "" {
+		c.Reminders.File = v
+	}
+	if v := getenv("TODO_WS_ORIGINS"); v != "" {
+		c.WebSocketOrigins = splitList(v)
+	}
+	if v := getenv("TODO_ROUTE_TIMEOUTS"); v != "" {
+		if err := c.Timeouts.parseRoutes(v); err != nil {
+			return fmt.Errorf("TODO_ROUTE_TIMEOUTS: %w", err)
+		}
+	}
+	return nil
+}
+
+// splitList reads a comma separated list, dropping empty entries.
+func splitList(spec string) []string {
+	items := []string{}
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseRoutes reads a comma separated list of route=duration pairs.
+func (t *Timeouts) parseRoutes(spec string) error {
+	if t.Routes == nil {
+		t.Routes = map[string]Duration{}
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		route, value, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("route timeout %q must look like route=duration", pair)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("route timeout %q: %w", pair, err)
+		}
+		t.Routes[strings.TrimSpace(route)] = Duration(d)
+	}
+	return nil
+}
+
+// Validate reports settings that cannot be used to start the server.
+func (c *Config) Validate() error {
+	switch c.Backend {
+	case BackendMemory:
+	case BackendSQLite:
+		if c.DSN == "" {
+			return fmt.Errorf("the sqlite backend requires a dsn")
+		}
+	default:
+		return fmt.Errorf("unknown backend %q, expected %q or %q", c.Backend, BackendMemory, BackendSQLite)
+	}
+	if _, err := c.Level(); err != nil {
+		return err
+	}
+	if c.Timeouts.Default <= 0 {
+		return fmt.Errorf("the default timeout must be positive")
+	}
+	for route := range c.Timeouts.Routes {
+		if !slices.Contains(Routes, route) {
+			return fmt.Errorf("unknown route %q in the route timeouts, expected one of %s", route, strings.Join(Routes, ", "))
+		}
+	}
+	if c.Trash.Retention < 0 {
+		return fmt.Errorf("the trash retention cannot be negative")
+	}
+	if c.Trash.Retention > 0 && c.Trash.SweepInterval <= 0 {
+		return fmt.Errorf("the trash sweep interval must be positive")
+	}
+	if _, err := storage.ParseDeleteRule(c.Subtasks.OnDelete); err != nil {
+		return err
+	}
+	if c.Reminders.Interval < 0 {
+		return fmt.Errorf("the reminder interval cannot be negative")
+	}
+	if c.Reminders.WebhookURL != "" {
+		u, err := url.Parse(c.Reminders.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("the reminder webhook must be an http or https URL")
+		}
+	}
+	for _, origin := range c.WebSocketOrigins {
+		u, err := url.Parse(origin)
+		if origin != "*" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "") {
+			return fmt.Errorf("websocket origin %q must be * or a scheme and host such as https://app.example.com", origin)
+		}
+	}
+	return nil
+}
+
+// Level converts the configured log level name into a slog.Level.
+func (c *Config) Level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return level, fmt.Errorf("invalid log level %q: %w", c.LogLevel, err)
+	}
+	return level, nil
+}
+
+// StorageOptions builds the storage.Options for the configured backend.
+// The returned close function releases the database or history file, if one was opened.
+func (c *Config) StorageOptions(ctx context.Context) (storage.Options, func() error, error) {
+	level, err := c.Level()
+	if err != nil {
+		return storage.Options{}, nil, err
+	}
+	onDelete, err := storage.ParseDeleteRule(c.Subtasks.OnDelete)
+	if err != nil {
+		return storage.Options{}, nil, err
+	}
+	options := storage.Options{
+		Logger:   slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level})),
+		Subtasks: storage.SubtaskOptions{OnDelete: onDelete, RollUp: c.Subtasks.RollUp},
+	}
+
+	if c.Backend != BackendSQLite {
+		history, err := storage.NewMemoryHistoryStore(storage.DefaultHistoryCapacity, c.HistoryFile)
+		if err != nil {
+			return storage.Options{}, nil, err
+		}
+		options.Store = storage.NewInMemoryStore()
+		options.History = history
+		return options, history.Close, nil
+	}
+
+	db, err := sql.Open("sqlite3", sqliteDSN(c.DSN))
+	if err != nil {
+		return storage.Options{}, nil, err
+	}
+	// SQLite takes one writer at a time, and every connection to :memory: is a database
+	// of its own
+	db.SetMaxOpenConns(1)
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, db)
+	if err != nil {
+		db.Close()
+		return storage.Options{}, nil, err
+	}
+	options.Store = store
+	options.History = storage.NewSQLiteHistoryStore(db)
+	return options, db.Close, nil
+}
+
+// sqliteBusyTimeout is how long, in milliseconds, a connection waits for a lock held by
+// another process before failing with "database is locked".
+const sqliteBusyTimeout = 5000
+
+// sqliteDSN adds the busy timeout to a DSN that doesn't set one.
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_busy_timeout=") || strings.Contains(dsn, "_timeout=") {
+		return dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%s_busy_timeout=%d", dsn, separator, sqliteBusyTimeout)
+}
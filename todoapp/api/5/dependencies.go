@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"todoapp/5/storage"
+)
+
+// todoDependenciesHandler serves GET /todos/{id}/dependencies: the todos blocking it
+// and the todos it blocks.
+func todoDependenciesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTodoID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("dependencies"))
+	defer cancel()
+
+	dependencies, err := todoList.GetDependencies(ctx, id)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dependencies)
+}
+
+// addDependencyHandler serves POST /todos/{id}/dependencies with a {"blocker_id"} body
+// and responds with the todo's dependencies.
+func addDependencyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTodoID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	var request struct {
+		BlockerID int `json:"blocker_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.BlockerID <= 0 {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid request payload, expected a blocker_id"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("dependencies"))
+	defer cancel()
+
+	if err := todoList.AddDependency(ctx, id, request.BlockerID); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	dependencies, err := todoList.GetDependencies(ctx, id)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dependencies)
+}
+
+func removeDependencyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTodoID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	blockerID, err := strconv.Atoi(r.PathValue("blocker"))
+	if err != nil {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid blocker ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("dependencies"))
+	defer cancel()
+
+	if err := todoList.RemoveDependency(ctx, id, blockerID); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// todoOrderHandler serves GET /todos/order: the open todos, each after its open blockers.
+func todoOrderHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("order"))
+	defer cancel()
+
+	todos, err := todoList.OrderTodos(ctx)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todos)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"todoapp/5/storage"
+)
+
+// ProblemDetails is an RFC 7807 application/problem+json error body.
+// Code and Errors are extension members carrying the storage error code
+// and the field-level validation failures.
+type ProblemDetails struct {
+	Type     string               `json:"type"`
+	Title    string               `json:"title"`
+	Status   int                  `json:"status"`
+	Detail   string               `json:"detail,omitempty"`
+	Instance string               `json:"instance,omitempty"`
+	Code     storage.ErrorCode    `json:"code,omitempty"`
+	Errors   []storage.FieldError `json:"errors,omitempty"`
+}
+
+// problemTypes describes each storage error code: the HTTP status it is reported with
+// and the title of its problem type. Unlisted codes, such as storage failures, are 500s.
+var problemTypes = map[storage.ErrorCode]struct {
+	status int
+	title  string
+}{
+	storage.ErrTodoNotFound:       {http.StatusNotFound, "Todo not found"},
+	storage.ErrInvalidInput:       {http.StatusBadRequest, "Invalid input"},
+	storage.ErrDuplicateTodo:      {http.StatusConflict, "Duplicate todo"},
+	storage.ErrOperationTimeout:   {http.StatusGatewayTimeout, "Operation timed out"},
+	storage.ErrVersionConflict:    {http.StatusPreconditionFailed, "Precondition failed"},
+	storage.ErrPatchConflict:      {http.StatusConflict, "Patch conflict"},
+	storage.ErrWebhookNotFound:    {http.StatusNotFound, "Webhook not found"},
+	storage.ErrListNotFound:       {http.StatusNotFound, "List not found"},
+	storage.ErrDuplicateList:      {http.StatusConflict, "Duplicate list"},
+	storage.ErrListNotEmpty:       {http.StatusConflict, "List not empty"},
+	storage.ErrHasSubtasks:        {http.StatusConflict, "Todo has subtasks"},
+	storage.ErrDependencyNotFound: {http.StatusNotFound, "Dependency not found"},
+	storage.ErrDependencyCycle:    {http.StatusConflict, "Dependency cycle"},
+	storage.ErrTodoBlocked:        {http.StatusConflict, "Todo blocked"},
+	storage.ErrReminderNotFound:   {http.StatusNotFound, "Reminder not found"},
+	storage.ErrNotAcceptable:      {http.StatusNotAcceptable, "Not acceptable"},
+	storage.ErrImportRejected:     {http.StatusUnprocessableEntity, "Import rejected"},
+}
+
+// statusForError classifies err and returns the HTTP status it should be reported with.
+func statusForError(err error) int {
+	if problem, ok := problemTypes[storage.Classify(err).Code]; ok {
+		return problem.status
+	}
+	return http.StatusInternalServerError
+}
+
+// problemType turns an error code such as TODO_NOT_FOUND into the type URI /problems/todo-not-found.
+func problemType(code storage.ErrorCode) string {
+	return "/problems/" + strings.ReplaceAll(strings.ToLower(string(code)), "_", "-")
+}
+
+// writeProblem classifies err and writes it as problem details,
+// so every handler reports the same failure the same way.
+func writeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblemDetails(w, problemFor(r, err))
+}
+
+// problemFor classifies err and describes it as problem details for the request.
+func problemFor(r *http.Request, err error) ProblemDetails {
+	todoErr := storage.Classify(err)
+
+	title, detail := "Storage error", todoErr.Error()
+	if problem, ok := problemTypes[todoErr.Code]; ok {
+		title = problem.title
+	} else {
+		// Storage failures can carry SQL, paths and driver messages, which stay in the log
+		todoList.Logger.Error("Request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+		detail = "The request could not be completed"
+	}
+	return ProblemDetails{
+		Type:     problemType(todoErr.Code),
+		Title:    title,
+		Status:   statusForError(todoErr),
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     todoErr.Code,
+		Errors:   todoErr.Fields,
+	}
+}
+
+// writeStatusProblem writes a problem without a specific type, for failures such as
+// unsupported methods that are fully described by the HTTP status.
+func writeStatusProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblemDetails(w, ProblemDetails{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
+
+func writeProblemDetails(w http.ResponseWriter, problem ProblemDetails) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// withProblemFallbacks reports requests no route of the mux matches, an unknown path or a
+// method the path's routes don't take, as problems instead of the mux's plain text.
+func withProblemFallbacks(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(&fallbackWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// fallbackWriter replaces a 404 or 405 body written by the mux with problem details,
+// keeping the headers it set such as Allow. Other responses, like redirects, pass through.
+type fallbackWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	problem bool
+}
+
+func (w *fallbackWriter) WriteHeader(status int) {
+	if status != http.StatusNotFound && status != http.StatusMethodNotAllowed {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.problem = true
+	w.Header().Del("X-Content-Type-Options")
+	detail := "No route matches " + w.r.URL.Path
+	if status == http.StatusMethodNotAllowed {
+		detail = "Method " + w.r.Method + " is not supported on " + w.r.URL.Path
+	}
+	writeStatusProblem(w.ResponseWriter, w.r, status, detail)
+}
+
+func (w *fallbackWriter) Write(data []byte) (int, error) {
+	if w.problem {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// methodNotAllowed rejects a request whose method the route does not support.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeStatusProblem(w, r, http.StatusMethodNotAllowed, "Method "+r.Method+" is not supported on "+r.URL.Path)
+}
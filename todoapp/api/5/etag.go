@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"todoapp/5/storage"
+)
+
+// etagFor returns the strong entity tag of a todo, derived from its version.
+func etagFor(todo *storage.Todo) string {
+	return fmt.Sprintf("%q", fmt.Sprint(todo.Version))
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value lists the etag.
+// "*" matches any existing todo. With weak comparison, used for If-None-Match, weak tags
+// are compared by their opaque value; with the strong comparison If-Match requires
+// (RFC 9110, section 13.1.1), a weak tag never matches.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch evaluates the If-Match precondition of a write against the current todo.
+// It returns the version the write must be conditioned on, or 0 when the request has no
+// If-Match header, and a version conflict error when the precondition fails.
+func checkIfMatch(r *http.Request, current *storage.Todo) (int, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+	if !etagMatches(header, etagFor(current), false) {
+		return 0, &storage.TodoError{
+			Code:    storage.ErrVersionConflict,
+			Message: fmt.Sprintf("Todo with ID %d does not match If-Match %s", current.ID, header),
+		}
+	}
+	return current.Version, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETag_ConditionalGet(t *testing.T) {
+	server := newTestServer(t)
+	todo := addTodo(t, server, `{"description": "Cached"}`)
+	url := server.URL + "/todos/1"
+
+	resp := do(t, http.MethodGet, url, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	assert.Equal(t, etagFor(todo), etag)
+
+	resp = do(t, http.MethodGet, url, "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	resp = do(t, http.MethodGet, url, "", "If-None-Match", `W/`+etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode, "weak tags compare by value")
+	resp = do(t, http.MethodGet, url, "", "If-None-Match", `"0"`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestETag_IfMatch(t *testing.T) {
+	server := newTestServer(t)
+	todo := addTodo(t, server, `{"description": "Versioned"}`)
+	url := server.URL + "/todos/1"
+	stale := etagFor(todo)
+
+	resp := do(t, http.MethodPut, url, `{"description": "First"}`, "If-Match", stale)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	current := resp.Header.Get("ETag")
+	assert.NotEqual(t, stale, current)
+
+	// If-Match compares strongly, a weak tag never matches
+	resp = do(t, http.MethodPut, url, `{"description": "Weak"}`, "If-Match", `W/`+current)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	// The write that read the old version loses
+	resp = do(t, http.MethodPut, url, `{"description": "Second"}`, "If-Match", stale)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	var problem ProblemDetails
+	decode(t, resp, &problem)
+	assert.Equal(t, storage.ErrVersionConflict, problem.Code)
+
+	resp = do(t, http.MethodDelete, url, "", "If-Match", stale)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	resp = do(t, http.MethodDelete, url, "", "If-Match", current)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestETag_BodyVersionIsIgnored(t *testing.T) {
+	server := newTestServer(t)
+	addTodo(t, server, `{"description": "Unconditional"}`)
+
+	// Without If-Match a PUT is unconditional, whatever version the body carries
+	resp := do(t, http.MethodPut, server.URL+"/todos/1", `{"description": "Updated", "version": 42}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var todo storage.Todo
+	decode(t, resp, &todo)
+	assert.Equal(t, "Updated", todo.Description)
+	assert.Equal(t, 2, todo.Version)
+}
+
+func TestETag_ChangesOnRestore(t *testing.T) {
+	server := newTestServer(t)
+	addTodo(t, server, `{"description": "Restored"}`)
+	url := server.URL + "/todos/1"
+
+	resp := do(t, http.MethodGet, url, "")
+	etag := resp.Header.Get("ETag")
+	resp = do(t, http.MethodDelete, url, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = do(t, http.MethodPost, url+"/restore", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do(t, http.MethodGet, url, "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = do(t, http.MethodPut, url, `{"description": "Stale"}`, "If-Match", etag)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"todoapp/5/storage"
+)
+
+// sseKeepAlive is how often an idle event stream gets a comment, so proxies keep it open
+const sseKeepAlive = 15 * time.Second
+
+// todoEventsHandler streams the change feed as Server-Sent Events. A client reconnecting
+// with Last-Event-ID first gets the events it missed; when some of them are no longer
+// buffered it gets a "reset" event instead and should reload the todos.
+func todoEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeStatusProblem(w, r, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	var sub *storage.Subscription
+	var replay []*storage.Event
+	complete := true
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			writeProblem(w, r, storage.NewInvalidInputError("Invalid Last-Event-ID header"))
+			return
+		}
+		sub, replay, complete = todoList.Events.Resume(id)
+	} else {
+		sub = todoList.Events.Subscribe()
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind, the client reconnects and resumes
+				return
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one event in the text/event-stream format.
+func writeEvent(w http.ResponseWriter, event *storage.Event) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
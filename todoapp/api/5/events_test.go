@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent is one event read from a text/event-stream.
+type sseEvent struct {
+	id, event, data string
+}
+
+// streamEvents opens the event stream with an optional Last-Event-ID and reads n events.
+func streamEvents(t *testing.T, url, lastEventID string, n int) []sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for len(events) < n && scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch field {
+		case "id":
+			current.id = value
+		case "event":
+			current.event = value
+		case "data":
+			current.data = value
+		case "":
+			if current.event != "" {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		}
+	}
+	require.Len(t, events, n, "stream ended early: %v", scanner.Err())
+	return events
+}
+
+func TestTodoEvents_Replay(t *testing.T) {
+	server := newTestServer(t)
+	addTodo(t, server, `{"description": "First"}`)
+	addTodo(t, server, `{"description": "Second"}`)
+	resp := do(t, http.MethodDelete, server.URL+"/todos/1", "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// Resuming after the first event replays the ones that followed it
+	events := streamEvents(t, server.URL+"/todos/events", "1", 2)
+	assert.Equal(t, "2", events[0].id)
+	assert.Equal(t, string(storage.EventCreated), events[0].event)
+	assert.Contains(t, events[0].data, `"Second"`)
+	assert.Equal(t, "3", events[1].id)
+	assert.Equal(t, string(storage.EventDeleted), events[1].event)
+}
+
+func TestTodoEvents_Reset(t *testing.T) {
+	server := newTestServer(t)
+	todoList.Events = storage.NewEventBus(1)
+	addTodo(t, server, `{"description": "First"}`)
+	addTodo(t, server, `{"description": "Second"}`)
+	addTodo(t, server, `{"description": "Third"}`)
+
+	// Event 2 is no longer buffered, so the client is told to reload before the rest
+	events := streamEvents(t, server.URL+"/todos/events", "1", 2)
+	assert.Equal(t, "reset", events[0].event)
+	assert.Equal(t, "3", events[1].id)
+
+	resp := do(t, http.MethodGet, server.URL+"/todos/events", "", "Last-Event-ID", "latest")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"todoapp/5/storage"
+)
+
+// withRequestContext tags every request with the actor and request ID recorded in the
+// todo history. The actor comes from the X-Actor header; the request ID from X-Request-ID,
+// or a new random one, and is echoed back so clients can correlate their changes.
+func withRequestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		ctx := storage.WithRequestID(r.Context(), requestID)
+		ctx = storage.WithActor(ctx, r.Header.Get("X-Actor"))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func todoHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTodoID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("history"))
+	defer cancel()
+
+	changes, err := todoList.TodoHistory(ctx, id)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
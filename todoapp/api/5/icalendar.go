@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todoapp/5/storage"
+)
+
+// todoFeedHandler serves GET /todos.ics: every todo as an iCalendar VTODO, or the todos
+// of one list with ?list=ID, for calendar clients to subscribe to. Clients polling the
+// feed get a 304 while it is unchanged.
+func todoFeedHandler(w http.ResponseWriter, r *http.Request) {
+	listID := 0
+	if value := r.URL.Query().Get("list"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			writeProblem(w, r, storage.NewInvalidInputError("Invalid list parameter"))
+			return
+		}
+		listID = id
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("feed"))
+	defer cancel()
+
+	todos, err := todoList.GetAllTodos(ctx)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	if listID != 0 {
+		if _, err := todoList.GetList(ctx, listID); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		inList := []*storage.Todo{}
+		for _, todo := range todos {
+			if todo.ListID == listID {
+				inList = append(inList, todo)
+			}
+		}
+		todos = inList
+	}
+
+	var body bytes.Buffer
+	if err := storage.EncodeICalendar(&body, todos); err != nil {
+		writeProblem(w, r, storage.NewStorageError(err))
+		return
+	}
+	sum := sha256.Sum256(body.Bytes())
+	etag := fmt.Sprintf("%q", hex.EncodeToString(sum[:16]))
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="todos.ics"`)
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoFeed(t *testing.T) {
+	server := newTestServer(t)
+	addTodo(t, server, `{"description": "Inbox todo"}`)
+	resp := do(t, http.MethodPost, server.URL+"/lists", `{"name": "Work"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp = do(t, http.MethodPost, server.URL+"/lists/2/todos", `{"description": "Work todo"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = do(t, http.MethodGet, server.URL+"/todos.ics", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/calendar; charset=utf-8", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "BEGIN:VCALENDAR")
+	assert.Contains(t, string(body), "SUMMARY:Inbox todo")
+	assert.Contains(t, string(body), "SUMMARY:Work todo")
+
+	// Polling an unchanged feed is answered with a 304, a change gives a new tag
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	resp = do(t, http.MethodGet, server.URL+"/todos.ics", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	addTodo(t, server, `{"description": "Another"}`)
+	resp = do(t, http.MethodGet, server.URL+"/todos.ics", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do(t, http.MethodGet, server.URL+"/todos.ics?list=2", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "SUMMARY:Work todo")
+	assert.NotContains(t, string(body), "SUMMARY:Inbox todo")
+
+	resp = do(t, http.MethodGet, server.URL+"/todos.ics?list=42", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = do(t, http.MethodGet, server.URL+"/todos.ics?list=work", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
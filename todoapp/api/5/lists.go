@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"todoapp/5/storage"
+)
+
+// pathListID parses the {id} wildcard of the /lists routes.
+func pathListID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, storage.NewInvalidInputError("Invalid list ID")
+	}
+	return id, nil
+}
+
+// decodeList reads and validates a list from the request body.
+func decodeList(r *http.Request) (*storage.List, error) {
+	var list storage.List
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		return nil, storage.NewInvalidInputError("Invalid request payload")
+	}
+	if err := storage.ValidateList(&list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// listListsHandler serves GET /lists. Archived lists are left out unless archived=true.
+func listListsHandler(w http.ResponseWriter, r *http.Request) {
+	includeArchived := false
+	if value := r.URL.Query().Get("archived"); value != "" {
+		var err error
+		if includeArchived, err = strconv.ParseBool(value); err != nil {
+			writeProblem(w, r, storage.NewInvalidInputError("Invalid archived parameter"))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("lists"))
+	defer cancel()
+
+	lists, err := todoList.ListLists(ctx, includeArchived)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lists)
+}
+
+func createListHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := decodeList(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("lists"))
+	defer cancel()
+
+	created, err := todoList.CreateList(ctx, list)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/lists/"+strconv.Itoa(created.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func getListHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathListID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("lists"))
+	defer cancel()
+
+	list, err := todoList.GetList(ctx, id)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func updateListHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathListID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	list, err := decodeList(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("lists"))
+	defer cancel()
+
+	updated, err := todoList.UpdateList(ctx, id, list)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathListID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("lists"))
+	defer cancel()
+
+	if err := todoList.DeleteList(ctx, id); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listTodosHandler serves GET /lists/{id}/todos with the same query parameters as GET /todos.
+func listTodosHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathListID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	opts.Filter.ListID = id
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("list"))
+	defer cancel()
+
+	// An unknown list is a 404 rather than an empty page
+	if _, err := todoList.GetList(ctx, id); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	page, err := todoList.ListTodos(ctx, opts)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	writePaginationLinks(w, r, page)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// createListTodoHandler serves POST /lists/{id}/todos. The list in the path wins over
+// any list_id in the body.
+func createListTodoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathListID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	var newTodo *storage.Todo
+	if err := json.NewDecoder(r.Body).Decode(&newTodo); err != nil || newTodo == nil {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid request payload"))
+		return
+	}
+	newTodo.ListID = id
+	if err := storage.ValidateTodo(newTodo); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("create"))
+	defer cancel()
+
+	todo, err := todoList.CreateTodo(ctx, newTodo)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/todos/"+strconv.Itoa(todo.ID))
+	w.Header().Set("ETag", etagFor(todo))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(todo)
+}
@@ -5,75 +5,145 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"todoapp/5/config"
+	"todoapp/5/reminder"
 	"todoapp/5/storage"
+	"todoapp/5/webhook"
 )
 
 var todoList *storage.TodoList
 
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Code    string `json:"code,omitempty"`
-	Message string `json:"message,omitempty"`
-}
+// timeouts holds the per-route request timeouts from the configuration
+var timeouts config.Timeouts
 
-func writeErrorResponse(w http.ResponseWriter, status int, err error) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-
-	var response ErrorResponse
-	if todoErr, ok := err.(*storage.TodoError); ok {
-		response = ErrorResponse{
-			Error:   todoErr.Error(),
-			Code:    todoErr.Code,
-			Message: todoErr.Message,
-		}
-	} else {
-		response = ErrorResponse{
-			Error: err.Error(),
-		}
+func getTodosHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, err)
+		return
 	}
 
-	json.NewEncoder(w).Encode(response)
-}
+	// GET /todos is the inbox, the other lists are under /lists/{id}/todos
+	opts.Filter.ListID = storage.DefaultListID
 
-func getTodosHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("list"))
 	defer cancel()
 
-	list, err := todoList.GetAllTodos(ctx)
+	page, err := todoList.ListTodos(ctx, opts)
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, storage.NewStorageError(err))
+		writeProblem(w, r, err)
 		return
 	}
+
+	writePaginationLinks(w, r, page)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(list)
+	json.NewEncoder(w).Encode(page)
+}
+
+// parseListOptions reads the filter, sort and paging query parameters of GET /todos:
+// completed=true|false, q=substring, min_id, max_id, tag, overdue=true|false,
+// sort=field or -field, limit and cursor.
+func parseListOptions(query url.Values) (storage.ListOptions, error) {
+	opts := storage.ListOptions{Cursor: query.Get("cursor")}
+
+	intParam := func(name string, target *int) error {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return storage.NewInvalidInputError(fmt.Sprintf("Invalid %s parameter", name))
+			}
+			*target = n
+		}
+		return nil
+	}
+	if err := intParam("limit", &opts.Limit); err != nil {
+		return opts, err
+	}
+	if err := intParam("min_id", &opts.Filter.MinID); err != nil {
+		return opts, err
+	}
+	if err := intParam("max_id", &opts.Filter.MaxID); err != nil {
+		return opts, err
+	}
+
+	boolParam := func(name string, target **bool) error {
+		if value := query.Get(name); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return storage.NewInvalidInputError(fmt.Sprintf("Invalid %s parameter", name))
+			}
+			*target = &b
+		}
+		return nil
+	}
+	if err := boolParam("completed", &opts.Filter.Completed); err != nil {
+		return opts, err
+	}
+	if err := boolParam("overdue", &opts.Filter.Overdue); err != nil {
+		return opts, err
+	}
+	opts.Filter.Contains = query.Get("q")
+	opts.Filter.Tag = query.Get("tag")
+
+	sort, err := storage.ParseTodoSort(query.Get("sort"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Sort = sort
+	return opts, nil
+}
+
+// writePaginationLinks sets an RFC 8288 Link header pointing at the first and next pages.
+func writePaginationLinks(w http.ResponseWriter, r *http.Request, page *storage.TodoPage) {
+	link := func(cursor, rel string) string {
+		u := *r.URL
+		query := u.Query()
+		if cursor == "" {
+			query.Del("cursor")
+		} else {
+			query.Set("cursor", cursor)
+		}
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf("<%s>; rel=%q", u.RequestURI(), rel)
+	}
+
+	links := []string{link("", "first")}
+	if page.NextCursor != "" {
+		links = append(links, link(page.NextCursor, "next"))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
 }
 
 func createTodoHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("create"))
 	defer cancel()
 
 	var newTodo *storage.Todo
-	if err := json.NewDecoder(r.Body).Decode(&newTodo); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, storage.NewInvalidInputError("Invalid request payload"))
+	if err := json.NewDecoder(r.Body).Decode(&newTodo); err != nil || newTodo == nil {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid request payload"))
 		return
 	}
 
-	if newTodo.Description == "" {
-		writeErrorResponse(w, http.StatusBadRequest, storage.NewInvalidInputError("Todo description cannot be empty"))
+	if err := storage.ValidateTodo(newTodo); err != nil {
+		writeProblem(w, r, err)
 		return
 	}
 
-	todo, err := todoList.AddTodo(ctx, newTodo.Description)
+	todo, err := todoList.CreateTodo(ctx, newTodo)
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, storage.NewStorageError(err))
+		writeProblem(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", etagFor(todo))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(todo)
@@ -83,20 +153,23 @@ func getTodoHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Path[len("/todos/"):]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, storage.NewInvalidInputError("Invalid todo ID"))
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid todo ID"))
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("get"))
 	defer cancel()
 
 	todo, err := todoList.GetTodoByID(ctx, id)
 	if err != nil {
-		if err.Error() == "todo not found" {
-			writeErrorResponse(w, http.StatusNotFound, storage.NewTodoNotFoundError(id))
-		} else {
-			writeErrorResponse(w, http.StatusInternalServerError, storage.NewStorageError(err))
-		}
+		writeProblem(w, r, err)
+		return
+	}
+
+	etag := etagFor(todo)
+	w.Header().Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -108,155 +181,377 @@ func updateTodoHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Path[len("/todos/"):]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, storage.NewInvalidInputError("Invalid todo ID"))
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid todo ID"))
 		return
 	}
 
 	var updatedTodo *storage.Todo
-	if err := json.NewDecoder(r.Body).Decode(&updatedTodo); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, storage.NewInvalidInputError("Invalid request payload"))
+	if err := json.NewDecoder(r.Body).Decode(&updatedTodo); err != nil || updatedTodo == nil {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid request payload"))
 		return
 	}
 
-	if updatedTodo.Description == "" {
-		writeErrorResponse(w, http.StatusBadRequest, storage.NewInvalidInputError("Todo description cannot be empty"))
+	if err := storage.ValidateTodo(updatedTodo); err != nil {
+		writeProblem(w, r, err)
 		return
 	}
 
 	updatedTodo.ID = id
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("update"))
 	defer cancel()
 
+	// If-Match pins the update to the version the client last saw,
+	// the store rejects it atomically if another write got there first.
+	// The version in the body is ignored, so PUTting back a GET is unconditional.
+	updatedTodo.Version = 0
+	if r.Header.Get("If-Match") != "" {
+		current, err := todoList.GetTodoByID(ctx, id)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		version, err := checkIfMatch(r, current)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		updatedTodo.Version = version
+	}
+
 	err = todoList.UpdateTodoByID(ctx, id, updatedTodo)
 	if err != nil {
-		if err.Error() == "todo not found" {
-			writeErrorResponse(w, http.StatusNotFound, storage.NewTodoNotFoundError(id))
-		} else {
-			writeErrorResponse(w, http.StatusInternalServerError, storage.NewStorageError(err))
-		}
+		writeProblem(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", etagFor(updatedTodo))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedTodo)
 }
 
-func deleteTodoHandler(w http.ResponseWriter, r *http.Request) {
+// Media types accepted by PATCH /todos/{id}
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+func patchTodoHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Path[len("/todos/"):]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, storage.NewInvalidInputError("Invalid todo ID"))
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid todo ID"))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid request payload"))
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	var patch storage.TodoPatch
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchType:
+		patch, err = storage.NewMergePatch(body)
+	case jsonPatchType:
+		patch, err = storage.NewJSONPatch(body)
+	default:
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		writeStatusProblem(w, r, http.StatusUnsupportedMediaType, "PATCH requires a "+mergePatchType+" or "+jsonPatchType+" body")
+		return
+	}
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	// The precondition is checked inside the patch so it sees the same state the patch is applied to
+	if r.Header.Get("If-Match") != "" {
+		apply := patch
+		patch = func(todo *storage.Todo) error {
+			if _, err := checkIfMatch(r, todo); err != nil {
+				return err
+			}
+			return apply(todo)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("patch"))
 	defer cancel()
 
-	err = todoList.DeleteTodoByID(ctx, id)
+	todo, err := todoList.PatchTodoByID(ctx, id, patch)
 	if err != nil {
-		if err.Error() == "todo not found" {
-			writeErrorResponse(w, http.StatusNotFound, storage.NewTodoNotFoundError(id))
-		} else {
-			writeErrorResponse(w, http.StatusInternalServerError, storage.NewStorageError(err))
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etagFor(todo))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todo)
+}
+
+func deleteTodoHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.URL.Path[len("/todos/"):]
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid todo ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("delete"))
+	defer cancel()
+
+	version := 0
+	if r.Header.Get("If-Match") != "" {
+		current, err := todoList.GetTodoByID(ctx, id)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
 		}
+		if version, err = checkIfMatch(r, current); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+	}
+
+	err = todoList.DeleteTodoByIDAtVersion(ctx, id, version)
+	if err != nil {
+		writeProblem(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func searchTodosHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil {
+			writeProblem(w, r, storage.NewInvalidInputError("Invalid limit parameter"))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("search"))
+	defer cancel()
+
+	results, err := todoList.SearchTodos(ctx, query.Get("q"), limit)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   query.Get("q"),
+		"results": results,
+	})
+}
+
+// downloadTodosHandler serves every todo as a file in the format the Accept header asks
+// for, JSON by default.
 func downloadTodosHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	codec, ok := todoList.Codecs().Negotiate(r.Header.Get("Accept"))
+	if !ok {
+		writeProblem(w, r, storage.NewNotAcceptableError(r.Header.Get("Accept")))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("download"))
 	defer cancel()
 
-	filename := "todos.json"
+	filename := "todos" + codec.Extensions()[0]
 	err := todoList.Download(ctx, filename)
 	if err != nil {
-		http.Error(w, "Failed to download todos", http.StatusInternalServerError)
+		writeProblem(w, r, err)
 		return
 	}
 
 	file, err := os.Open(filename)
 	if err != nil {
-		http.Error(w, "Error opening file", http.StatusInternalServerError)
+		writeProblem(w, r, storage.NewStorageError(err))
 		return
 	}
 	defer file.Close()
 
 	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", codec.MediaType())
+	w.Header().Add("Vary", "Accept")
 	http.ServeFile(w, r, filename)
 }
 
+// uploadTodosHandler imports todos from a multipart "file" field, from a body in a format
+// with a codec, such as text/csv, or from a file on the server named by a JSON
+// {"path": ...} body. Uploaded files are decoded by their Content-Type or extension and
+// server files by their extension, defaulting to JSON.
 func uploadTodosHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("upload"))
 	defer cancel()
 
 	var file io.Reader
 	var err error
+	codecs := todoList.Codecs()
+	codec := storage.Codec(&storage.JSONCodec{IO: todoList.StorageIO})
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	bodyCodec, isCodec := codecs.ForMediaType(mediaType)
 
 	// Check for multipart file upload
-	if r.Header.Get("Content-Type") == "multipart/form-data" {
+	if mediaType == "multipart/form-data" {
 		err = r.ParseMultipartForm(10 << 20) // 10MB limit
 		if err != nil {
-			http.Error(w, "Failed to parse multipart form", http.StatusBadRequest)
+			writeProblem(w, r, storage.NewInvalidInputError("Failed to parse multipart form"))
 			return
 		}
 
-		uploadedFile, _, err := r.FormFile("file")
+		uploadedFile, header, err := r.FormFile("file")
 		if err != nil {
-			http.Error(w, "Failed to retrieve uploaded file", http.StatusBadRequest)
+			writeProblem(w, r, storage.NewInvalidInputError("Failed to retrieve uploaded file"))
 			return
 		}
 		defer uploadedFile.Close()
 
+		if found, ok := codecs.ForMediaType(header.Header.Get("Content-Type")); ok {
+			codec = found
+		} else if found, ok := codecs.ForPath(header.Filename); ok {
+			codec = found
+		}
 		file = uploadedFile
+	} else if isCodec && mediaType != "application/json" {
+		codec = bodyCodec
+		file = r.Body
 	} else {
 		// Fallback to JSON body with "path"
 		var requestData struct {
 			Path string `json:"path"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			writeProblem(w, r, storage.NewInvalidInputError("Invalid request payload"))
 			return
 		}
 
 		file, err = os.Open(requestData.Path)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to open file: %s", err.Error()), http.StatusInternalServerError)
+			writeProblem(w, r, storage.NewStorageError(err))
 			return
 		}
 		defer file.(*os.File).Close()
+		if found, ok := codecs.ForPath(requestData.Path); ok {
+			codec = found
+		}
 	}
 
-	// Decode todos
-	var todos []*storage.Todo
-	if err := todoList.StorageIO.DecodeJSON(file, &todos); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to parse todos: %s", err.Error()), http.StatusInternalServerError)
+	if csvCodec, ok := codec.(*storage.CSVCodec); ok {
+		if codec, err = csvOptions(csvCodec, r.URL.Query()); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+	}
+
+	result, err := todoList.ImportFrom(ctx, file, codec)
+	if err != nil {
+		writeProblem(w, r, err)
 		return
 	}
 
-	// Add todos to storage
-	for _, todo := range todos {
-		_, err := todoList.Store.AddTodo(ctx, todo.Description)
-		if err != nil {
-			todoList.Logger.Error("Failed to add todo", "id", todo.ID, "error", err)
-		}
+	message := "Todos uploaded successfully"
+	if result.Skipped > 0 {
+		message = "Todos uploaded, some were skipped"
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Todos uploaded successfully",
-	})
+	json.NewEncoder(w).Encode(struct {
+		Message string `json:"message"`
+		*storage.ImportResult
+	}{message, result})
+}
+
+// csvOptions applies the ?delimiter=%3B (or \t) and ?columns=Task:description,Due:due_date
+// parameters of an upload to a copy of the CSV codec.
+func csvOptions(codec *storage.CSVCodec, query url.Values) (*storage.CSVCodec, error) {
+	configured := *codec
+	if value := query.Get("delimiter"); value != "" {
+		if value == `\t` {
+			value = "\t"
+		}
+		delimiter := []rune(value)
+		if len(delimiter) != 1 || delimiter[0] == '"' || delimiter[0] == '\r' || delimiter[0] == '\n' {
+			return nil, storage.NewInvalidInputError("Invalid delimiter parameter")
+		}
+		configured.Delimiter = delimiter[0]
+	}
+	if value := query.Get("columns"); value != "" {
+		configured.Columns = map[string]string{}
+		for _, mapping := range strings.Split(value, ",") {
+			header, column, ok := strings.Cut(mapping, ":")
+			if !ok || !slices.Contains(storage.CSVColumns, column) {
+				return nil, storage.NewInvalidInputError(fmt.Sprintf("Invalid column mapping %q", mapping))
+			}
+			configured.Columns[header] = column
+		}
+	}
+	return &configured, nil
 }
 
 func main() {
-	todoList = storage.NewTodoList()
-	// Create a new ServeMux to handle routes
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Println("Configuration error:", err)
+		os.Exit(2)
+	}
+
+	options, closeStore, err := cfg.StorageOptions(context.Background())
+	if err != nil {
+		fmt.Println("Storage error:", err)
+		os.Exit(1)
+	}
+	defer closeStore()
+
+	todoList = storage.NewTodoListWithOptions(options)
+	timeouts = cfg.Timeouts
+	socketUpgrader.AllowedOrigins = cfg.WebSocketOrigins
+
+	if cfg.Trash.Retention > 0 {
+		todoList.StartTrashSweeper(context.Background(), time.Duration(cfg.Trash.Retention), time.Duration(cfg.Trash.SweepInterval))
+	}
+
+	webhooks = webhook.NewRegistry()
+	dispatcher = webhook.NewDispatcher(webhooks)
+	dispatcher.Logger = todoList.Logger
+	dispatcher.Start(context.Background(), todoList.Events)
+
+	if cfg.Reminders.Interval > 0 {
+		notifiers := []reminder.Notifier{&reminder.LogNotifier{Logger: todoList.Logger}}
+		if cfg.Reminders.WebhookURL != "" {
+			notifiers = append(notifiers, reminder.NewWebhookNotifier(cfg.Reminders.WebhookURL, cfg.Reminders.WebhookSecret))
+		}
+		if cfg.Reminders.File != "" {
+			notifiers = append(notifiers, &reminder.FileNotifier{Path: cfg.Reminders.File})
+		}
+		scheduler := reminder.NewScheduler(todoList.Store, notifiers...)
+		scheduler.Clock = todoList.Clock
+		scheduler.Logger = todoList.Logger
+		scheduler.Interval = time.Duration(cfg.Reminders.Interval)
+		scheduler.Start(context.Background())
+	}
+
+	// Start the HTTP server
+	todoList.Logger.Info("Server is running", "addr", cfg.ListenAddr, "backend", cfg.Backend)
+	err = http.ListenAndServe(cfg.ListenAddr, newHandler())
+	if err != nil {
+		fmt.Println("Server error:", err)
+	}
+}
+
+// newHandler routes every endpoint to its handler, behind the request context middleware.
+func newHandler() http.Handler {
 	mux := http.NewServeMux()
 
 	// Route for getting all todos and creating a new todo
@@ -267,27 +562,91 @@ func main() {
 		case http.MethodPost:
 			createTodoHandler(w, r)
 		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
 		}
 	})
 
-	// Route for handling specific todo by ID (get, update, delete)
+	// Route for handling specific todo by ID (get, update, patch, delete)
 	mux.HandleFunc("/todos/", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
 			getTodoHandler(w, r)
 		case http.MethodPut:
 			updateTodoHandler(w, r)
+		case http.MethodPatch:
+			patchTodoHandler(w, r)
 		case http.MethodDelete:
 			deleteTodoHandler(w, r)
 		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
 		}
 	})
 
+	// Creates, updates and deletes applied all-or-nothing
+	mux.HandleFunc("POST /todos/batch", batchTodosHandler)
+	mux.HandleFunc("/todos/batch", func(w http.ResponseWriter, r *http.Request) {
+		methodNotAllowed(w, r, http.MethodPost)
+	})
+
+	// Change feed as Server-Sent Events
+	mux.HandleFunc("GET /todos/events", todoEventsHandler)
+
+	// Change feed and commands over a WebSocket
+	mux.HandleFunc("GET /todos/ws", todoSocketHandler)
+
+	// Audit history of a todo, including deleted and purged ones
+	mux.HandleFunc("GET /todos/{id}/history", todoHistoryHandler)
+
+	// A todo with its subtasks, nested to any depth
+	mux.HandleFunc("GET /todos/{id}/tree", todoTreeHandler)
+
+	// "Blocked by" dependencies and the order they allow the open todos to be done in
+	mux.HandleFunc("GET /todos/{id}/dependencies", todoDependenciesHandler)
+	mux.HandleFunc("POST /todos/{id}/dependencies", addDependencyHandler)
+	mux.HandleFunc("DELETE /todos/{id}/dependencies/{blocker}", removeDependencyHandler)
+	mux.HandleFunc("GET /todos/order", todoOrderHandler)
+
+	// Reminders, fired once by the scheduler through the configured notifiers
+	mux.HandleFunc("GET /todos/{id}/reminders", todoRemindersHandler)
+	mux.HandleFunc("POST /todos/{id}/reminders", addReminderHandler)
+	mux.HandleFunc("DELETE /todos/{id}/reminders/{reminder}", deleteReminderHandler)
+
+	// Trash: deleted todos can be listed, restored or purged for good
+	mux.HandleFunc("GET /todos/trash", listTrashHandler)
+	mux.HandleFunc("POST /todos/{id}/restore", restoreTodoHandler)
+	mux.HandleFunc("DELETE /todos/trash/{id}", purgeTodoHandler)
+
+	// Webhook subscriptions, their delivery log and the events that could not be delivered
+	mux.HandleFunc("POST /webhooks", createWebhookHandler)
+	mux.HandleFunc("GET /webhooks", listWebhooksHandler)
+	mux.HandleFunc("GET /webhooks/{id}", getWebhookHandler)
+	mux.HandleFunc("DELETE /webhooks/{id}", deleteWebhookHandler)
+	mux.HandleFunc("GET /webhooks/{id}/deliveries", webhookDeliveriesHandler)
+	mux.HandleFunc("GET /webhooks/dead-letters", deadLettersHandler)
+
+	// Named lists, /todos being the inbox
+	mux.HandleFunc("GET /lists", listListsHandler)
+	mux.HandleFunc("POST /lists", createListHandler)
+	mux.HandleFunc("GET /lists/{id}", getListHandler)
+	mux.HandleFunc("PUT /lists/{id}", updateListHandler)
+	mux.HandleFunc("DELETE /lists/{id}", deleteListHandler)
+	mux.HandleFunc("GET /lists/{id}/todos", listTodosHandler)
+	mux.HandleFunc("POST /lists/{id}/todos", createListTodoHandler)
+
+	// iCalendar feed of the todos for calendar clients
+	mux.HandleFunc("GET /todos.ics", todoFeedHandler)
+
+	mux.HandleFunc("/todos/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		searchTodosHandler(w, r)
+	})
+
 	mux.HandleFunc("/todos/download", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			methodNotAllowed(w, r, http.MethodGet)
 			return
 		}
 		downloadTodosHandler(w, r)
@@ -295,16 +654,11 @@ func main() {
 
 	mux.HandleFunc("/todos/upload", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			methodNotAllowed(w, r, http.MethodPost)
 			return
 		}
 		uploadTodosHandler(w, r)
 	})
 
-	// Start the HTTP server
-	fmt.Println("Server is running on http://localhost:8080")
-	err := http.ListenAndServe(":8080", mux)
-	if err != nil {
-		fmt.Println("Server error:", err)
-	}
+	return withRequestContext(withProblemFallbacks(mux))
 }
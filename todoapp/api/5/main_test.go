@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"todoapp/5/config"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer points the handlers at a fresh in-memory todo list and serves them
+// with the routes of main.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	todoList = storage.NewTodoList()
+	todoList.DisableLogging()
+	timeouts = config.Default().Timeouts
+
+	server := httptest.NewServer(newHandler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// do sends a request with an optional body and headers given as name, value pairs.
+func do(t *testing.T, method, url, body string, headers ...string) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	require.NoError(t, err)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// decode reads a JSON response body into target.
+func decode(t *testing.T, resp *http.Response, target interface{}) {
+	t.Helper()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(target))
+}
+
+// addTodo creates a todo through the API.
+func addTodo(t *testing.T, server *httptest.Server, body string) *storage.Todo {
+	t.Helper()
+	resp := do(t, http.MethodPost, server.URL+"/todos", body)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var todo storage.Todo
+	decode(t, resp, &todo)
+	return &todo
+}
+
+func TestProblemDetails(t *testing.T) {
+	server := newTestServer(t)
+	addTodo(t, server, `{"description": "Existing"}`)
+
+	tests := []struct {
+		name, method, path, body string
+		status                   int
+		code                     storage.ErrorCode
+	}{
+		{"missing todo", http.MethodGet, "/todos/42", "", http.StatusNotFound, storage.ErrTodoNotFound},
+		{"invalid ID", http.MethodGet, "/todos/abc", "", http.StatusBadRequest, storage.ErrInvalidInput},
+		{"malformed body", http.MethodPost, "/todos", `{"description":`, http.StatusBadRequest, storage.ErrInvalidInput},
+		{"null create", http.MethodPost, "/todos", `null`, http.StatusBadRequest, storage.ErrInvalidInput},
+		{"null update", http.MethodPut, "/todos/1", `null`, http.StatusBadRequest, storage.ErrInvalidInput},
+		{"duplicate", http.MethodPost, "/todos", `{"description": "Existing"}`, http.StatusConflict, storage.ErrDuplicateTodo},
+		{"missing list", http.MethodGet, "/lists/42", "", http.StatusNotFound, storage.ErrListNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(t, tt.method, server.URL+tt.path, tt.body)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+			var problem ProblemDetails
+			decode(t, resp, &problem)
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, tt.code, problem.Code)
+			assert.Equal(t, problemType(tt.code), problem.Type)
+			assert.Equal(t, tt.path, problem.Instance)
+			assert.NotEmpty(t, problem.Title)
+		})
+	}
+}
+
+func TestProblemDetails_FieldErrors(t *testing.T) {
+	server := newTestServer(t)
+
+	resp := do(t, http.MethodPost, server.URL+"/todos", `{"description": ""}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	var problem ProblemDetails
+	decode(t, resp, &problem)
+	require.NotEmpty(t, problem.Errors)
+	assert.Equal(t, "description", problem.Errors[0].Field)
+}
+
+func TestProblemDetails_MethodNotAllowed(t *testing.T) {
+	server := newTestServer(t)
+
+	resp := do(t, http.MethodDelete, server.URL+"/todos", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "GET, POST", resp.Header.Get("Allow"))
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+}
+
+func TestProblemDetails_Fallbacks(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		method, path string
+		status       int
+		allow        string
+	}{
+		{http.MethodPut, "/webhooks", http.StatusMethodNotAllowed, "GET, HEAD, POST"},
+		{http.MethodPost, "/lists/1", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, PUT"},
+		{http.MethodGet, "/nowhere", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		resp := do(t, tt.method, server.URL+tt.path, "")
+		assert.Equal(t, tt.status, resp.StatusCode, tt.path)
+		assert.Equal(t, tt.allow, resp.Header.Get("Allow"), tt.path)
+		assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"), tt.path)
+		var problem ProblemDetails
+		decode(t, resp, &problem)
+		assert.Equal(t, tt.status, problem.Status, tt.path)
+		assert.Equal(t, tt.path, problem.Instance, tt.path)
+	}
+}
+
+func TestProblemDetails_HidesStorageErrors(t *testing.T) {
+	newTestServer(t)
+	r := httptest.NewRequest(http.MethodGet, "/todos", nil)
+
+	problem := problemFor(r, storage.NewStorageError(errors.New("no such table: todos")))
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+	assert.NotContains(t, problem.Detail, "no such table")
+	assert.NotEmpty(t, problem.Detail)
+}
+
+func TestPatchTodo_MediaTypes(t *testing.T) {
+	server := newTestServer(t)
+	addTodo(t, server, `{"description": "Patched", "tags": ["a"]}`)
+	url := server.URL + "/todos/1"
+
+	resp := do(t, http.MethodPatch, url, `{"completed": true, "tags": null}`, "Content-Type", mergePatchType)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var todo storage.Todo
+	decode(t, resp, &todo)
+	assert.True(t, todo.Completed)
+	assert.Empty(t, todo.Tags)
+	assert.Equal(t, "Patched", todo.Description)
+
+	resp = do(t, http.MethodPatch, url, `[{"op": "replace", "path": "/description", "value": "Renamed"}]`,
+		"Content-Type", jsonPatchType+"; charset=utf-8")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	decode(t, resp, &todo)
+	assert.Equal(t, "Renamed", todo.Description)
+
+	// A failed test operation is a conflict
+	resp = do(t, http.MethodPatch, url, `[{"op": "test", "path": "/description", "value": "Patched"}]`,
+		"Content-Type", jsonPatchType)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	for _, contentType := range []string{"", "application/json", "text/plain"} {
+		resp = do(t, http.MethodPatch, url, `{"completed": false}`, "Content-Type", contentType)
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode, contentType)
+		assert.Equal(t, mergePatchType+", "+jsonPatchType, resp.Header.Get("Accept-Patch"), contentType)
+		assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"), contentType)
+	}
+}
+
+func TestGetTodos_PaginationLinks(t *testing.T) {
+	server := newTestServer(t)
+	for _, description := range []string{"One", "Two", "Three"} {
+		addTodo(t, server, `{"description": "`+description+`"}`)
+	}
+
+	resp := do(t, http.MethodGet, server.URL+"/todos?limit=2&completed=false", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var page storage.TodoPage
+	decode(t, resp, &page)
+	require.Len(t, page.Todos, 2)
+	require.NotEmpty(t, page.NextCursor)
+	links := resp.Header.Get("Link")
+	assert.Contains(t, links, `</todos?completed=false&limit=2>; rel="first"`)
+	next := "/todos?completed=false&cursor=" + url.QueryEscape(page.NextCursor) + "&limit=2"
+	assert.Contains(t, links, "<"+next+`>; rel="next"`)
+
+	// The next link leads to the last page, which has no next link
+	resp = do(t, http.MethodGet, server.URL+next, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var last storage.TodoPage
+	decode(t, resp, &last)
+	require.Len(t, last.Todos, 1)
+	assert.Equal(t, "Three", last.Todos[0].Description)
+	assert.Empty(t, last.NextCursor)
+	assert.NotContains(t, resp.Header.Get("Link"), `rel="next"`)
+
+	resp = do(t, http.MethodGet, server.URL+"/todos?limit=two", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestUpdateTodo_Duplicate(t *testing.T) {
+	server := newTestServer(t)
+	addTodo(t, server, `{"description": "a"}`)
+	addTodo(t, server, `{"description": "b"}`)
+
+	resp := do(t, http.MethodPatch, server.URL+"/todos/1", `{"description": "b"}`, "Content-Type", mergePatchType)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	resp = do(t, http.MethodPut, server.URL+"/todos/1", `{"description": "b"}`)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	var problem ProblemDetails
+	decode(t, resp, &problem)
+	assert.Equal(t, storage.ErrDuplicateTodo, problem.Code)
+
+	assert.ElementsMatch(t, []string{"a", "b"}, inboxDescriptions(t, server))
+}
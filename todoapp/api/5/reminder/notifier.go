@@ -0,0 +1,119 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"todoapp/5/storage"
+	"todoapp/5/webhook"
+)
+
+// EventType is sent in the webhook.EventHeader of reminder notifications
+const EventType = "reminder"
+
+// Notification is what notifiers deliver when a reminder fires.
+type Notification struct {
+	Reminder *storage.Reminder `json:"reminder"`
+	Todo     *storage.Todo     `json:"todo"`
+}
+
+// Notifier delivers notifications. A reminder whose delivery failed, with any notifier,
+// is retried with all of them, so Notify may see the same reminder more than once.
+type Notifier interface {
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// LogNotifier writes notifications to a logger.
+type LogNotifier struct {
+	Logger *slog.Logger
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification *Notification) error {
+	todo := notification.Todo
+	args := []any{"id", todo.ID, "reminder", notification.Reminder.ID, "description", todo.Description}
+	if todo.DueDate != nil {
+		args = append(args, "due", *todo.DueDate)
+	}
+	n.Logger.Info("Reminder", args...)
+	return nil
+}
+
+// WebhookNotifier posts notifications as JSON to a URL, signed like webhook deliveries
+// so receivers can check them with webhook.Verify.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+	Clock  storage.Clock // Time source for the signatures, the system clock when nil
+}
+
+// NewWebhookNotifier creates a notifier posting to url, signing with secret.
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Secret: secret, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := nowFrom(n.Clock).Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todoapp-reminders/1")
+	req.Header.Set(webhook.EventHeader, EventType)
+	req.Header.Set(webhook.TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(n.Secret, timestamp, body))
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return nil
+}
+
+// FileNotifier appends notifications to a file as JSON lines. The file is opened for
+// every notification, so it can be rotated underneath.
+type FileNotifier struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (n *FileNotifier) Notify(ctx context.Context, notification *Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(notification); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func nowFrom(clock storage.Clock) time.Time {
+	if clock == nil {
+		clock = storage.SystemClock
+	}
+	return clock.Now().UTC()
+}
@@ -0,0 +1,142 @@
+// Package reminder fires the reminders of todos through pluggable notifiers.
+package reminder
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+	"todoapp/5/storage"
+)
+
+// Scheduler defaults
+const (
+	DefaultInterval    = 30 * time.Second // How often the scheduler looks for due reminders
+	DefaultLease       = 2 * time.Minute  // How long a delivery may take before the reminder is retried
+	DefaultMaxAttempts = 5                // Deliveries tried before a failing reminder is given up
+)
+
+// Scheduler fires due reminders at least once. Each reminder is claimed in the store for
+// a lease before it is delivered and only marked fired once every notifier took it, so
+// several schedulers on one SQLite database don't deliver it side by side, and a failed
+// delivery, or one cut short by a crash, is retried once the lease runs out. A retry
+// goes to every notifier again. Reminders that came due while the server was down fire
+// on the first run after a restart, reminders of completed todos are marked fired
+// without notifying anyone.
+type Scheduler struct {
+	Store       storage.TodoStore
+	Notifiers   []Notifier
+	Clock       storage.Clock // Time source deciding which reminders are due, the system clock when nil
+	Logger      *slog.Logger
+	Interval    time.Duration
+	Lease       time.Duration
+	MaxAttempts int
+}
+
+// NewScheduler creates a scheduler for the reminders in store with the default interval,
+// lease and attempts.
+func NewScheduler(store storage.TodoStore, notifiers ...Notifier) *Scheduler {
+	return &Scheduler{
+		Store:       store,
+		Notifiers:   notifiers,
+		Logger:      slog.Default(),
+		Interval:    DefaultInterval,
+		Lease:       DefaultLease,
+		MaxAttempts: DefaultMaxAttempts,
+	}
+}
+
+// FireDue fires the reminders due now and returns how many were delivered to the notifiers.
+func (s *Scheduler) FireDue(ctx context.Context) (int, error) {
+	now := nowFrom(s.Clock)
+	due, err := s.Store.DueReminders(ctx, now)
+	if err != nil {
+		s.Logger.Error("Failed to list due reminders", "error", err)
+		return 0, err
+	}
+
+	fired := 0
+	for _, reminder := range due {
+		claimed, err := s.Store.ClaimReminder(ctx, reminder.ID, now, s.Lease)
+		if err != nil {
+			s.Logger.Error("Failed to claim reminder", "reminder", reminder.ID, "error", err)
+			return fired, err
+		}
+		if !claimed {
+			continue
+		}
+		reminder.Attempts++
+
+		// The todo may have been trashed since the reminders were listed, then the
+		// reminder waits for it to be restored
+		todo, err := s.Store.GetTodoByID(ctx, reminder.TodoID)
+		if err != nil {
+			s.Logger.Warn("Postponed reminder", "id", reminder.TodoID, "reminder", reminder.ID, "error", err)
+			continue
+		}
+		if todo.Completed {
+			s.Logger.Debug("Skipped reminder of a completed todo", "id", todo.ID, "reminder", reminder.ID)
+			if err := s.markFired(ctx, reminder, now); err != nil {
+				return fired, err
+			}
+			continue
+		}
+
+		reminder.FiredAt = &now
+		if err := s.notify(ctx, &Notification{Reminder: reminder, Todo: todo}); err != nil {
+			if reminder.Attempts < s.MaxAttempts {
+				s.Logger.Warn("Reminder notification failed, retrying after the lease", "id", todo.ID, "reminder", reminder.ID,
+					"attempts", reminder.Attempts, "error", err)
+				continue
+			}
+			s.Logger.Error("Reminder notification failed, giving up", "id", todo.ID, "reminder", reminder.ID,
+				"attempts", reminder.Attempts, "error", err)
+		}
+		if err := s.markFired(ctx, reminder, now); err != nil {
+			return fired, err
+		}
+		fired++
+	}
+	return fired, nil
+}
+
+// notify hands a notification to every notifier and joins their errors.
+func (s *Scheduler) notify(ctx context.Context, notification *Notification) error {
+	var errs []error
+	for _, notifier := range s.Notifiers {
+		if err := notifier.Notify(ctx, notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// markFired records a delivered reminder. When that fails the claim runs out and the
+// reminder is delivered again.
+func (s *Scheduler) markFired(ctx context.Context, reminder *storage.Reminder, now time.Time) error {
+	if _, err := s.Store.MarkReminderFired(ctx, reminder.ID, now); err != nil {
+		s.Logger.Error("Failed to mark reminder fired", "reminder", reminder.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// Start fires due reminders right away and then every interval in a background
+// goroutine until ctx is cancelled. The returned channel is closed once it has stopped.
+func (s *Scheduler) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(max(s.Interval, time.Second))
+		defer ticker.Stop()
+		for {
+			s.FireDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
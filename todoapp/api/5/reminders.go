@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"todoapp/5/storage"
+)
+
+// todoRemindersHandler serves GET /todos/{id}/reminders, fired ones included.
+func todoRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTodoID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("reminders"))
+	defer cancel()
+
+	reminders, err := todoList.ListReminders(ctx, id)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reminders)
+}
+
+// addReminderHandler serves POST /todos/{id}/reminders with an {"at"} body.
+func addReminderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTodoID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	var request struct {
+		At time.Time `json:"at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid request payload, expected an RFC 3339 at time"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("reminders"))
+	defer cancel()
+
+	reminder, err := todoList.AddReminder(ctx, id, request.At)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reminder)
+}
+
+func deleteReminderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTodoID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	reminderID, err := strconv.Atoi(r.PathValue("reminder"))
+	if err != nil {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid reminder ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("reminders"))
+	defer cancel()
+
+	if err := todoList.DeleteReminder(ctx, id, reminderID); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"todoapp/5/storage"
+	"todoapp/5/websocket"
+)
+
+// socketUpgrader answers WebSocket handshakes, from the configured origins besides the
+// server's own
+var socketUpgrader = &websocket.Upgrader{}
+
+// Connections are pinged every socketPingInterval and closed when nothing arrives for
+// socketReadTimeout, so dead peers don't hold a subscription forever.
+var (
+	socketPingInterval = 30 * time.Second
+	socketReadTimeout  = 75 * time.Second
+)
+
+// socketCommand is a change requested by a WebSocket client. Ref is chosen by the
+// client and echoed in the reply so it can match replies to commands.
+type socketCommand struct {
+	Ref string `json:"ref,omitempty"`
+	storage.BatchOp
+}
+
+// socketMessage is sent to WebSocket clients: a change event, or the result or error
+// of one of their commands.
+type socketMessage struct {
+	Type  string          `json:"type"` // event, result or error
+	Ref   string          `json:"ref,omitempty"`
+	Event *storage.Event  `json:"event,omitempty"`
+	ID    int             `json:"id,omitempty"`
+	Todo  *storage.Todo   `json:"todo,omitempty"`
+	Error *ProblemDetails `json:"error,omitempty"`
+}
+
+// todoSocketHandler serves GET /todos/ws: the connection receives every change event
+// and may send create, update and delete commands as JSON text messages.
+func todoSocketHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := socketUpgrader.Upgrade(w, r)
+	if err != nil {
+		var handshakeErr *websocket.HandshakeError
+		if errors.As(err, &handshakeErr) {
+			writeStatusProblem(w, r, handshakeErr.Status, handshakeErr.Message)
+		}
+		return
+	}
+	defer conn.Close()
+	conn.ReadTimeout = socketReadTimeout
+
+	// done is closed before sub.Close runs, so the event writer can tell a feed that ended
+	// because the client left from one that dropped it for falling behind
+	sub := todoList.Events.Subscribe()
+	defer sub.Close()
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(socketPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if conn.Ping(nil) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		for event := range sub.Events() {
+			if writeSocketMessage(conn, socketMessage{Type: "event", Event: event}) != nil {
+				return
+			}
+		}
+		select {
+		case <-done:
+		default:
+			// Dropped for falling behind: the client should reconnect and reload
+			conn.WriteClose(websocket.CloseTryAgainLater, "too far behind the change feed")
+		}
+	}()
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var command socketCommand
+		if messageType != websocket.TextMessage || json.Unmarshal(data, &command) != nil {
+			writeSocketMessage(conn, socketError(r, "", storage.NewInvalidInputError("Commands must be JSON text messages")))
+			continue
+		}
+		writeSocketMessage(conn, runSocketCommand(r, command))
+	}
+}
+
+// runSocketCommand applies a command with the same validation as the REST handlers.
+func runSocketCommand(r *http.Request, command socketCommand) socketMessage {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For(string(command.Op)))
+	defer cancel()
+
+	reply := socketMessage{Type: "result", Ref: command.Ref, ID: command.ID}
+	var err error
+	switch command.Op {
+	case storage.BatchCreate:
+		if err = storage.ValidateTodo(command.Todo()); err == nil {
+			reply.Todo, err = todoList.CreateTodo(ctx, command.Todo())
+		}
+	case storage.BatchUpdate:
+		todo := command.Todo()
+		if err = storage.ValidateTodo(todo); err == nil {
+			err = todoList.UpdateTodoByID(ctx, command.ID, todo)
+			reply.Todo = todo
+		}
+	case storage.BatchDelete:
+		err = todoList.DeleteTodoByIDAtVersion(ctx, command.ID, command.Version)
+	default:
+		err = storage.NewInvalidInputError("Unknown command " + string(command.Op))
+	}
+	if err != nil {
+		return socketError(r, command.Ref, err)
+	}
+	if reply.Todo != nil {
+		reply.ID = reply.Todo.ID
+	}
+	return reply
+}
+
+func socketError(r *http.Request, ref string, err error) socketMessage {
+	problem := problemFor(r, err)
+	return socketMessage{Type: "error", Ref: ref, Error: &problem}
+}
+
+func writeSocketMessage(conn *websocket.Conn, message socketMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
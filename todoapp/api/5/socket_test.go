@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"todoapp/5/websocket"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoSocket_ReadTimeoutClosesNormally(t *testing.T) {
+	server := newTestServer(t)
+	readTimeout := socketReadTimeout
+	socketReadTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { socketReadTimeout = readTimeout })
+
+	conn, _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http")+"/todos/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// A silent client is closed normally, not told it fell behind the change feed
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.True(t, errors.As(err, &closeErr), "%v", err)
+	assert.Equal(t, websocket.CloseNormal, closeErr.Code)
+}
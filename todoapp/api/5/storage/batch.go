@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxBatchSize bounds the number of operations in one batch
+const MaxBatchSize = 100
+
+// BatchOpType names the change a batch operation makes
+type BatchOpType string
+
+// Batch operation types
+const (
+	BatchCreate BatchOpType = "create"
+	BatchUpdate BatchOpType = "update"
+	BatchDelete BatchOpType = "delete"
+)
+
+// BatchOp is one change in a batch. Create adds the todo described by the op, update
+// replaces the user-editable fields of todo ID with them, delete moves todo ID to the trash.
+// A non-zero Version makes an update or delete conditional, as in UpdateTodoByID.
+// OnDelete is applied to the subtasks of a deleted todo as in DeleteTodoTree, restrict
+// when empty; TodoList sets it from its SubtaskOptions.
+type BatchOp struct {
+	Op          BatchOpType `json:"op"`
+	ID          int         `json:"id,omitempty"`
+	ListID      int         `json:"list_id,omitempty"`
+	ParentID    int         `json:"parent_id,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Completed   bool        `json:"completed,omitempty"`
+	DueDate     *time.Time  `json:"due_date,omitempty"`
+	Priority    Priority    `json:"priority,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	Recurrence  string      `json:"recurrence,omitempty"`
+	Version     int         `json:"version,omitempty"`
+	OnDelete    DeleteRule  `json:"-"`
+}
+
+// Todo returns the todo a create or update operation writes.
+func (op BatchOp) Todo() *Todo {
+	return &Todo{
+		ID:          op.ID,
+		ListID:      op.ListID,
+		ParentID:    op.ParentID,
+		Description: op.Description,
+		Completed:   op.Completed,
+		DueDate:     op.DueDate,
+		Priority:    op.Priority,
+		Tags:        op.Tags,
+		Recurrence:  op.Recurrence,
+		Version:     op.Version,
+	}
+}
+
+// BatchResult is the outcome of one operation of an applied batch: the created or
+// updated todo, or only the ID of a deleted one and the subtasks its delete changed.
+type BatchResult struct {
+	Op       BatchOpType     `json:"op"`
+	ID       int             `json:"id"`
+	Todo     *Todo           `json:"todo,omitempty"`
+	Subtasks []SubtaskChange `json:"-"`
+}
+
+// validateBatch checks the shape of every operation before a store starts applying them.
+func validateBatch(ops []BatchOp) error {
+	if len(ops) == 0 {
+		return NewInvalidInputError("Batch must contain at least one operation")
+	}
+	if len(ops) > MaxBatchSize {
+		return NewInvalidInputError(fmt.Sprintf("Batch must contain at most %d operations", MaxBatchSize))
+	}
+	for i, op := range ops {
+		var err error
+		switch op.Op {
+		case BatchCreate:
+			err = ValidateTodo(op.Todo())
+		case BatchUpdate:
+			if op.ID <= 0 {
+				err = NewInvalidInputError("Update requires a todo ID")
+			} else {
+				err = ValidateTodo(op.Todo())
+			}
+		case BatchDelete:
+			if op.ID <= 0 {
+				err = NewInvalidInputError("Delete requires a todo ID")
+			}
+		default:
+			err = NewInvalidInputError(fmt.Sprintf("Unknown operation %q", op.Op))
+		}
+		if err != nil {
+			return batchOpError(i, op, err)
+		}
+	}
+	return nil
+}
+
+// batchOpError reports which operation made a batch fail. It keeps the code of the
+// operation's error and qualifies its field errors with the operation index.
+func batchOpError(index int, op BatchOp, err error) error {
+	cause := Classify(err)
+	batchErr := &TodoError{
+		Code:    cause.Code,
+		Message: fmt.Sprintf("Batch operation %d (%s) failed", index, op.Op),
+		Err:     err,
+	}
+	for _, field := range cause.Fields {
+		field.Field = fmt.Sprintf("operations[%d].%s", index, field.Field)
+		batchErr.Fields = append(batchErr.Fields, field)
+	}
+	return batchErr
+}
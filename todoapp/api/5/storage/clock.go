@@ -0,0 +1,26 @@
+package storage
+
+import "time"
+
+// Clock tells the current time. Stores and background workers take a Clock
+// so time-dependent behaviour can be tested without sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now().UTC()
+}
+
+// SystemClock is the Clock backed by the real time, used when none is configured.
+var SystemClock Clock = systemClock{}
+
+// nowFrom returns the time from the given clock, falling back to the system clock.
+func nowFrom(clock Clock) time.Time {
+	if clock == nil {
+		return SystemClock.Now()
+	}
+	return clock.Now().UTC()
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec reads and writes todos in one file format.
+type Codec interface {
+	MediaType() string    // The media type the format is served as, such as text/csv
+	Extensions() []string // File extensions with their dot, the preferred one first
+	Encode(w io.Writer, todos []*Todo) error
+	Decode(r io.Reader) ([]*Todo, error)
+}
+
+// ListAware is implemented by codecs that name the lists of todos, such as Markdown
+// headings. WithLists returns a copy of the codec that knows the lists of the store and,
+// when create isn't nil, creates the lists an import names that don't exist yet.
+type ListAware interface {
+	WithLists(lists []*List, create func(name string) (*List, error)) Codec
+}
+
+// CodecProvider is implemented by StorageIOs that read and write more formats than JSON.
+type CodecProvider interface {
+	Codecs() *CodecRegistry
+}
+
+// CodecRegistry finds the codec of a file by its extension and the codec of a request by
+// its media type. A codec registered later wins over earlier ones for the same extension
+// or media type.
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	codecs []Codec
+}
+
+// NewCodecRegistry returns a registry of the codecs.
+func NewCodecRegistry(codecs ...Codec) *CodecRegistry {
+	registry := &CodecRegistry{}
+	for _, codec := range codecs {
+		registry.Register(codec)
+	}
+	return registry
+}
+
+// DefaultCodecs returns a registry of the built-in formats: JSON, iCalendar, CSV,
+// tab-separated values, todo.txt and Markdown.
+func DefaultCodecs() *CodecRegistry {
+	return NewCodecRegistry(&JSONCodec{}, ICalendarCodec{}, &CSVCodec{}, &CSVCodec{Delimiter: '\t'},
+		TodoTxtCodec{}, &MarkdownCodec{})
+}
+
+// Register adds a codec.
+func (r *CodecRegistry) Register(codec Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codecs = append(r.codecs, codec)
+}
+
+// Codecs returns the registered codecs, latest first.
+func (r *CodecRegistry) Codecs() []Codec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	codecs := make([]Codec, 0, len(r.codecs))
+	for i := len(r.codecs) - 1; i >= 0; i-- {
+		codecs = append(codecs, r.codecs[i])
+	}
+	return codecs
+}
+
+// ForPath returns the codec for the extension of a file name, ignoring case.
+func (r *CodecRegistry) ForPath(path string) (Codec, bool) {
+	ext := filepath.Ext(path)
+	if ext == "" {
+		return nil, false
+	}
+	for _, codec := range r.Codecs() {
+		for _, candidate := range codec.Extensions() {
+			if strings.EqualFold(ext, candidate) {
+				return codec, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ForMediaType returns the codec for a Content-Type such as "text/csv; charset=utf-8".
+func (r *CodecRegistry) ForMediaType(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	for _, codec := range r.Codecs() {
+		if codec.MediaType() == mediaType {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// Negotiate returns the codec that best matches an Accept header, honouring quality
+// values and wildcards; among equally good media ranges the one listed first wins.
+// Wildcards and an empty header go to the codec registered first, JSON for DefaultCodecs.
+func (r *CodecRegistry) Negotiate(accept string) (Codec, bool) {
+	r.mu.RLock()
+	registered := append([]Codec(nil), r.codecs...)
+	r.mu.RUnlock()
+	if len(registered) == 0 {
+		return nil, false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return registered[0], true
+	}
+
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, mediaRange{mediaType, quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+
+	for _, accepted := range ranges {
+		if !strings.HasSuffix(accepted.mediaType, "/*") {
+			if codec, ok := r.ForMediaType(accepted.mediaType); ok {
+				return codec, true
+			}
+			continue
+		}
+		prefix := strings.TrimSuffix(accepted.mediaType, "*")
+		for _, codec := range registered {
+			if prefix == "*/" || strings.HasPrefix(codec.MediaType(), prefix) {
+				return codec, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// JSONCodec reads and writes todos as a JSON array, through IO when it is set so that
+// Download and Upload keep using StorageIOInterface.EncodeJSON and DecodeJSON.
+type JSONCodec struct {
+	IO StorageIOInterface
+}
+
+func (c *JSONCodec) MediaType() string    { return "application/json" }
+func (c *JSONCodec) Extensions() []string { return []string{".json"} }
+
+func (c *JSONCodec) Encode(w io.Writer, todos []*Todo) error {
+	if c.IO != nil {
+		return c.IO.EncodeJSON(w, todos)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(todos)
+}
+
+func (c *JSONCodec) Decode(r io.Reader) ([]*Todo, error) {
+	var todos []*Todo
+	var err error
+	if c.IO != nil {
+		err = c.IO.DecodeJSON(r, &todos)
+	} else {
+		err = json.NewDecoder(r).Decode(&todos)
+	}
+	return todos, err
+}
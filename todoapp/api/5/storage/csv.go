@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVColumns are the columns CSVCodec writes, in order, and the ones it reads back.
+var CSVColumns = []string{
+	"id", "list_id", "parent_id", "description", "completed", "due_date", "priority",
+	"tags", "recurrence", "created_at", "updated_at", "completed_at",
+}
+
+// CSVCodec reads and writes todos as CSV with a header row, for spreadsheets. Fields are
+// quoted when they contain the delimiter, quotes or line breaks, tags are separated by
+// spaces and times are RFC 3339. On import the columns are found by their header, so
+// they can come in any order and unknown ones are ignored; only description is required.
+type CSVCodec struct {
+	Delimiter rune // A comma when zero, a tab is written as text/tab-separated-values
+
+	// Columns maps headers of imported files onto CSVColumns, ignoring case, such as
+	// "Task" to "description". Other headers are matched against CSVColumns directly,
+	// with spaces read as underscores.
+	Columns map[string]string
+}
+
+func (c *CSVCodec) delimiter() rune {
+	if c.Delimiter == 0 {
+		return ','
+	}
+	return c.Delimiter
+}
+
+func (c *CSVCodec) MediaType() string {
+	if c.delimiter() == '\t' {
+		return "text/tab-separated-values"
+	}
+	return "text/csv"
+}
+
+func (c *CSVCodec) Extensions() []string {
+	if c.delimiter() == '\t' {
+		return []string{".tsv", ".tab"}
+	}
+	return []string{".csv"}
+}
+
+func (c *CSVCodec) Encode(w io.Writer, todos []*Todo) error {
+	out := csv.NewWriter(w)
+	out.Comma = c.delimiter()
+	if err := out.Write(CSVColumns); err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		record := []string{
+			strconv.Itoa(todo.ID),
+			strconv.Itoa(todo.ListID),
+			csvInt(todo.ParentID),
+			todo.Description,
+			strconv.FormatBool(todo.Completed),
+			csvTime(todo.DueDate),
+			"",
+			strings.Join(todo.Tags, " "),
+			todo.Recurrence,
+			csvTime(&todo.CreatedAt),
+			csvTime(&todo.UpdatedAt),
+			csvTime(todo.CompletedAt),
+		}
+		if todo.Priority != PriorityNone {
+			record[6] = todo.Priority.String()
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func (c *CSVCodec) Decode(r io.Reader) ([]*Todo, error) {
+	in := csv.NewReader(r)
+	in.Comma = c.delimiter()
+	in.FieldsPerRecord = -1 // Spreadsheets drop trailing empty cells
+	header, err := in.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV has no header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // The byte order mark spreadsheets write
+		}
+		if column := c.column(name); column != "" {
+			if _, seen := columns[column]; !seen {
+				columns[column] = i
+			}
+		}
+	}
+	if _, ok := columns["description"]; !ok {
+		return nil, errors.New("CSV has no description column")
+	}
+
+	todos := []*Todo{}
+	for {
+		record, err := in.Read()
+		if errors.Is(err, io.EOF) {
+			return todos, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+		line, _ := in.FieldPos(0)
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		todo, err := csvTodo(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		todos = append(todos, todo)
+	}
+}
+
+// column returns the name in CSVColumns a header stands for, or "" for unknown headers.
+func (c *CSVCodec) column(header string) string {
+	header = strings.TrimSpace(header)
+	for from, to := range c.Columns {
+		if strings.EqualFold(header, from) {
+			return to
+		}
+	}
+	header = strings.ToLower(strings.ReplaceAll(header, " ", "_"))
+	for _, column := range CSVColumns {
+		if header == column {
+			return column
+		}
+	}
+	return ""
+}
+
+// csvTodo builds a todo from the cells of one row, given by column name.
+func csvTodo(value func(column string) string) (*Todo, error) {
+	todo := &Todo{Description: value("description")}
+	var err error
+	for column, field := range map[string]*int{"id": &todo.ID, "list_id": &todo.ListID, "parent_id": &todo.ParentID} {
+		if text := value(column); text != "" {
+			if *field, err = strconv.Atoi(text); err != nil {
+				return nil, fmt.Errorf("%s %q is not a number", column, text)
+			}
+		}
+	}
+	if text := value("completed"); text != "" {
+		if todo.Completed, err = strconv.ParseBool(text); err != nil {
+			return nil, fmt.Errorf("completed %q is not true or false", text)
+		}
+	}
+	if text := value("priority"); text != "" {
+		if todo.Priority, err = ParsePriority(text); err != nil {
+			return nil, err
+		}
+	}
+	todo.Tags = strings.FieldsFunc(value("tags"), func(r rune) bool { return r == ' ' || r == ',' })
+	if len(todo.Tags) == 0 {
+		todo.Tags = nil
+	}
+	todo.Recurrence = value("recurrence")
+
+	for column, field := range map[string]**time.Time{"due_date": &todo.DueDate, "completed_at": &todo.CompletedAt} {
+		if *field, err = parseCSVTime(column, value(column)); err != nil {
+			return nil, err
+		}
+	}
+	for column, field := range map[string]*time.Time{"created_at": &todo.CreatedAt, "updated_at": &todo.UpdatedAt} {
+		parsed, err := parseCSVTime(column, value(column))
+		if err != nil {
+			return nil, err
+		}
+		if parsed != nil {
+			*field = *parsed
+		}
+	}
+	return todo, nil
+}
+
+// parseCSVTime reads an RFC 3339 time or a date, nil when the cell is empty.
+func parseCSVTime(column, text string) (*time.Time, error) {
+	if text == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			return &parsed, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %q is not a date", column, text)
+}
+
+func csvInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
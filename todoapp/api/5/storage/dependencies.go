@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dependency records that a todo is blocked by another: it can't be completed while
+// its blocker is open. The dependencies of all todos form a directed acyclic graph.
+type Dependency struct {
+	TodoID    int `json:"todo_id"`
+	BlockerID int `json:"blocker_id"`
+}
+
+// Dependencies lists the todos around one todo in the dependency graph, ordered by ID.
+// Trashed todos are left out.
+type Dependencies struct {
+	BlockedBy []*Todo `json:"blocked_by"` // The todos it waits for, open or not
+	Blocks    []*Todo `json:"blocks"`     // The todos waiting for it
+}
+
+// DependencyStore defines storage operations for dependencies. Dependencies on trashed
+// todos are kept, so they apply again once the todo is restored, and don't block anything
+// in the meantime. Purging a todo drops its dependencies.
+type DependencyStore interface {
+	// AddDependency makes todoID blocked by blockerID. Adding an existing dependency is a
+	// no-op, one that would close a cycle fails with ErrDependencyCycle.
+	AddDependency(ctx context.Context, todoID, blockerID int) error
+	RemoveDependency(ctx context.Context, todoID, blockerID int) error
+	GetDependencies(ctx context.Context, id int) (*Dependencies, error)
+	// ListDependencies returns every dependency between todos outside the trash
+	ListDependencies(ctx context.Context) ([]Dependency, error)
+}
+
+// formatIDs lists IDs for error messages, e.g. "1, 4".
+func formatIDs(ids []int) string {
+	formatted := make([]string, len(ids))
+	for i, id := range ids {
+		formatted[i] = strconv.Itoa(id)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// topologicalOrder orders the open todos so each one comes after the open todos blocking
+// it, taking the lowest ID first whenever several are ready (Kahn's algorithm).
+func topologicalOrder(todos []*Todo, dependencies []Dependency) ([]*Todo, error) {
+	open := map[int]*Todo{}
+	for _, todo := range todos {
+		if !todo.Completed {
+			open[todo.ID] = todo
+		}
+	}
+	waiting := map[int]int{} // Open blockers left per todo
+	blocks := map[int][]int{}
+	for _, dependency := range dependencies {
+		if open[dependency.TodoID] != nil && open[dependency.BlockerID] != nil {
+			waiting[dependency.TodoID]++
+			blocks[dependency.BlockerID] = append(blocks[dependency.BlockerID], dependency.TodoID)
+		}
+	}
+
+	var ready []int
+	for id := range open {
+		if waiting[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+	sort.Ints(ready)
+
+	ordered := make([]*Todo, 0, len(open))
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, open[id])
+		for _, blocked := range blocks[id] {
+			if waiting[blocked]--; waiting[blocked] == 0 {
+				i := sort.SearchInts(ready, blocked)
+				ready = append(ready[:i], append([]int{blocked}, ready[i:]...)...)
+			}
+		}
+	}
+	// The stores reject cycles, so this only happens if the graph was corrupted
+	if len(ordered) < len(open) {
+		return nil, &TodoError{Code: ErrDependencyCycle, Message: "The dependencies between open todos form a cycle"}
+	}
+	return ordered, nil
+}
+
+// AddDependency makes a todo blocked by another.
+func (t *TodoList) AddDependency(ctx context.Context, todoID, blockerID int) error {
+	t.Logger.Info("Adding a dependency", "id", todoID, "blocker", blockerID)
+	if err := t.Store.AddDependency(ctx, todoID, blockerID); err != nil {
+		t.Logger.Error("Failed to add dependency", "error", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveDependency unblocks a todo from another.
+func (t *TodoList) RemoveDependency(ctx context.Context, todoID, blockerID int) error {
+	t.Logger.Info("Removing a dependency", "id", todoID, "blocker", blockerID)
+	return t.Store.RemoveDependency(ctx, todoID, blockerID)
+}
+
+// GetDependencies retrieves the todos blocking a todo and the todos it blocks.
+func (t *TodoList) GetDependencies(ctx context.Context, id int) (*Dependencies, error) {
+	t.Logger.Info("Getting dependencies", "id", id)
+	return t.Store.GetDependencies(ctx, id)
+}
+
+// OrderTodos returns the open todos in an order they can be done in: every todo comes
+// after the open todos blocking it.
+func (t *TodoList) OrderTodos(ctx context.Context) ([]*Todo, error) {
+	t.Logger.Info("Ordering open todos")
+	todos, err := t.Store.GetAllTodos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dependencies, err := t.Store.ListDependencies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return topologicalOrder(todos, dependencies)
+}
+
+// openBlockers returns the IDs of the open todos outside the trash blocking id, in order.
+func (tx *memoryTxn) openBlockers(id int) []int {
+	var open []int
+	for blockerID := range tx.store.blockers[id] {
+		if blocker, exists := tx.live(blockerID); exists && !blocker.Completed {
+			open = append(open, blockerID)
+		}
+	}
+	sort.Ints(open)
+	return open
+}
+
+// checkUnblocked refuses to complete current while todos blocking it are open.
+func (tx *memoryTxn) checkUnblocked(current, updated *Todo) error {
+	if !updated.Completed || current.Completed {
+		return nil
+	}
+	if open := tx.openBlockers(current.ID); len(open) > 0 {
+		return NewTodoBlockedError(current.ID, open)
+	}
+	return nil
+}
+
+// AddDependency makes todoID blocked by blockerID.
+func (s *InMemoryStore) AddDependency(ctx context.Context, todoID, blockerID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if todoID == blockerID {
+		return NewInvalidInputError("A todo can't be blocked by itself")
+	}
+	for _, id := range []int{todoID, blockerID} {
+		if _, exists := s.live(id); !exists {
+			return NewTodoNotFoundError(id)
+		}
+	}
+	if s.blockers[todoID][blockerID] {
+		return nil
+	}
+
+	// The new dependency closes a cycle if the blocker already waits for the todo
+	seen := map[int]bool{}
+	pending := []int{blockerID}
+	for len(pending) > 0 {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if id == todoID {
+			return NewDependencyCycleError(todoID, blockerID)
+		}
+		for upstream := range s.blockers[id] {
+			if !seen[upstream] {
+				seen[upstream] = true
+				pending = append(pending, upstream)
+			}
+		}
+	}
+
+	if s.blockers[todoID] == nil {
+		s.blockers[todoID] = map[int]bool{}
+	}
+	s.blockers[todoID][blockerID] = true
+	return nil
+}
+
+// RemoveDependency deletes the dependency of todoID on blockerID.
+func (s *InMemoryStore) RemoveDependency(ctx context.Context, todoID, blockerID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.blockers[todoID][blockerID] {
+		return NewDependencyNotFoundError(todoID, blockerID)
+	}
+	delete(s.blockers[todoID], blockerID)
+	return nil
+}
+
+// GetDependencies retrieves the live todos around a todo in the dependency graph.
+func (s *InMemoryStore) GetDependencies(ctx context.Context, id int) (*Dependencies, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.live(id); !exists {
+		return nil, NewTodoNotFoundError(id)
+	}
+	dependencies := &Dependencies{BlockedBy: []*Todo{}, Blocks: []*Todo{}}
+	for blockerID := range s.blockers[id] {
+		if blocker, exists := s.live(blockerID); exists {
+			dependencies.BlockedBy = append(dependencies.BlockedBy, blocker)
+		}
+	}
+	for todoID, blockers := range s.blockers {
+		if blocked, exists := s.live(todoID); exists && blockers[id] {
+			dependencies.Blocks = append(dependencies.Blocks, blocked)
+		}
+	}
+	sortByID(dependencies.BlockedBy)
+	sortByID(dependencies.Blocks)
+	return dependencies, nil
+}
+
+// ListDependencies returns the dependencies between live todos.
+func (s *InMemoryStore) ListDependencies(ctx context.Context) ([]Dependency, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dependencies := []Dependency{}
+	for todoID, blockers := range s.blockers {
+		if _, exists := s.live(todoID); !exists {
+			continue
+		}
+		for blockerID := range blockers {
+			if _, exists := s.live(blockerID); exists {
+				dependencies = append(dependencies, Dependency{TodoID: todoID, BlockerID: blockerID})
+			}
+		}
+	}
+	return dependencies, nil
+}
+
+// forgetDependencies drops the dependencies of a purged todo. The store mutex must be held.
+func (s *InMemoryStore) forgetDependencies(id int) {
+	delete(s.blockers, id)
+	for _, blockers := range s.blockers {
+		delete(blockers, id)
+	}
+}
+
+func sortByID(todos []*Todo) {
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID < todos[j].ID })
+}
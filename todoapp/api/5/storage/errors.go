@@ -1,14 +1,25 @@
 package storage
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 // Error types for todo operations
 type TodoError struct {
-	Code    string
+	Code    ErrorCode
 	Message string
+	Fields  []FieldError // Per-field reasons for validation failures
 	Err     error
 }
 
+// FieldError explains why a single input field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
 func (e *TodoError) Error() string {
 	if e.Err != nil {
 		return fmt.Sprintf("%s: %s", e.Message, e.Err.Error())
@@ -16,15 +27,81 @@ func (e *TodoError) Error() string {
 	return e.Message
 }
 
+// Unwrap exposes the underlying error to errors.Is and errors.As.
+func (e *TodoError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes every TodoError match the sentinel of its code,
+// e.g. errors.Is(err, ErrTodoNotFound).
+func (e *TodoError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && code == e.Code
+}
+
+// ErrorCode classifies a TodoError. Each code doubles as a sentinel error for errors.Is.
+type ErrorCode string
+
+func (c ErrorCode) Error() string {
+	return string(c)
+}
+
 // Error codes
 const (
-	ErrTodoNotFound     = "TODO_NOT_FOUND"
-	ErrInvalidInput     = "INVALID_INPUT"
-	ErrStorageError     = "STORAGE_ERROR"
-	ErrDuplicateTodo    = "DUPLICATE_TODO"
-	ErrOperationTimeout = "OPERATION_TIMEOUT"
+	ErrTodoNotFound       ErrorCode = "TODO_NOT_FOUND"
+	ErrInvalidInput       ErrorCode = "INVALID_INPUT"
+	ErrStorageError       ErrorCode = "STORAGE_ERROR"
+	ErrDuplicateTodo      ErrorCode = "DUPLICATE_TODO"
+	ErrOperationTimeout   ErrorCode = "OPERATION_TIMEOUT"
+	ErrMigrationFailed    ErrorCode = "MIGRATION_FAILED"
+	ErrVersionConflict    ErrorCode = "VERSION_CONFLICT"
+	ErrPatchConflict      ErrorCode = "PATCH_CONFLICT" // A JSON Patch test operation failed
+	ErrWebhookNotFound    ErrorCode = "WEBHOOK_NOT_FOUND"
+	ErrListNotFound       ErrorCode = "LIST_NOT_FOUND"
+	ErrDuplicateList      ErrorCode = "DUPLICATE_LIST"
+	ErrListNotEmpty       ErrorCode = "LIST_NOT_EMPTY" // Only empty lists can be deleted
+	ErrHasSubtasks        ErrorCode = "HAS_SUBTASKS"   // The todo's subtasks have to be moved or deleted first
+	ErrDependencyNotFound ErrorCode = "DEPENDENCY_NOT_FOUND"
+	ErrDependencyCycle    ErrorCode = "DEPENDENCY_CYCLE"
+	ErrTodoBlocked        ErrorCode = "TODO_BLOCKED" // Completing the todo waits for open blockers
+	ErrReminderNotFound   ErrorCode = "REMINDER_NOT_FOUND"
+	ErrNotAcceptable      ErrorCode = "NOT_ACCEPTABLE"  // No codec writes any of the accepted media types
+	ErrImportRejected     ErrorCode = "IMPORT_REJECTED" // None of the uploaded todos could be created
 )
 
+// Classify returns the TodoError that best describes err: the first error in its chain
+// with a code more specific than ErrStorageError, an ErrOperationTimeout for expired
+// contexts, and otherwise a storage error.
+func Classify(err error) *TodoError {
+	if err == nil {
+		return nil
+	}
+
+	var outermost *TodoError
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		todoErr, ok := e.(*TodoError)
+		if !ok {
+			continue
+		}
+		if todoErr.Code != ErrStorageError {
+			return todoErr
+		}
+		if outermost == nil {
+			outermost = todoErr
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		timeoutErr := NewOperationTimeoutError()
+		timeoutErr.Err = err
+		return timeoutErr
+	}
+	if outermost != nil {
+		return outermost
+	}
+	return NewStorageError(err)
+}
+
 // Helper functions to create specific errors
 func NewTodoNotFoundError(id int) *TodoError {
 	return &TodoError{
@@ -40,6 +117,14 @@ func NewInvalidInputError(message string) *TodoError {
 	}
 }
 
+func NewValidationError(fields ...FieldError) *TodoError {
+	return &TodoError{
+		Code:    ErrInvalidInput,
+		Message: "Validation failed",
+		Fields:  fields,
+	}
+}
+
 func NewStorageError(err error) *TodoError {
 	return &TodoError{
 		Code:    ErrStorageError,
@@ -55,9 +140,97 @@ func NewDuplicateTodoError(description string) *TodoError {
 	}
 }
 
+func NewVersionConflictError(id, expected, actual int) *TodoError {
+	return &TodoError{
+		Code:    ErrVersionConflict,
+		Message: fmt.Sprintf("Todo with ID %d is at version %d, not %d", id, actual, expected),
+	}
+}
+
 func NewOperationTimeoutError() *TodoError {
 	return &TodoError{
 		Code:    ErrOperationTimeout,
 		Message: "Operation timed out",
 	}
 }
+
+func NewListNotFoundError(id int) *TodoError {
+	return &TodoError{
+		Code:    ErrListNotFound,
+		Message: fmt.Sprintf("List with ID %d not found", id),
+	}
+}
+
+func NewDuplicateListError(name string) *TodoError {
+	return &TodoError{
+		Code:    ErrDuplicateList,
+		Message: fmt.Sprintf("List with name '%s' already exists", name),
+	}
+}
+
+func NewListNotEmptyError(id, todos int) *TodoError {
+	return &TodoError{
+		Code:    ErrListNotEmpty,
+		Message: fmt.Sprintf("List with ID %d still has %d todos", id, todos),
+	}
+}
+
+func NewHasSubtasksError(id, subtasks int) *TodoError {
+	return &TodoError{
+		Code:    ErrHasSubtasks,
+		Message: fmt.Sprintf("Todo with ID %d has %d subtasks", id, subtasks),
+	}
+}
+
+func NewDependencyNotFoundError(todoID, blockerID int) *TodoError {
+	return &TodoError{
+		Code:    ErrDependencyNotFound,
+		Message: fmt.Sprintf("Todo with ID %d is not blocked by todo %d", todoID, blockerID),
+	}
+}
+
+func NewDependencyCycleError(todoID, blockerID int) *TodoError {
+	return &TodoError{
+		Code:    ErrDependencyCycle,
+		Message: fmt.Sprintf("Todo with ID %d can't be blocked by todo %d, which already waits for it", todoID, blockerID),
+	}
+}
+
+// NewTodoBlockedError refuses to complete a todo, naming the open todos blocking it.
+func NewTodoBlockedError(id int, blockers []int) *TodoError {
+	return &TodoError{
+		Code:    ErrTodoBlocked,
+		Message: fmt.Sprintf("Todo with ID %d is blocked by open todos %s", id, formatIDs(blockers)),
+		Fields:  []FieldError{{Field: "completed", Message: "blocked by open todos " + formatIDs(blockers)}},
+	}
+}
+
+func NewReminderNotFoundError(todoID, id int) *TodoError {
+	return &TodoError{
+		Code:    ErrReminderNotFound,
+		Message: fmt.Sprintf("Todo with ID %d has no reminder %d", todoID, id),
+	}
+}
+
+func NewNotAcceptableError(accept string) *TodoError {
+	return &TodoError{
+		Code:    ErrNotAcceptable,
+		Message: fmt.Sprintf("Todos can't be written as any of %q", accept),
+	}
+}
+
+func NewImportRejectedError(fields []FieldError) *TodoError {
+	return &TodoError{
+		Code:    ErrImportRejected,
+		Message: "None of the todos could be imported",
+		Fields:  fields,
+	}
+}
+
+func NewMigrationError(version int, message string, err error) *TodoError {
+	return &TodoError{
+		Code:    ErrMigrationFailed,
+		Message: fmt.Sprintf("Migration %d: %s", version, message),
+		Err:     err,
+	}
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType names the kind of change an Event reports
+type EventType string
+
+// Event types, one per ChangeAction
+const (
+	EventCreated  EventType = "created"
+	EventUpdated  EventType = "updated"
+	EventDeleted  EventType = "deleted"
+	EventRestored EventType = "restored"
+	EventPurged   EventType = "purged"
+)
+
+var eventTypes = map[ChangeAction]EventType{
+	ActionCreate:  EventCreated,
+	ActionUpdate:  EventUpdated,
+	ActionDelete:  EventDeleted,
+	ActionRestore: EventRestored,
+	ActionPurge:   EventPurged,
+}
+
+// Event is published on the EventBus after a change has been stored.
+// Todo is the todo after the change, nil for deletions and purges.
+type Event struct {
+	ID     int64     `json:"id"` // Increases by one with every event, assigned by the bus
+	Type   EventType `json:"type"`
+	TodoID int       `json:"todo_id"`
+	Todo   *Todo     `json:"todo,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// DefaultEventReplay is the number of recent events an EventBus keeps for resuming subscribers
+const DefaultEventReplay = 1000
+
+// subscriberBuffer is how many events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// EventBus fans events out to subscribers and keeps the most recent ones so a
+// subscriber that reconnects can resume where it stopped.
+type EventBus struct {
+	mu          sync.Mutex
+	nextID      int64
+	replay      []*Event // the most recent events, oldest first
+	replaySize  int
+	subscribers map[*Subscription]struct{}
+}
+
+// NewEventBus creates a bus that keeps up to replaySize events for resuming subscribers.
+func NewEventBus(replaySize int) *EventBus {
+	if replaySize <= 0 {
+		replaySize = DefaultEventReplay
+	}
+	return &EventBus{nextID: 1, replaySize: replaySize, subscribers: map[*Subscription]struct{}{}}
+}
+
+// Subscription receives the events published after it was created.
+type Subscription struct {
+	bus    *EventBus
+	events chan *Event
+}
+
+// Events delivers the events in order. It is closed by Close, or by the bus when the
+// subscriber falls too far behind; the subscriber can then resume from its last event.
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.unsubscribe(s)
+}
+
+// unsubscribe must be called with the bus mutex held.
+func (b *EventBus) unsubscribe(s *Subscription) {
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Publish assigns the event its ID and delivers it without blocking on slow subscribers.
+func (b *EventBus) Publish(event *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event.ID = b.nextID
+	b.nextID++
+	b.replay = append(b.replay, event)
+	if len(b.replay) > b.replaySize {
+		b.replay = b.replay[len(b.replay)-b.replaySize:]
+	}
+
+	for s := range b.subscribers {
+		select {
+		case s.events <- event:
+		default:
+			b.unsubscribe(s)
+		}
+	}
+}
+
+// Subscribe starts a subscription to the events published from now on.
+func (b *EventBus) Subscribe() *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribe()
+}
+
+// Resume starts a subscription for a subscriber whose last seen event was lastEventID.
+// It returns the buffered events published since then to be replayed before the
+// subscription's own events; complete is false when some of them had already been
+// evicted from the replay buffer.
+func (b *EventBus) Resume(lastEventID int64) (sub *Subscription, replay []*Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// An ID past the last published one comes from before a restart of the server
+	oldest := b.nextID
+	if len(b.replay) > 0 {
+		oldest = b.replay[0].ID
+	}
+	complete = lastEventID >= oldest-1 && lastEventID < b.nextID
+	for _, event := range b.replay {
+		if event.ID > lastEventID {
+			replay = append(replay, event)
+		}
+	}
+	return b.subscribe(), replay, complete
+}
+
+// subscribe must be called with the bus mutex held.
+func (b *EventBus) subscribe() *Subscription {
+	s := &Subscription{bus: b, events: make(chan *Event, subscriberBuffer)}
+	b.subscribers[s] = struct{}{}
+	return s
+}
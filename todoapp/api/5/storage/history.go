@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// ChangeAction names what happened to a todo in a Change
+type ChangeAction string
+
+// Change actions
+const (
+	ActionCreate  ChangeAction = "create"
+	ActionUpdate  ChangeAction = "update"
+	ActionDelete  ChangeAction = "delete"
+	ActionRestore ChangeAction = "restore"
+	ActionPurge   ChangeAction = "purge"
+)
+
+// Change is one entry of the audit history of a todo. Before and After are snapshots of
+// the todo around the change; Before is nil for creations, After for deletions and purges.
+type Change struct {
+	Seq       int64        `json:"seq"` // Position in the history, assigned by the HistoryStore
+	TodoID    int          `json:"todo_id"`
+	Action    ChangeAction `json:"action"`
+	Before    *Todo        `json:"before,omitempty"`
+	After     *Todo        `json:"after,omitempty"`
+	At        time.Time    `json:"at"`
+	Actor     string       `json:"actor,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// HistoryStore is an append-only log of changes.
+type HistoryStore interface {
+	// Append assigns the change its Seq and stores it
+	Append(ctx context.Context, change *Change) error
+	// ListChanges returns the recorded changes of a todo, oldest first
+	ListChanges(ctx context.Context, todoID int) ([]*Change, error)
+}
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	requestIDKey
+)
+
+// WithActor returns a context whose changes are recorded as made by actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFrom returns the actor set by WithActor, or "".
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+// WithRequestID returns a context whose changes are recorded with the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFrom returns the request ID set by WithRequestID, or "".
+func RequestIDFrom(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// write runs fn, which applies a change to the store and records it with changes. It holds
+// the write lock, so changes are recorded and published in the order they are applied. With a
+// Transactional store fn runs in a transaction, which makes the change fail and be undone when
+// its history can't be recorded; otherwise that failure is only logged. The events are
+// published once the change is committed.
+func (t *TodoList) write(ctx context.Context, fn func(ctx context.Context, changes *changeLog) error) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	changes := &changeLog{list: t}
+	var err error
+	if store, ok := t.Store.(Transactional); ok {
+		changes.atomic = true
+		err = store.Transaction(ctx, func(ctx context.Context) error { return fn(ctx, changes) })
+	} else {
+		err = fn(ctx, changes)
+	}
+	if err != nil {
+		return err
+	}
+	if t.Events != nil {
+		for _, event := range changes.events {
+			t.Events.Publish(event)
+		}
+	}
+	return nil
+}
+
+// changeLog records the changes of one write and holds their events until it is committed.
+type changeLog struct {
+	list   *TodoList
+	atomic bool // The write runs in a transaction the history joins
+	events []*Event
+}
+
+// record appends a change to the history and queues its event.
+func (c *changeLog) record(ctx context.Context, action ChangeAction, todoID int, before, after *Todo) error {
+	t := c.list
+	at := nowFrom(t.Clock)
+	if t.History != nil {
+		change := &Change{
+			TodoID:    todoID,
+			Action:    action,
+			Before:    snapshot(before),
+			After:     snapshot(after),
+			At:        at,
+			Actor:     ActorFrom(ctx),
+			RequestID: RequestIDFrom(ctx),
+		}
+		// Record the change even if the request timed out right after applying it
+		if err := t.History.Append(context.WithoutCancel(ctx), change); err != nil {
+			if c.atomic {
+				return err
+			}
+			t.Logger.Error("Failed to record todo history", "id", todoID, "action", action, "error", err)
+		}
+	}
+	c.events = append(c.events, &Event{Type: eventTypes[action], TodoID: todoID, Todo: snapshot(after), At: at})
+	return nil
+}
+
+func snapshot(todo *Todo) *Todo {
+	if todo == nil {
+		return nil
+	}
+	copied := *todo
+	return &copied
+}
+
+// TodoHistory returns the recorded changes of a todo, oldest first.
+// A todo without any recorded change is reported as not found.
+func (t *TodoList) TodoHistory(ctx context.Context, id int) ([]*Change, error) {
+	t.Logger.Info("Getting todo history", "id", id)
+	if t.History == nil {
+		return nil, NewTodoNotFoundError(id)
+	}
+	changes, err := t.History.ListChanges(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, NewTodoNotFoundError(id)
+	}
+	return changes, nil
+}
+
+// DefaultHistoryCapacity is the number of changes MemoryHistoryStore keeps queryable
+const DefaultHistoryCapacity = 10000
+
+// MemoryHistoryStore keeps the most recent changes in a ring buffer and, when it has
+// a file, also appends every change to it as a JSON line. Changes that fell out of the
+// ring buffer are only found in the file.
+type MemoryHistoryStore struct {
+	mu      sync.Mutex
+	changes []*Change // ring buffer, the oldest change is at next once it is full
+	next    int
+	full    bool
+	seq     int64
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewMemoryHistoryStore creates a history store holding up to capacity changes in memory.
+// If path is not empty every change is also appended to that file.
+func NewMemoryHistoryStore(capacity int, path string) (*MemoryHistoryStore, error) {
+	if capacity <= 0 {
+		capacity = DefaultHistoryCapacity
+	}
+	h := &MemoryHistoryStore{changes: make([]*Change, capacity)}
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, NewStorageError(err)
+		}
+		h.file = file
+		h.encoder = json.NewEncoder(file)
+	}
+	return h, nil
+}
+
+// Append records a change, writing it to the file before it becomes queryable.
+func (h *MemoryHistoryStore) Append(ctx context.Context, change *Change) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	change.Seq = h.seq
+	if h.encoder != nil {
+		if err := h.encoder.Encode(change); err != nil {
+			return NewStorageError(err)
+		}
+	}
+	h.changes[h.next] = change
+	h.next = (h.next + 1) % len(h.changes)
+	if h.next == 0 {
+		h.full = true
+	}
+	return nil
+}
+
+// ListChanges returns the changes of a todo still held in the ring buffer, oldest first.
+func (h *MemoryHistoryStore) ListChanges(ctx context.Context, todoID int) ([]*Change, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	start, count := 0, h.next
+	if h.full {
+		start, count = h.next, len(h.changes)
+	}
+	changes := []*Change{}
+	for i := 0; i < count; i++ {
+		if change := h.changes[(start+i)%len(h.changes)]; change.TodoID == todoID {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// Close closes the history file, if there is one.
+func (h *MemoryHistoryStore) Close() error {
+	if h.file == nil {
+		return nil
+	}
+	return h.file.Close()
+}
@@ -0,0 +1,403 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// iCalendar (RFC 5545) export and import. Each todo becomes a VTODO with SUMMARY, STATUS
+// and a UID derived from its ID, plus DUE, PRIORITY, CATEGORIES, RRULE and RELATED-TO
+// (the parent) when they apply. X-TODOAPP-LIST-ID keeps the list for our own round trips.
+
+const (
+	icalTimeLayout = "20060102T150405Z"
+	icalDateLayout = "20060102"
+	icalLineLength = 75 // Octets per line before folding, CRLF excluded
+	icalProductID  = "-//todoapp//todos//EN"
+)
+
+var icalUID = regexp.MustCompile(`^todo-(\d+)@todoapp$`)
+
+// icalPriorities maps priorities onto the 1 (highest) to 9 (lowest) scale of PRIORITY
+var icalPriorities = map[Priority]int{PriorityUrgent: 1, PriorityHigh: 3, PriorityMedium: 5, PriorityLow: 7}
+
+// ICalendarCodec is the Codec of EncodeICalendar and DecodeICalendar.
+type ICalendarCodec struct{}
+
+func (ICalendarCodec) MediaType() string                       { return "text/calendar" }
+func (ICalendarCodec) Extensions() []string                    { return []string{".ics", ".ical"} }
+func (ICalendarCodec) Encode(w io.Writer, todos []*Todo) error { return EncodeICalendar(w, todos) }
+func (ICalendarCodec) Decode(r io.Reader) ([]*Todo, error)     { return DecodeICalendar(r) }
+
+// TodoUID returns the iCalendar UID of a todo.
+func TodoUID(id int) string {
+	return fmt.Sprintf("todo-%d@todoapp", id)
+}
+
+// EncodeICalendar writes the todos as a VCALENDAR of VTODO components.
+func EncodeICalendar(w io.Writer, todos []*Todo) error {
+	out := &icalWriter{w: bufio.NewWriter(w)}
+	out.line("BEGIN", "VCALENDAR")
+	out.line("VERSION", "2.0")
+	out.line("PRODID", icalProductID)
+	out.line("CALSCALE", "GREGORIAN")
+	for _, todo := range todos {
+		out.line("BEGIN", "VTODO")
+		out.line("UID", TodoUID(todo.ID))
+		out.line("DTSTAMP", icalTime(todo.UpdatedAt))
+		if !todo.CreatedAt.IsZero() {
+			out.line("CREATED", icalTime(todo.CreatedAt))
+			out.line("LAST-MODIFIED", icalTime(todo.UpdatedAt))
+		}
+		out.line("SUMMARY", icalEscape(todo.Description))
+		if todo.Completed {
+			out.line("STATUS", "COMPLETED")
+			if todo.CompletedAt != nil {
+				out.line("COMPLETED", icalTime(*todo.CompletedAt))
+			}
+		} else {
+			out.line("STATUS", "NEEDS-ACTION")
+		}
+		if todo.DueDate != nil {
+			out.line("DUE", icalTime(*todo.DueDate))
+		}
+		if priority, ok := icalPriorities[todo.Priority]; ok {
+			out.line("PRIORITY", strconv.Itoa(priority))
+		}
+		if len(todo.Tags) > 0 {
+			tags := make([]string, len(todo.Tags))
+			for i, tag := range todo.Tags {
+				tags[i] = icalEscape(tag)
+			}
+			out.line("CATEGORIES", strings.Join(tags, ","))
+		}
+		if todo.Recurrence != "" {
+			out.line("RRULE", todo.Recurrence)
+		}
+		if todo.ParentID != 0 {
+			out.line("RELATED-TO", TodoUID(todo.ParentID))
+		}
+		if todo.ListID != 0 {
+			out.line("X-TODOAPP-LIST-ID", strconv.Itoa(todo.ListID))
+		}
+		out.line("END", "VTODO")
+	}
+	out.line("END", "VCALENDAR")
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+// icalWriter writes folded content lines, keeping the first error.
+type icalWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (out *icalWriter) line(name, value string) {
+	if out.err != nil {
+		return
+	}
+	line := name + ":" + value
+	// Continuation lines start with a space, which counts towards their length
+	for limit := icalLineLength; len(line) > limit; limit = icalLineLength - 1 {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, out.err = out.w.WriteString(line[:cut] + "\r\n "); out.err != nil {
+			return
+		}
+		line = line[cut:]
+	}
+	_, out.err = out.w.WriteString(line + "\r\n")
+}
+
+func icalTime(t time.Time) string {
+	return t.UTC().Format(icalTimeLayout)
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\r", `\n`, "\n", `\n`)
+
+func icalEscape(text string) string {
+	return icalEscaper.Replace(text)
+}
+
+// icalUnescape reverses icalEscape. Unknown escapes keep the escaped character.
+func icalUnescape(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+			if text[i] == 'n' || text[i] == 'N' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(text[i])
+			}
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// splitEscaped splits a TEXT list value on the commas that are not escaped.
+func splitEscaped(value string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// icalProperty is one unfolded content line.
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICalLine splits a content line into its name, parameters and value. Colons inside
+// quoted parameter values, as in TZID="Europe/Paris:1", don't end the name.
+func parseICalLine(line string) (*icalProperty, error) {
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return nil, fmt.Errorf("%q is not a content line", line)
+	}
+
+	property := &icalProperty{params: map[string]string{}, value: line[colon+1:]}
+	parts := strings.Split(line[:colon], ";")
+	property.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		name, value, _ := strings.Cut(param, "=")
+		property.params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+	}
+	return property, nil
+}
+
+// parseICalTime reads a DATE-TIME in UTC, in the zone named by TZID or floating, or a DATE.
+// Floating times and dates are taken as UTC.
+func parseICalTime(property *icalProperty) (time.Time, error) {
+	value := property.value
+	if property.params["VALUE"] == "DATE" || len(value) == len(icalDateLayout) {
+		return time.Parse(icalDateLayout, value)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(icalTimeLayout, value)
+	}
+	location := time.UTC
+	if tzid := property.params["TZID"]; tzid != "" {
+		if loaded, err := time.LoadLocation(tzid); err == nil {
+			location = loaded
+		}
+	}
+	t, err := time.ParseInLocation(strings.TrimSuffix(icalTimeLayout, "Z"), value, location)
+	return t.UTC(), err
+}
+
+// icalTodo is a VTODO being decoded, with the UIDs that link it to other todos.
+type icalTodo struct {
+	todo      *Todo
+	uid       string
+	relatedTo string
+}
+
+// DecodeICalendar reads the VTODO components of an iCalendar stream. Todos keep the ID
+// in a UID written by EncodeICalendar; other todos are numbered after the highest such
+// ID, so RELATED-TO links between them survive and Import can attach subtasks to their
+// parents. Other components, such as VEVENTs and the VALARMs of todos, are skipped.
+func DecodeICalendar(r io.Reader) ([]*Todo, error) {
+	lines, err := unfoldICal(r) // Errors count unfolded lines
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded []*icalTodo
+	var current *icalTodo
+	var components []string // Open components, innermost last
+	for i, line := range lines {
+		property, err := parseICalLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch property.name {
+		case "BEGIN":
+			name := strings.ToUpper(property.value)
+			if len(components) == 0 && name != "VCALENDAR" {
+				return nil, fmt.Errorf("line %d: expected BEGIN:VCALENDAR", i+1)
+			}
+			components = append(components, name)
+			if name == "VTODO" && len(components) == 2 {
+				current = &icalTodo{todo: &Todo{}}
+			}
+			continue
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(property.value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, property.value)
+			}
+			components = components[:len(components)-1]
+			if current != nil && len(components) == 1 {
+				decoded = append(decoded, current)
+				current = nil
+			}
+			continue
+		}
+		// Only the properties of the VTODO itself, not those of its VALARMs
+		if current == nil || len(components) != 2 {
+			continue
+		}
+		if err := current.set(property); err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", i+1, property.name, err)
+		}
+	}
+	if len(components) > 0 {
+		return nil, fmt.Errorf("END:%s is missing", components[len(components)-1])
+	}
+	return linkICalTodos(decoded), nil
+}
+
+// unfoldICal reads content lines, joining folded continuation lines and skipping blank ones.
+func unfoldICal(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// set applies one VTODO property to the todo being decoded.
+func (t *icalTodo) set(property *icalProperty) error {
+	todo := t.todo
+	switch property.name {
+	case "UID":
+		t.uid = property.value
+	case "SUMMARY":
+		todo.Description = icalUnescape(property.value)
+	case "STATUS":
+		todo.Completed = strings.EqualFold(property.value, "COMPLETED")
+	case "DUE":
+		due, err := parseICalTime(property)
+		if err != nil {
+			return err
+		}
+		todo.DueDate = &due
+	case "PRIORITY":
+		priority, err := strconv.Atoi(property.value)
+		if err != nil || priority < 0 || priority > 9 {
+			return fmt.Errorf("%q is not a priority from 0 to 9", property.value)
+		}
+		switch {
+		case priority == 0:
+			todo.Priority = PriorityNone
+		case priority <= 2:
+			todo.Priority = PriorityUrgent
+		case priority <= 4:
+			todo.Priority = PriorityHigh
+		case priority == 5:
+			todo.Priority = PriorityMedium
+		default:
+			todo.Priority = PriorityLow
+		}
+	case "CATEGORIES":
+		for _, tag := range splitEscaped(property.value) {
+			if tag = icalUnescape(tag); tag != "" {
+				todo.Tags = append(todo.Tags, tag)
+			}
+		}
+	case "RRULE":
+		todo.Recurrence = property.value
+	case "RELATED-TO":
+		// Only parents, RELTYPE defaults to PARENT
+		if reltype := property.params["RELTYPE"]; reltype == "" || strings.EqualFold(reltype, "PARENT") {
+			t.relatedTo = property.value
+		}
+	case "X-TODOAPP-LIST-ID":
+		listID, err := strconv.Atoi(property.value)
+		if err != nil {
+			return fmt.Errorf("%q is not a list ID", property.value)
+		}
+		todo.ListID = listID
+	}
+	return nil
+}
+
+// linkICalTodos numbers the decoded todos and resolves their parents.
+func linkICalTodos(decoded []*icalTodo) []*Todo {
+	ids := map[string]int{}
+	highest := 0
+	for _, t := range decoded {
+		if match := icalUID.FindStringSubmatch(t.uid); match != nil {
+			t.todo.ID, _ = strconv.Atoi(match[1])
+			highest = max(highest, t.todo.ID)
+		}
+	}
+	for _, t := range decoded {
+		if t.todo.ID == 0 {
+			highest++
+			t.todo.ID = highest
+		}
+		if t.uid != "" {
+			ids[t.uid] = t.todo.ID
+		}
+	}
+
+	todos := make([]*Todo, len(decoded))
+	for i, t := range decoded {
+		if parentID, ok := ids[t.relatedTo]; ok && parentID != t.todo.ID {
+			t.todo.ParentID = parentID
+		}
+		todos[i] = t.todo
+	}
+	// Import attaches subtasks to parents created before them, so parents come first
+	parents := map[int]int{}
+	for _, todo := range todos {
+		parents[todo.ID] = todo.ParentID
+	}
+	depths := map[int]int{}
+	for _, todo := range todos {
+		// Bounded by the number of todos in case RELATED-TO links form a cycle
+		for id := todo.ParentID; id != 0 && depths[todo.ID] < len(todos); id = parents[id] {
+			depths[todo.ID]++
+		}
+	}
+	sort.SliceStable(todos, func(i, j int) bool { return depths[todos[i].ID] < depths[todos[j].ID] })
+	return todos
+}
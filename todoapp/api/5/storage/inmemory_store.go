@@ -2,73 +2,335 @@ package storage
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 )
 
 // InMemoryStore is a thread-safe in-memory implementation of TodoStore.
+// Trashed todos stay in the map with DeletedAt set until they are purged.
 type InMemoryStore struct {
-	todos     sync.Map   // Stores todos using their ID as the key
-	mu        sync.Mutex // Ensures safe ID increments
-	idCounter int        // ID counter for generating unique IDs
+	Clock          Clock         // Time source for the todo timestamps, the system clock when nil
+	todos          sync.Map      // Stores todos using their ID as the key
+	mu             sync.Mutex    // Serializes writes and guards the ID counters, lists and search index
+	idCounter      int           // ID counter for generating unique IDs
+	index          *searchIndex  // Inverted index over live descriptions for SearchTodos
+	lists          map[int]*List // Lists by ID, starting with the inbox
+	nextListID     int
+	blockers       map[int]map[int]bool // IDs of the todos blocking each todo
+	reminders      map[int]*Reminder    // Reminders by ID
+	nextReminderID int
 }
 
 // NewInMemoryStore creates an in-memory storage instance.
 func NewInMemoryStore() *InMemoryStore {
-	return &InMemoryStore{idCounter: 1}
+	return &InMemoryStore{
+		idCounter:      1,
+		index:          newSearchIndex(),
+		lists:          map[int]*List{DefaultListID: newInbox(SystemClock.Now())},
+		nextListID:     DefaultListID + 1,
+		blockers:       map[int]map[int]bool{},
+		reminders:      map[int]*Reminder{},
+		nextReminderID: 1,
+	}
 }
 
 // AddTodo adds a new todo to the in-memory store.
 func (s *InMemoryStore) AddTodo(ctx context.Context, description string) (*Todo, error) {
+	return s.CreateTodo(ctx, NewTodo(description))
+}
+
+// CreateTodo adds a copy of newTodo to the in-memory store.
+func (s *InMemoryStore) CreateTodo(ctx context.Context, newTodo *Todo) (*Todo, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Check for duplicate description
+	tx := s.begin()
+	todo, err := tx.add(newTodo)
+	if err != nil {
+		return nil, err
+	}
+	tx.commit()
+	return todo, nil
+}
+
+// live loads a todo that exists and is not in the trash.
+func (s *InMemoryStore) live(id int) (*Todo, bool) {
+	value, exists := s.todos.Load(id)
+	if !exists || value.(*Todo).DeletedAt != nil {
+		return nil, false
+	}
+	return value.(*Todo), true
+}
+
+// GetAllTodos retrieves all todos that are not in the trash.
+func (s *InMemoryStore) GetAllTodos(ctx context.Context) ([]*Todo, error) {
+	var todoList []*Todo
 	s.todos.Range(func(_, value interface{}) bool {
-		if todo := value.(*Todo); todo.Description == description {
-			return false
+		if todo := value.(*Todo); todo.DeletedAt == nil {
+			todoList = append(todoList, todo)
 		}
 		return true
 	})
-
-	// Assign unique ID
-	todo := &Todo{ID: int(s.idCounter), Description: description, Completed: false}
-	s.todos.Store(s.idCounter, todo)
-	s.idCounter++
-	return todo, nil
+	sort.Slice(todoList, func(i, j int) bool { return todoList[i].ID < todoList[j].ID })
+	return todoList, nil
 }
 
-// GetAllTodos retrieves all todos.
-func (s *InMemoryStore) GetAllTodos(ctx context.Context) ([]*Todo, error) {
+// ListTodos evaluates the filter and sort in Go and returns the requested page.
+func (s *InMemoryStore) ListTodos(ctx context.Context, opts ListOptions) (*TodoPage, error) {
+	opts, anchor, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
 	var todoList []*Todo
 	s.todos.Range(func(_, value interface{}) bool {
-		todoList = append(todoList, value.(*Todo))
+		todo := value.(*Todo)
+		if todo.DeletedAt == nil && opts.Filter.Matches(todo) && (anchor == nil || opts.Sort.compare(todo, anchor) > 0) {
+			todoList = append(todoList, todo)
+		}
 		return true
 	})
-	return todoList, nil
+	sort.Slice(todoList, func(i, j int) bool { return opts.Sort.compare(todoList[i], todoList[j]) < 0 })
+
+	if len(todoList) > opts.Limit+1 {
+		todoList = todoList[:opts.Limit+1]
+	}
+	return newTodoPage(todoList, opts), nil
 }
 
-// GetTodoByID retrieves a todo by ID.
+// GetTodoByID retrieves a todo by ID, trashed todos are reported as not found.
 func (s *InMemoryStore) GetTodoByID(ctx context.Context, id int) (*Todo, error) {
-	if value, exists := s.todos.Load(id); exists {
-		return value.(*Todo), nil
+	if todo, exists := s.live(id); exists {
+		return todo, nil
 	}
 	return nil, NewTodoNotFoundError(id)
 }
 
-// UpdateTodoByID updates an existing todo.
+// GetTodoTree retrieves a todo with its subtasks.
+func (s *InMemoryStore) GetTodoTree(ctx context.Context, id int) (*TodoNode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.live(id); !exists {
+		return nil, NewTodoNotFoundError(id)
+	}
+	var todos []*Todo
+	s.todos.Range(func(_, value interface{}) bool {
+		if todo := value.(*Todo); todo.DeletedAt == nil {
+			todos = append(todos, todo)
+		}
+		return true
+	})
+	return buildTree(id, todos)
+}
+
+// UpdateTodoByID updates an existing todo that is not in the trash.
 func (s *InMemoryStore) UpdateTodoByID(ctx context.Context, id int, updatedTodo *Todo) error {
-	if _, exists := s.todos.Load(id); !exists {
-		return NewTodoNotFoundError(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.begin()
+	updated, err := tx.update(id, updatedTodo)
+	if err != nil {
+		return err
 	}
-	s.todos.Store(id, updatedTodo)
+	tx.commit()
+	*updatedTodo = *updated
 	return nil
 }
 
-// DeleteTodoByID removes a todo by ID.
+// PatchTodoByID applies patch to a copy of the current todo and stores the result,
+// holding the write lock so no other write can interleave.
+func (s *InMemoryStore) PatchTodoByID(ctx context.Context, id int, patch TodoPatch) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.live(id)
+	if !exists {
+		return nil, NewTodoNotFoundError(id)
+	}
+	patched := *current
+	if err := patch(&patched); err != nil {
+		return nil, err
+	}
+	patched.Version = current.Version
+	tx := s.begin()
+	updated, err := tx.update(id, &patched)
+	if err != nil {
+		return nil, err
+	}
+	tx.commit()
+	return updated, nil
+}
+
+// DeleteTodoByID moves a todo to the trash.
 func (s *InMemoryStore) DeleteTodoByID(ctx context.Context, id int) error {
-	if _, exists := s.todos.Load(id); !exists {
+	return s.DeleteTodoByIDAtVersion(ctx, id, 0)
+}
+
+// DeleteTodoByIDAtVersion moves a todo to the trash if it is at the given version, 0 matches any.
+func (s *InMemoryStore) DeleteTodoByIDAtVersion(ctx context.Context, id, version int) error {
+	_, err := s.DeleteTodoTree(ctx, id, version, DeleteRestrict)
+	return err
+}
+
+// DeleteTodoTree moves a todo to the trash and applies the delete rule to its subtasks,
+// all or nothing.
+func (s *InMemoryStore) DeleteTodoTree(ctx context.Context, id, version int, rule DeleteRule) ([]SubtaskChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.begin()
+	subtasks, err := tx.delete(id, version, rule)
+	if err != nil {
+		return nil, err
+	}
+	tx.commit()
+	return subtasks, nil
+}
+
+// ApplyBatch stages every operation on a copy-on-write view of the store and
+// publishes them together, or not at all if one of them fails.
+func (s *InMemoryStore) ApplyBatch(ctx context.Context, ops []BatchOp) ([]*BatchResult, error) {
+	if err := validateBatch(ops); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.begin()
+	results := make([]*BatchResult, 0, len(ops))
+	for i, op := range ops {
+		result, err := tx.apply(op)
+		if err != nil {
+			return nil, batchOpError(i, op, err)
+		}
+		results = append(results, result)
+	}
+	tx.commit()
+	return results, nil
+}
+
+// trashed loads a todo that is in the trash.
+func (s *InMemoryStore) trashed(id int) (*Todo, bool) {
+	value, exists := s.todos.Load(id)
+	if !exists || value.(*Todo).DeletedAt == nil {
+		return nil, false
+	}
+	return value.(*Todo), true
+}
+
+// ListTrash retrieves the trashed todos, most recently deleted first.
+func (s *InMemoryStore) ListTrash(ctx context.Context) ([]*Todo, error) {
+	todoList := []*Todo{}
+	s.todos.Range(func(_, value interface{}) bool {
+		if todo := value.(*Todo); todo.DeletedAt != nil {
+			todoList = append(todoList, todo)
+		}
+		return true
+	})
+	sort.Slice(todoList, func(i, j int) bool {
+		if !todoList[i].DeletedAt.Equal(*todoList[j].DeletedAt) {
+			return todoList[i].DeletedAt.After(*todoList[j].DeletedAt)
+		}
+		return todoList[i].ID > todoList[j].ID
+	})
+	return todoList, nil
+}
+
+// RestoreTodoByID takes a todo out of the trash as a new version, into the inbox when
+// its list is gone.
+func (s *InMemoryStore) RestoreTodoByID(ctx context.Context, id int) (*Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todo, exists := s.trashed(id)
+	if !exists {
+		return nil, NewTodoNotFoundError(id)
+	}
+	restored := *todo
+	if _, exists := s.lists[restored.ListID]; !exists {
+		restored.ListID = DefaultListID
+	}
+	if !restored.Completed && s.begin().hasDescription(restored.ListID, restored.Description, id) {
+		return nil, NewDuplicateTodoError(restored.Description)
+	}
+	restored.DeletedAt = nil
+	restored.Version++
+	restored.UpdatedAt = nowFrom(s.Clock)
+	// A subtask whose parent is gone, or now in another list, comes back at the top level
+	if parent, exists := s.live(restored.ParentID); !exists || parent.ListID != restored.ListID {
+		restored.ParentID = 0
+	}
+	s.todos.Store(id, &restored)
+	s.index.add(id, restored.Description)
+	return &restored, nil
+}
+
+// PurgeTodoByID permanently removes a trashed todo.
+func (s *InMemoryStore) PurgeTodoByID(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.trashed(id); !exists {
 		return NewTodoNotFoundError(id)
 	}
 	s.todos.Delete(id)
+	s.forgetDependencies(id)
+	s.forgetReminders(id)
 	return nil
 }
+
+// PurgeDeletedBefore permanently removes the todos trashed before the cutoff.
+func (s *InMemoryStore) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := []int{}
+	s.todos.Range(func(key, value interface{}) bool {
+		if todo := value.(*Todo); todo.DeletedAt != nil && todo.DeletedAt.Before(cutoff) {
+			s.todos.Delete(key)
+			s.forgetDependencies(todo.ID)
+			s.forgetReminders(todo.ID)
+			purged = append(purged, todo.ID)
+		}
+		return true
+	})
+	sort.Ints(purged)
+	return purged, nil
+}
+
+// SearchTodos ranks the todos whose descriptions contain every word of the query
+// using the store's inverted index.
+func (s *InMemoryStore) SearchTodos(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	terms, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, scores := s.index.search(terms)
+	results := []*SearchResult{}
+	for _, id := range ids {
+		if len(results) == limit {
+			break
+		}
+		todo, exists := s.live(id)
+		if !exists {
+			continue
+		}
+		results = append(results, &SearchResult{
+			Todo:    todo,
+			Rank:    scores[id],
+			Snippet: highlight(todo.Description, terms),
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+)
+
+// memoryTxn stages writes to an InMemoryStore in an overlay. Reads through the
+// transaction see the staged todos, the store only sees them once commit publishes
+// them. The store's mutex must be held for the whole life of the transaction.
+type memoryTxn struct {
+	store  *InMemoryStore
+	staged map[int]*Todo
+	nextID int
+}
+
+// begin starts a transaction on the store.
+func (s *InMemoryStore) begin() *memoryTxn {
+	return &memoryTxn{store: s, staged: map[int]*Todo{}, nextID: s.idCounter}
+}
+
+// live loads a todo that exists and is not in the trash, staged writes included.
+func (tx *memoryTxn) live(id int) (*Todo, bool) {
+	if todo, staged := tx.staged[id]; staged {
+		return todo, todo.DeletedAt == nil
+	}
+	return tx.store.live(id)
+}
+
+// matching returns the todos outside the trash that match, staged writes included, ordered by ID.
+func (tx *memoryTxn) matching(match func(todo *Todo) bool) []*Todo {
+	var todos []*Todo
+	for _, todo := range tx.staged {
+		if todo.DeletedAt == nil && match(todo) {
+			todos = append(todos, todo)
+		}
+	}
+	tx.store.todos.Range(func(key, value interface{}) bool {
+		if _, staged := tx.staged[key.(int)]; !staged && value.(*Todo).DeletedAt == nil && match(value.(*Todo)) {
+			todos = append(todos, value.(*Todo))
+		}
+		return true
+	})
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID < todos[j].ID })
+	return todos
+}
+
+// count returns the number of todos outside the trash that match, staged writes included.
+func (tx *memoryTxn) count(match func(todo *Todo) bool) int {
+	return len(tx.matching(match))
+}
+
+// hasDescription reports whether an open todo of the list outside the trash, other than
+// excludeID, has the description. Completed todos don't count, so a recurring todo can
+// follow its last occurrence.
+func (tx *memoryTxn) hasDescription(listID int, description string, excludeID int) bool {
+	return tx.count(func(todo *Todo) bool {
+		return todo.ID != excludeID && todo.ListID == listID && todo.Description == description && !todo.Completed
+	}) > 0
+}
+
+// place resolves the list of a todo about to be written under id, 0 for a new todo, and
+// checks its parent. A zero ListID means the parent's list for a subtask and currentListID
+// otherwise. Subtasks stay in the list of their parent, which can't be one of their own
+// subtasks.
+func (tx *memoryTxn) place(id int, todo *Todo, currentListID int) error {
+	if todo.ParentID != 0 {
+		parent, exists := tx.live(todo.ParentID)
+		if !exists {
+			return parentError(fmt.Sprintf("todo %d does not exist", todo.ParentID))
+		}
+		if todo.ListID == 0 {
+			todo.ListID = parent.ListID
+		}
+		if todo.ListID != parent.ListID {
+			return parentError("must be in the same list")
+		}
+		for ancestor := parent; ancestor != nil; ancestor, _ = tx.live(ancestor.ParentID) {
+			if ancestor.ID == id {
+				return parentError("must not be the todo itself or one of its subtasks")
+			}
+		}
+	}
+	if todo.ListID == 0 {
+		todo.ListID = currentListID
+	}
+	if _, exists := tx.store.lists[todo.ListID]; !exists {
+		return NewListNotFoundError(todo.ListID)
+	}
+	if id != 0 {
+		if moved := tx.count(func(subtask *Todo) bool {
+			return subtask.ParentID == id && subtask.ListID != todo.ListID
+		}); moved > 0 {
+			return NewHasSubtasksError(id, moved)
+		}
+	}
+	return nil
+}
+
+// add stages a copy of newTodo under the next ID, in the inbox unless it names a list
+// or a parent, and returns another copy.
+func (tx *memoryTxn) add(newTodo *Todo) (*Todo, error) {
+	todo := *newTodo
+	if err := tx.place(0, &todo, DefaultListID); err != nil {
+		return nil, err
+	}
+	if tx.hasDescription(todo.ListID, todo.Description, 0) {
+		return nil, NewDuplicateTodoError(todo.Description)
+	}
+	todo.ID = tx.nextID
+	todo.Version = 1
+	todo.DeletedAt = nil
+	stamp(&todo, nil, nowFrom(tx.store.Clock))
+	tx.staged[todo.ID] = &todo
+	tx.nextID++
+	created := todo
+	return &created, nil
+}
+
+// update stages a copy of updatedTodo, so callers never share memory with the store.
+// A zero ListID keeps the todo in its list, or moves it to the list of its new parent.
+func (tx *memoryTxn) update(id int, updatedTodo *Todo) (*Todo, error) {
+	current, exists := tx.live(id)
+	if !exists {
+		return nil, NewTodoNotFoundError(id)
+	}
+	if updatedTodo.Version != 0 && updatedTodo.Version != current.Version {
+		return nil, NewVersionConflictError(id, updatedTodo.Version, current.Version)
+	}
+	updated := *updatedTodo
+	if err := tx.place(id, &updated, current.ListID); err != nil {
+		return nil, err
+	}
+	if err := tx.checkUnblocked(current, &updated); err != nil {
+		return nil, err
+	}
+	if !updated.Completed && tx.hasDescription(updated.ListID, updated.Description, id) {
+		return nil, NewDuplicateTodoError(updated.Description)
+	}
+	updated.ID = id
+	updated.Version = current.Version + 1
+	updated.DeletedAt = nil // only DeleteTodoByID moves todos to the trash
+	stamp(&updated, current, nowFrom(tx.store.Clock))
+	tx.staged[id] = &updated
+	return &updated, nil
+}
+
+// delete stages a trashed copy of a todo after applying the delete rule to its subtasks,
+// and returns the subtasks it changed.
+func (tx *memoryTxn) delete(id, version int, rule DeleteRule) ([]SubtaskChange, error) {
+	todo, exists := tx.live(id)
+	if !exists {
+		return nil, NewTodoNotFoundError(id)
+	}
+	if version != 0 && version != todo.Version {
+		return nil, NewVersionConflictError(id, version, todo.Version)
+	}
+	changes, err := tx.clearSubtasks(todo, rule)
+	if err != nil {
+		return nil, err
+	}
+	// Stage a copy so readers holding the previous pointer never see it change
+	trashed := *todo
+	deletedAt := nowFrom(tx.store.Clock)
+	trashed.DeletedAt = &deletedAt
+	tx.staged[id] = &trashed
+	return changes, nil
+}
+
+// clearSubtasks applies the delete rule to the subtasks of a todo about to be trashed.
+func (tx *memoryTxn) clearSubtasks(todo *Todo, rule DeleteRule) ([]SubtaskChange, error) {
+	subtasks := tx.matching(func(subtask *Todo) bool { return subtask.ParentID == todo.ID })
+	if len(subtasks) == 0 {
+		return nil, nil
+	}
+
+	var changes []SubtaskChange
+	switch rule {
+	case DeleteCascade:
+		for _, subtask := range subtasks {
+			nested, err := tx.delete(subtask.ID, 0, rule)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(append(changes, nested...), SubtaskChange{Before: subtask, After: tx.staged[subtask.ID]})
+		}
+	case DeletePromote:
+		for _, subtask := range subtasks {
+			promoted := *subtask
+			promoted.ParentID = todo.ParentID
+			after, err := tx.update(subtask.ID, &promoted)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, SubtaskChange{Before: subtask, After: after})
+		}
+	default:
+		return nil, NewHasSubtasksError(todo.ID, len(subtasks))
+	}
+	return changes, nil
+}
+
+// apply stages one batch operation.
+func (tx *memoryTxn) apply(op BatchOp) (*BatchResult, error) {
+	result := &BatchResult{Op: op.Op, ID: op.ID}
+	var err error
+	switch op.Op {
+	case BatchCreate:
+		result.Todo, err = tx.add(op.Todo())
+	case BatchUpdate:
+		result.Todo, err = tx.update(op.ID, op.Todo())
+	case BatchDelete:
+		result.Subtasks, err = tx.delete(op.ID, op.Version, op.OnDelete)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.Todo != nil {
+		result.ID = result.Todo.ID
+	}
+	return result, nil
+}
+
+// commit publishes the staged todos and keeps the search index in step.
+func (tx *memoryTxn) commit() {
+	for id, todo := range tx.staged {
+		tx.store.todos.Store(id, todo)
+		if todo.DeletedAt == nil {
+			tx.store.index.add(id, todo.Description)
+		} else {
+			tx.store.index.remove(id)
+		}
+	}
+	tx.store.idCounter = tx.nextID
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultListID is the inbox: the list todos are created in when none is given.
+// It always exists and cannot be deleted.
+const DefaultListID = 1
+
+// MaxListNameLength bounds the length of a list name, in characters
+const MaxListNameLength = 100
+
+// List groups todos, such as the todos of one project.
+type List struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"` // #rrggbb
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListStore defines storage operations for lists. List names are unique, ignoring case.
+type ListStore interface {
+	CreateList(ctx context.Context, list *List) (*List, error)
+	GetList(ctx context.Context, id int) (*List, error)
+	ListLists(ctx context.Context, includeArchived bool) ([]*List, error)
+	// UpdateList replaces the name, color and archived flag of a list
+	UpdateList(ctx context.Context, id int, list *List) (*List, error)
+	// DeleteList removes a list without live todos. Its trashed todos move to the inbox.
+	DeleteList(ctx context.Context, id int) error
+}
+
+var listColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ValidateList checks the user-supplied fields of a list and reports every invalid one.
+func ValidateList(list *List) error {
+	var fields []FieldError
+	switch {
+	case strings.TrimSpace(list.Name) == "":
+		fields = append(fields, FieldError{Field: "name", Message: "must not be empty"})
+	case utf8.RuneCountInString(list.Name) > MaxListNameLength:
+		fields = append(fields, FieldError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", MaxListNameLength)})
+	}
+	if list.Color != "" && !listColor.MatchString(list.Color) {
+		fields = append(fields, FieldError{Field: "color", Message: "must be a hex color such as #1e90ff"})
+	}
+	if len(fields) > 0 {
+		return NewValidationError(fields...)
+	}
+	return nil
+}
+
+// newInbox returns the list every store starts with.
+func newInbox(now time.Time) *List {
+	return &List{ID: DefaultListID, Name: "Inbox", CreatedAt: now, UpdatedAt: now}
+}
+
+// CreateList adds a list.
+func (t *TodoList) CreateList(ctx context.Context, list *List) (*List, error) {
+	created, err := t.Store.CreateList(ctx, list)
+	if err != nil {
+		t.Logger.Error("Failed to create list", "error", err)
+		return nil, err
+	}
+	t.Logger.Info("Created a list", "id", created.ID)
+	return created, nil
+}
+
+// GetList retrieves a list by ID.
+func (t *TodoList) GetList(ctx context.Context, id int) (*List, error) {
+	t.Logger.Info("Getting a list", "id", id)
+	return t.Store.GetList(ctx, id)
+}
+
+// ListLists retrieves the lists, archived ones only when asked for.
+func (t *TodoList) ListLists(ctx context.Context, includeArchived bool) ([]*List, error) {
+	t.Logger.Info("Listing lists", "archived", includeArchived)
+	return t.Store.ListLists(ctx, includeArchived)
+}
+
+// UpdateList renames, recolors, archives or unarchives a list.
+func (t *TodoList) UpdateList(ctx context.Context, id int, list *List) (*List, error) {
+	t.Logger.Info("Updating a list", "id", id)
+	return t.Store.UpdateList(ctx, id, list)
+}
+
+// DeleteList removes an empty list.
+func (t *TodoList) DeleteList(ctx context.Context, id int) error {
+	t.Logger.Info("Deleting a list", "id", id)
+	return t.Store.DeleteList(ctx, id)
+}
+
+// CreateList adds a list to the in-memory store.
+func (s *InMemoryStore) CreateList(ctx context.Context, list *List) (*List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hasListName(list.Name, 0) {
+		return nil, NewDuplicateListError(list.Name)
+	}
+	now := nowFrom(s.Clock)
+	created := &List{ID: s.nextListID, Name: list.Name, Color: list.Color, Archived: list.Archived, CreatedAt: now, UpdatedAt: now}
+	s.lists[created.ID] = created
+	s.nextListID++
+	copied := *created
+	return &copied, nil
+}
+
+// hasListName reports whether a list other than except is named name, ignoring case.
+// The store mutex must be held.
+func (s *InMemoryStore) hasListName(name string, except int) bool {
+	for id, list := range s.lists {
+		if id != except && strings.EqualFold(list.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetList retrieves a list by ID.
+func (s *InMemoryStore) GetList(ctx context.Context, id int) (*List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, exists := s.lists[id]
+	if !exists {
+		return nil, NewListNotFoundError(id)
+	}
+	copied := *list
+	return &copied, nil
+}
+
+// ListLists retrieves the lists ordered by ID.
+func (s *InMemoryStore) ListLists(ctx context.Context, includeArchived bool) ([]*List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lists := []*List{}
+	for _, list := range s.lists {
+		if includeArchived || !list.Archived {
+			copied := *list
+			lists = append(lists, &copied)
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].ID < lists[j].ID })
+	return lists, nil
+}
+
+// UpdateList replaces the user-editable fields of a list.
+func (s *InMemoryStore) UpdateList(ctx context.Context, id int, list *List) (*List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.lists[id]
+	if !exists {
+		return nil, NewListNotFoundError(id)
+	}
+	if s.hasListName(list.Name, id) {
+		return nil, NewDuplicateListError(list.Name)
+	}
+	updated := &List{
+		ID:        id,
+		Name:      list.Name,
+		Color:     list.Color,
+		Archived:  list.Archived,
+		CreatedAt: current.CreatedAt,
+		UpdatedAt: nowFrom(s.Clock),
+	}
+	s.lists[id] = updated
+	copied := *updated
+	return &copied, nil
+}
+
+// DeleteList removes a list without live todos and moves its trashed todos to the inbox.
+func (s *InMemoryStore) DeleteList(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.lists[id]; !exists {
+		return NewListNotFoundError(id)
+	}
+	if id == DefaultListID {
+		return NewInvalidInputError("The inbox cannot be deleted")
+	}
+
+	live := 0
+	var trashed []*Todo
+	s.todos.Range(func(_, value interface{}) bool {
+		if todo := value.(*Todo); todo.ListID == id {
+			if todo.DeletedAt == nil {
+				live++
+			} else {
+				trashed = append(trashed, todo)
+			}
+		}
+		return true
+	})
+	if live > 0 {
+		return NewListNotEmptyError(id, live)
+	}
+
+	for _, todo := range trashed {
+		moved := *todo
+		moved.ListID = DefaultListID
+		s.todos.Store(moved.ID, &moved)
+	}
+	delete(s.lists, id)
+	return nil
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownHeading = regexp.MustCompile(`^#{1,6}\s+(.*?)(?:\s+#+)?\s*$`)
+	markdownTask    = regexp.MustCompile(`^(\s*)(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+(.*)$`)
+)
+
+// MarkdownCodec reads and writes todos as Markdown task lists, for pasting into pull
+// requests and wikis: a heading per list, then a "- [ ]" or "- [x]" item per todo with
+// its subtasks nested below it. Only descriptions, completion, subtasks and lists survive
+// a round trip.
+type MarkdownCodec struct {
+	Lists []*List // Name the headings; the lists of other todos are written as "List N"
+
+	// CreateList, when set, is called on import for headings that name none of Lists.
+	// Without it their todos go to the default list.
+	CreateList func(name string) (*List, error)
+}
+
+func (c *MarkdownCodec) MediaType() string    { return "text/markdown" }
+func (c *MarkdownCodec) Extensions() []string { return []string{".md", ".markdown"} }
+
+// WithLists returns a copy of the codec, keeping the Lists and CreateList already set.
+func (c *MarkdownCodec) WithLists(lists []*List, create func(name string) (*List, error)) Codec {
+	configured := *c
+	if configured.Lists == nil {
+		configured.Lists = lists
+	}
+	if configured.CreateList == nil {
+		configured.CreateList = create
+	}
+	return &configured
+}
+
+func (c *MarkdownCodec) Encode(w io.Writer, todos []*Todo) error {
+	present := map[int]bool{}
+	for _, todo := range todos {
+		present[todo.ID] = true
+	}
+	// Lists in the order of Lists, then of first appearance, and the top-level todos of
+	// each; subtasks follow their parent wherever it is
+	listIDs := []int{}
+	roots := map[int][]*Todo{}
+	subtasks := map[int][]*Todo{}
+	for _, list := range c.Lists {
+		listIDs = append(listIDs, list.ID)
+	}
+	for _, todo := range todos {
+		if todo.ParentID != 0 && present[todo.ParentID] {
+			subtasks[todo.ParentID] = append(subtasks[todo.ParentID], todo)
+			continue
+		}
+		listID := todo.ListID
+		if listID == 0 {
+			listID = DefaultListID
+		}
+		if _, seen := roots[listID]; !seen && !c.hasList(listID) {
+			listIDs = append(listIDs, listID)
+		}
+		roots[listID] = append(roots[listID], todo)
+	}
+
+	out := bufio.NewWriter(w)
+	written := map[int]bool{}
+	var writeItem func(todo *Todo, depth int)
+	writeItem = func(todo *Todo, depth int) {
+		if written[todo.ID] && todo.ID != 0 {
+			return
+		}
+		written[todo.ID] = true
+		box := "[ ]"
+		if todo.Completed {
+			box = "[x]"
+		}
+		fmt.Fprintf(out, "%s- %s %s\n", strings.Repeat("  ", depth), box, strings.Join(strings.Fields(todo.Description), " "))
+		for _, subtask := range subtasks[todo.ID] {
+			writeItem(subtask, depth+1)
+		}
+	}
+	sections := 0
+	for _, listID := range listIDs {
+		if len(roots[listID]) == 0 {
+			continue
+		}
+		if sections > 0 {
+			out.WriteString("\n")
+		}
+		sections++
+		fmt.Fprintf(out, "## %s\n\n", c.listName(listID))
+		for _, todo := range roots[listID] {
+			writeItem(todo, 0)
+		}
+	}
+	return out.Flush()
+}
+
+func (c *MarkdownCodec) hasList(id int) bool {
+	for _, list := range c.Lists {
+		if list.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *MarkdownCodec) listName(id int) string {
+	for _, list := range c.Lists {
+		if list.ID == id {
+			return list.Name
+		}
+	}
+	return fmt.Sprintf("List %d", id)
+}
+
+// Decode reads the task items of a document, numbering them from 1 so that subtasks refer
+// to their parent, and ignores everything else, code blocks included. Items nest by
+// indentation, a tab counting as four spaces.
+func (c *MarkdownCodec) Decode(r io.Reader) ([]*Todo, error) {
+	lists := map[string]int{}
+	for _, list := range c.Lists {
+		lists[strings.ToLower(list.Name)] = list.ID
+	}
+
+	type openItem struct{ indent, id int }
+	todos := []*Todo{}
+	stack := []openItem{}
+	listID := 0
+	fenced := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.ReplaceAll(strings.TrimRight(scanner.Text(), " \t\r"), "\t", "    ")
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+
+		if match := markdownHeading.FindStringSubmatch(line); match != nil {
+			stack = stack[:0]
+			name := strings.TrimSpace(match[1])
+			id, ok := lists[strings.ToLower(name)]
+			if !ok && c.CreateList != nil && name != "" {
+				list, err := c.CreateList(name)
+				if err != nil {
+					return nil, fmt.Errorf("creating list %q: %w", name, err)
+				}
+				id = list.ID
+				lists[strings.ToLower(name)] = id
+			}
+			listID = id
+			continue
+		}
+
+		match := markdownTask.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		indent := len(match[1])
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		todo := &Todo{
+			ID:          len(todos) + 1,
+			ListID:      listID,
+			Description: strings.TrimSpace(match[3]),
+			Completed:   match[2] != " ",
+		}
+		if len(stack) > 0 {
+			todo.ParentID = stack[len(stack)-1].id
+		}
+		stack = append(stack, openItem{indent, todo.ID})
+		todos = append(todos, todo)
+	}
+	return todos, scanner.Err()
+}
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS todos (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	description TEXT NOT NULL,
+	completed BOOLEAN NOT NULL
+);
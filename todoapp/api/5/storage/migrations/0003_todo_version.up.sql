@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
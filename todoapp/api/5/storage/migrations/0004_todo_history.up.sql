@@ -0,0 +1,17 @@
+CREATE TABLE todo_history (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    todo_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    before TEXT,
+    after TEXT,
+    at TIMESTAMP NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_todo_history_todo_id ON todo_history (todo_id, seq);
+CREATE TRIGGER todo_history_no_update BEFORE UPDATE ON todo_history BEGIN
+    SELECT RAISE(ABORT, 'todo_history is append-only');
+END;
+CREATE TRIGGER todo_history_no_delete BEFORE DELETE ON todo_history BEGIN
+    SELECT RAISE(ABORT, 'todo_history is append-only');
+END;
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS todo_tags_purge;
+DROP TABLE IF EXISTS todo_tags;
+DROP INDEX IF EXISTS idx_todos_due_date;
+ALTER TABLE todos DROP COLUMN completed_at;
+ALTER TABLE todos DROP COLUMN updated_at;
+ALTER TABLE todos DROP COLUMN created_at;
+ALTER TABLE todos DROP COLUMN priority;
+ALTER TABLE todos DROP COLUMN due_date;
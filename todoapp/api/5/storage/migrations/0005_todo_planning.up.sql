@@ -0,0 +1,16 @@
+ALTER TABLE todos ADD COLUMN due_date TIMESTAMP;
+ALTER TABLE todos ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE todos ADD COLUMN updated_at TIMESTAMP;
+ALTER TABLE todos ADD COLUMN completed_at TIMESTAMP;
+UPDATE todos SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP;
+CREATE INDEX idx_todos_due_date ON todos (due_date);
+CREATE TABLE todo_tags (
+    todo_id INTEGER NOT NULL REFERENCES todos (id),
+    tag TEXT NOT NULL,
+    PRIMARY KEY (todo_id, tag)
+);
+CREATE INDEX idx_todo_tags_tag ON todo_tags (tag, todo_id);
+CREATE TRIGGER todo_tags_purge AFTER DELETE ON todos BEGIN
+    DELETE FROM todo_tags WHERE todo_id = old.id;
+END;
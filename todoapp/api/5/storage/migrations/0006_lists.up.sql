@@ -0,0 +1,12 @@
+CREATE TABLE lists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    color TEXT NOT NULL DEFAULT '',
+    archived BOOLEAN NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+CREATE UNIQUE INDEX idx_lists_name ON lists (name COLLATE NOCASE);
+INSERT INTO lists (id, name, created_at, updated_at) VALUES (1, 'Inbox', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+ALTER TABLE todos ADD COLUMN list_id INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX idx_todos_list_id ON todos (list_id);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS todo_dependencies_purge;
+DROP TABLE IF EXISTS todo_dependencies;
@@ -0,0 +1,9 @@
+CREATE TABLE todo_dependencies (
+    todo_id INTEGER NOT NULL REFERENCES todos (id),
+    blocker_id INTEGER NOT NULL REFERENCES todos (id),
+    PRIMARY KEY (todo_id, blocker_id)
+);
+CREATE INDEX idx_todo_dependencies_blocker ON todo_dependencies (blocker_id, todo_id);
+CREATE TRIGGER todo_dependencies_purge AFTER DELETE ON todos BEGIN
+    DELETE FROM todo_dependencies WHERE todo_id = old.id OR blocker_id = old.id;
+END;
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS todo_reminders_purge;
+DROP TABLE IF EXISTS todo_reminders;
@@ -0,0 +1,12 @@
+CREATE TABLE todo_reminders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    todo_id INTEGER NOT NULL REFERENCES todos (id),
+    at TIMESTAMP NOT NULL,
+    fired_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_todo_reminders_todo ON todo_reminders (todo_id);
+CREATE INDEX idx_todo_reminders_due ON todo_reminders (at) WHERE fired_at IS NULL;
+CREATE TRIGGER todo_reminders_purge AFTER DELETE ON todos BEGIN
+    DELETE FROM todo_reminders WHERE todo_id = old.id;
+END;
@@ -0,0 +1,2 @@
+ALTER TABLE todo_reminders DROP COLUMN attempts;
+ALTER TABLE todo_reminders DROP COLUMN claimed_until;
@@ -0,0 +1,2 @@
+ALTER TABLE todo_reminders ADD COLUMN claimed_until TIMESTAMP;
+ALTER TABLE todo_reminders ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Page size limits applied to ListTodos
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// ListOptions selects, orders and pages the todos returned by ListTodos.
+// Cursor is the opaque NextCursor of the previous page and is only valid with the same Sort.
+type ListOptions struct {
+	Filter TodoFilter
+	Sort   TodoSort
+	Limit  int
+	Cursor string
+}
+
+// TodoPage is one page of todos in a stable order.
+// NextCursor is empty when there are no more todos.
+type TodoPage struct {
+	Todos      []*Todo `json:"todos"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// cursor is the position after which the next page starts: the sort key and ID of the last todo.
+type cursor struct {
+	Sort SortField       `json:"s"`
+	Key  json.RawMessage `json:"k,omitempty"`
+	ID   int             `json:"id"`
+}
+
+// encodeCursor returns the opaque cursor pointing just after the given todo.
+func encodeCursor(sort TodoSort, last *Todo) string {
+	c := cursor{Sort: sort.Field, ID: last.ID}
+	if sort.Field != SortByID {
+		c.Key, _ = json.Marshal(sortColumns[sort.Field].field(last))
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor rebuilds the todo a cursor points after, holding only its ID and sort key.
+// An empty cursor returns nil, meaning the first page.
+func decodeCursor(sort TodoSort, value string) (*Todo, error) {
+	if value == "" {
+		return nil, nil
+	}
+	invalid := NewInvalidInputError("Invalid pagination cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID < 0 {
+		return nil, invalid
+	}
+	if c.Sort != sort.Field {
+		return nil, NewInvalidInputError("Pagination cursor was issued for a different sort order")
+	}
+
+	anchor := &Todo{ID: c.ID}
+	if sort.Field != SortByID {
+		if err := json.Unmarshal(c.Key, sortColumns[sort.Field].field(anchor)); err != nil {
+			return nil, invalid
+		}
+	}
+	return anchor, nil
+}
+
+// normalize validates the options, fills in defaults and decodes the cursor.
+func (o ListOptions) normalize() (ListOptions, *Todo, error) {
+	if o.Limit < 0 {
+		return o, nil, NewInvalidInputError("Page limit cannot be negative")
+	}
+	if o.Limit == 0 {
+		o.Limit = DefaultPageLimit
+	}
+	if o.Limit > MaxPageLimit {
+		return o, nil, NewInvalidInputError(fmt.Sprintf("Page limit cannot exceed %d", MaxPageLimit))
+	}
+
+	sort, err := o.Sort.normalize()
+	if err != nil {
+		return o, nil, err
+	}
+	o.Sort = sort
+
+	anchor, err := decodeCursor(o.Sort, o.Cursor)
+	return o, anchor, err
+}
+
+// newTodoPage trims a result fetched with one extra row into a page and its next cursor.
+func newTodoPage(todos []*Todo, opts ListOptions) *TodoPage {
+	page := &TodoPage{Todos: todos}
+	if len(todos) > opts.Limit {
+		page.Todos = todos[:opts.Limit]
+		page.NextCursor = encodeCursor(opts.Sort, page.Todos[opts.Limit-1])
+	}
+	if page.Todos == nil {
+		page.Todos = []*Todo{}
+	}
+	return page
+}
@@ -0,0 +1,379 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TodoPatch modifies a todo in place. The stores apply it to the current todo and
+// write the result atomically, so a patch always sees the latest stored state.
+type TodoPatch func(todo *Todo) error
+
+// NewMergePatch parses an RFC 7396 JSON Merge Patch: object members replace the matching
+// fields of the todo and null members remove them.
+func NewMergePatch(doc []byte) (TodoPatch, error) {
+	var patch interface{}
+	if err := json.Unmarshal(doc, &patch); err != nil {
+		return nil, &TodoError{Code: ErrInvalidInput, Message: "Invalid merge patch", Err: err}
+	}
+	return func(todo *Todo) error {
+		return patchDocument(todo, func(target interface{}) (interface{}, error) {
+			return mergePatch(target, patch), nil
+		})
+	}, nil
+}
+
+// mergePatch applies patch to target as described in RFC 7396, section 2.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+	return targetObject
+}
+
+// patchOperation is one operation of an RFC 6902 JSON Patch.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"` // empty when absent, "null" for an explicit null
+
+	path, from []string
+	value      interface{}
+}
+
+// NewJSONPatch parses an RFC 6902 JSON Patch. The operations are applied in order
+// and either all of them succeed or the todo is left unchanged.
+func NewJSONPatch(doc []byte) (TodoPatch, error) {
+	var operations []*patchOperation
+	if err := json.Unmarshal(doc, &operations); err != nil {
+		return nil, &TodoError{Code: ErrInvalidInput, Message: "Invalid JSON patch", Err: err}
+	}
+	for i, op := range operations {
+		if err := op.parse(); err != nil {
+			return nil, NewInvalidInputError(fmt.Sprintf("Invalid JSON patch operation %d: %s", i, err))
+		}
+	}
+	return func(todo *Todo) error {
+		return patchDocument(todo, func(doc interface{}) (interface{}, error) {
+			for i, op := range operations {
+				var err error
+				if doc, err = op.apply(doc); err != nil {
+					if _, ok := err.(*TodoError); ok {
+						return nil, err
+					}
+					return nil, NewInvalidInputError(fmt.Sprintf("JSON patch operation %d (%s %s): %s", i, op.Op, op.Path, err))
+				}
+			}
+			return doc, nil
+		})
+	}, nil
+}
+
+// parse checks that the operation has the members its op requires and parses its pointers.
+func (op *patchOperation) parse() error {
+	var err error
+	if op.path, err = parsePointer(op.Path); err != nil {
+		return err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return fmt.Errorf("%s requires a value", op.Op)
+		}
+		return json.Unmarshal(op.Value, &op.value)
+	case "move", "copy":
+		if op.from, err = parsePointer(op.From); err != nil {
+			return err
+		}
+		if op.Op == "move" && len(op.path) > len(op.from) && hasPrefix(op.path, op.from) {
+			return fmt.Errorf("cannot move %s into one of its children", op.From)
+		}
+		return nil
+	case "remove":
+		return nil
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+}
+
+// apply runs the operation against doc and returns the resulting document.
+func (op *patchOperation) apply(doc interface{}) (interface{}, error) {
+	switch op.Op {
+	case "add":
+		return addValue(doc, op.path, copyValue(op.value))
+	case "remove":
+		doc, _, err := removeValue(doc, op.path)
+		return doc, err
+	case "replace":
+		doc, _, err := removeValue(doc, op.path)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, op.path, copyValue(op.value))
+	case "move":
+		doc, value, err := removeValue(doc, op.from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, op.path, value)
+	case "copy":
+		value, err := getValue(doc, op.from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, op.path, copyValue(value))
+	default: // test
+		value, err := getValue(doc, op.path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, op.value) {
+			return nil, &TodoError{
+				Code:    ErrPatchConflict,
+				Message: fmt.Sprintf("JSON patch test of %s failed", op.Path),
+			}
+		}
+		return doc, nil
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func hasPrefix(tokens, prefix []string) bool {
+	for i := range prefix {
+		if tokens[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses an array index token, which must be at most max.
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return index, nil
+}
+
+// getValue returns the value the tokens point at.
+func getValue(node interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			value, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			node = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[index]
+		default:
+			return nil, fmt.Errorf("cannot index into a scalar with %q", token)
+		}
+	}
+	return node, nil
+}
+
+// addValue sets the member or inserts the array element the tokens point at
+// and returns the updated node. "-" appends to an array.
+func addValue(node interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			n[token] = value
+			return n, nil
+		}
+		child, ok := n[token]
+		if !ok {
+			return nil, fmt.Errorf("member %q does not exist", token)
+		}
+		child, err := addValue(child, rest, value)
+		n[token] = child
+		return n, err
+	case []interface{}:
+		if len(rest) == 0 {
+			index := len(n)
+			if token != "-" {
+				var err error
+				if index, err = arrayIndex(token, len(n)); err != nil {
+					return nil, err
+				}
+			}
+			n = append(n, nil)
+			copy(n[index+1:], n[index:])
+			n[index] = value
+			return n, nil
+		}
+		index, err := arrayIndex(token, len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		child, err := addValue(n[index], rest, value)
+		n[index] = child
+		return n, err
+	default:
+		return nil, fmt.Errorf("cannot add to a scalar with %q", token)
+	}
+}
+
+// removeValue deletes the member or array element the tokens point at and
+// returns the updated node along with the removed value.
+func removeValue(node interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, node, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("member %q does not exist", token)
+		}
+		if len(rest) == 0 {
+			delete(n, token)
+			return n, child, nil
+		}
+		child, removed, err := removeValue(child, rest)
+		n[token] = child
+		return n, removed, err
+	case []interface{}:
+		index, err := arrayIndex(token, len(n)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := n[index]
+			return append(n[:index], n[index+1:]...), removed, nil
+		}
+		child, removed, err := removeValue(n[index], rest)
+		n[index] = child
+		return n, removed, err
+	default:
+		return nil, nil, fmt.Errorf("cannot remove from a scalar with %q", token)
+	}
+}
+
+// copyValue deep copies a decoded JSON value so patched documents never share state.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for name, member := range v {
+			copied[name] = copyValue(member)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, element := range v {
+			copied[i] = copyValue(element)
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// patchDocument applies a patch to the JSON representation of a todo and decodes the
+// result back into it. Fields the server maintains are read-only and the patched todo
+// must pass the same validation as a full update.
+func patchDocument(todo *Todo, patch func(doc interface{}) (interface{}, error)) error {
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return NewStorageError(err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return NewStorageError(err)
+	}
+
+	if doc, err = patch(doc); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return NewInvalidInputError("Patched todo cannot be encoded")
+	}
+
+	var patched Todo
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return &TodoError{Code: ErrInvalidInput, Message: "Patched document is not a valid todo", Err: err}
+	}
+
+	var fields []FieldError
+	if patched.ID != todo.ID {
+		fields = append(fields, FieldError{Field: "id", Message: "is read-only"})
+	}
+	if patched.Version != todo.Version {
+		fields = append(fields, FieldError{Field: "version", Message: "is read-only"})
+	}
+	if patched.DeletedAt != nil {
+		fields = append(fields, FieldError{Field: "deleted_at", Message: "is read-only"})
+	}
+	if !patched.CreatedAt.Equal(todo.CreatedAt) {
+		fields = append(fields, FieldError{Field: "created_at", Message: "is read-only"})
+	}
+	if !patched.UpdatedAt.Equal(todo.UpdatedAt) {
+		fields = append(fields, FieldError{Field: "updated_at", Message: "is read-only"})
+	}
+	if !sameTime(patched.CompletedAt, todo.CompletedAt) {
+		fields = append(fields, FieldError{Field: "completed_at", Message: "is read-only"})
+	}
+	if len(fields) > 0 {
+		return NewValidationError(fields...)
+	}
+	if err := ValidateTodo(&patched); err != nil {
+		return err
+	}
+
+	*todo = patched
+	return nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
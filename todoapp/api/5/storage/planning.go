@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Priority ranks how urgent a todo is. It is written as its name in JSON and
+// the zero value, PriorityNone, is omitted.
+type Priority int
+
+// Priority levels, in increasing urgency
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+func (p Priority) String() string {
+	if p < PriorityNone || int(p) >= len(priorityNames) {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority reads a priority name such as "high".
+func ParsePriority(name string) (Priority, error) {
+	for p, candidate := range priorityNames {
+		if strings.EqualFold(name, candidate) {
+			return Priority(p), nil
+		}
+	}
+	return PriorityNone, NewInvalidInputError(fmt.Sprintf("Unknown priority %q", name))
+}
+
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("priority must be one of %s", strings.Join(priorityNames, ", "))
+	}
+	parsed, err := ParsePriority(name)
+	if err != nil {
+		return fmt.Errorf("priority must be one of %s", strings.Join(priorityNames, ", "))
+	}
+	*p = parsed
+	return nil
+}
+
+// Tag limits
+const (
+	MaxTags      = 20
+	MaxTagLength = 50 // In characters
+)
+
+const tagFieldMessage = "must be non-empty, without spaces or commas and at most %d characters"
+
+// validTag reports whether a tag can be stored: tags are single words so they can be
+// passed in query strings and comma separated lists.
+func validTag(tag string) bool {
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return false
+	}
+	return strings.IndexFunc(tag, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) < 0
+}
+
+// NormalizeTag returns the stored form of a tag, which is matched case-insensitively.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags lowercases, sorts and deduplicates tags.
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	normalized := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// HasTag reports whether the todo carries the tag, compared case-insensitively.
+func (todo *Todo) HasTag(tag string) bool {
+	tag = NormalizeTag(tag)
+	for _, candidate := range todo.Tags {
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOverdue reports whether the todo is still open past its due date.
+func (todo *Todo) IsOverdue(now time.Time) bool {
+	return !todo.Completed && todo.DueDate != nil && todo.DueDate.Before(now)
+}
+
+// normalize puts the user-editable fields in the form the stores keep them in.
+func (todo *Todo) normalize() {
+	todo.Tags = normalizeTags(todo.Tags)
+	if todo.DueDate != nil {
+		due := todo.DueDate.UTC()
+		todo.DueDate = &due
+	}
+	if rule, err := ParseRRule(todo.Recurrence); todo.Recurrence != "" && err == nil {
+		todo.Recurrence = rule.String()
+	}
+}
+
+// stamp normalizes a todo about to be written and fills in the fields the stores
+// maintain. Client supplied timestamps are ignored: CreatedAt is kept from current,
+// the stored todo before the write (nil for a new one), UpdatedAt becomes now and
+// CompletedAt records when the todo was last marked completed.
+func stamp(todo, current *Todo, now time.Time) {
+	todo.normalize()
+	todo.CreatedAt = now
+	todo.CompletedAt = nil
+	if current != nil {
+		todo.CreatedAt = current.CreatedAt
+	}
+	if todo.Completed {
+		todo.CompletedAt = &now
+		if current != nil && current.Completed && current.CompletedAt != nil {
+			todo.CompletedAt = current.CompletedAt
+		}
+	}
+	todo.UpdatedAt = now
+}
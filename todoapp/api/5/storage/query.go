@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TodoFilter narrows the todos returned by ListTodos. Zero values match every todo.
+type TodoFilter struct {
+	ListID    int       // only todos of this list, 0 for every list
+	Completed *bool     // only todos in this completion state
+	Contains  string    // case-insensitive substring of the description
+	MinID     int       // inclusive lower bound on the ID
+	MaxID     int       // inclusive upper bound on the ID, 0 for none
+	Tag       string    // only todos carrying this tag
+	Overdue   *bool     // only todos that are (or are not) open past their due date at Now
+	Now       time.Time // reference time for Overdue, the current time when zero
+}
+
+// now returns the reference time of the Overdue condition.
+func (f TodoFilter) now() time.Time {
+	if f.Now.IsZero() {
+		return SystemClock.Now()
+	}
+	return f.Now.UTC()
+}
+
+// Matches reports whether the todo passes every condition of the filter.
+func (f TodoFilter) Matches(todo *Todo) bool {
+	if f.ListID > 0 && todo.ListID != f.ListID {
+		return false
+	}
+	if f.Completed != nil && todo.Completed != *f.Completed {
+		return false
+	}
+	if f.Contains != "" && !strings.Contains(strings.ToLower(todo.Description), strings.ToLower(f.Contains)) {
+		return false
+	}
+	if f.MinID > 0 && todo.ID < f.MinID {
+		return false
+	}
+	if f.MaxID > 0 && todo.ID > f.MaxID {
+		return false
+	}
+	if f.Tag != "" && !todo.HasTag(f.Tag) {
+		return false
+	}
+	if f.Overdue != nil && todo.IsOverdue(f.now()) != *f.Overdue {
+		return false
+	}
+	return true
+}
+
+// SortField names a todo attribute that listings can be ordered by.
+type SortField string
+
+const (
+	SortByID          SortField = "id"
+	SortByDescription SortField = "description"
+	SortByCompleted   SortField = "completed"
+	SortByPriority    SortField = "priority"
+)
+
+// SortDirection is the direction of a TodoSort.
+type SortDirection string
+
+const (
+	SortAscending  SortDirection = "asc"
+	SortDescending SortDirection = "desc"
+)
+
+// TodoSort orders a listing. Ties are always broken by ID in the same direction,
+// which keeps the order total and the pagination cursor stable.
+type TodoSort struct {
+	Field     SortField
+	Direction SortDirection
+}
+
+// sortColumn describes how a SortField is read from a Todo and from the todos table.
+type sortColumn struct {
+	column string
+	// field returns a pointer to the sorted attribute, used both to compare
+	// todos and to decode the key stored in a cursor
+	field func(todo *Todo) interface{}
+}
+
+var sortColumns = map[SortField]sortColumn{
+	SortByID:          {column: "id", field: func(todo *Todo) interface{} { return &todo.ID }},
+	SortByDescription: {column: "description", field: func(todo *Todo) interface{} { return &todo.Description }},
+	SortByCompleted:   {column: "completed", field: func(todo *Todo) interface{} { return &todo.Completed }},
+	SortByPriority:    {column: "priority", field: func(todo *Todo) interface{} { return &todo.Priority }},
+}
+
+// ParseTodoSort reads a sort expression such as "description" or "-id" (descending).
+// An empty expression sorts by ascending ID.
+func ParseTodoSort(expr string) (TodoSort, error) {
+	sort := TodoSort{Field: SortByID, Direction: SortAscending}
+	if expr == "" {
+		return sort, nil
+	}
+	if strings.HasPrefix(expr, "-") {
+		sort.Direction = SortDescending
+		expr = expr[1:]
+	}
+	sort.Field = SortField(expr)
+	if _, ok := sortColumns[sort.Field]; !ok {
+		return sort, NewInvalidInputError(fmt.Sprintf("Cannot sort by %q", expr))
+	}
+	return sort, nil
+}
+
+// normalize fills in defaults and rejects unknown fields or directions.
+func (s TodoSort) normalize() (TodoSort, error) {
+	if s.Field == "" {
+		s.Field = SortByID
+	}
+	if s.Direction == "" {
+		s.Direction = SortAscending
+	}
+	if _, ok := sortColumns[s.Field]; !ok {
+		return s, NewInvalidInputError(fmt.Sprintf("Cannot sort by %q", s.Field))
+	}
+	if s.Direction != SortAscending && s.Direction != SortDescending {
+		return s, NewInvalidInputError(fmt.Sprintf("Invalid sort direction %q", s.Direction))
+	}
+	return s, nil
+}
+
+// compare orders two todos according to the sort, including the ID tie-breaker.
+func (s TodoSort) compare(a, b *Todo) int {
+	column := sortColumns[s.Field]
+	result := compareValues(column.field(a), column.field(b))
+	if result == 0 {
+		result = compareValues(&a.ID, &b.ID)
+	}
+	if s.Direction == SortDescending {
+		return -result
+	}
+	return result
+}
+
+// compareValues compares two pointers returned by a sortColumn field accessor.
+func compareValues(a, b interface{}) int {
+	switch x := a.(type) {
+	case *int:
+		y := *b.(*int)
+		switch {
+		case *x < y:
+			return -1
+		case *x > y:
+			return 1
+		}
+		return 0
+	case *Priority:
+		ix, iy := int(*x), int(*b.(*Priority))
+		return compareValues(&ix, &iy)
+	case *string:
+		return strings.Compare(*x, *b.(*string))
+	case *bool:
+		y := *b.(*bool)
+		switch {
+		case *x == y:
+			return 0
+		case !*x:
+			return -1
+		}
+		return 1
+	}
+	panic(fmt.Sprintf("storage: unsupported sort value %T", a))
+}
+
+// deref returns the value behind a sortColumn field pointer, used as a SQL argument.
+func deref(p interface{}) interface{} {
+	switch v := p.(type) {
+	case *int:
+		return *v
+	case *Priority:
+		return int(*v)
+	case *string:
+		return *v
+	case *bool:
+		return *v
+	}
+	panic(fmt.Sprintf("storage: unsupported sort value %T", p))
+}
@@ -0,0 +1,317 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a recurrence rule
+type Frequency string
+
+// Supported frequencies
+const (
+	FreqDaily   Frequency = "DAILY"
+	FreqWeekly  Frequency = "WEEKLY"
+	FreqMonthly Frequency = "MONTHLY"
+)
+
+// maxPeriods bounds the search for the next occurrence, for rules such as monthly on the
+// 31st that skip periods
+const maxPeriods = 1000
+
+var weekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"} // Indexed by time.Weekday
+
+// WeekdayNum is a BYDAY entry: a weekday and, in monthly rules, an optional ordinal
+// counting from the start of the month or, when negative, from its end (-1FR is the
+// last Friday).
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+func (w WeekdayNum) String() string {
+	if w.Ordinal != 0 {
+		return strconv.Itoa(w.Ordinal) + weekdayCodes[w.Weekday]
+	}
+	return weekdayCodes[w.Weekday]
+}
+
+// RRule is a recurrence rule in the subset of iCalendar RRULEs (RFC 5545) todos support:
+// FREQ=DAILY|WEEKLY|MONTHLY with INTERVAL, BYDAY, COUNT and UNTIL. Weeks start on Monday.
+type RRule struct {
+	Freq     Frequency
+	Interval int // Periods between occurrences, 1 when not given
+	ByDay    []WeekdayNum
+	Count    int        // Occurrences left, the current one included, 0 for no limit
+	Until    *time.Time // Last time an occurrence may fall on, in UTC
+}
+
+// ParseRRule reads a rule such as "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=10", with or without
+// the "RRULE:" prefix.
+func ParseRRule(text string) (*RRule, error) {
+	text = strings.TrimSpace(text)
+	if len(text) >= 6 && strings.EqualFold(text[:6], "RRULE:") {
+		text = text[6:]
+	}
+	rule := &RRule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(text, ";") {
+		name, value, found := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !found || name == "" || value == "" {
+			return nil, fmt.Errorf("%q is not a NAME=VALUE pair", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s is given twice", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = Frequency(value)
+			if rule.Freq != FreqDaily && rule.Freq != FreqWeekly && rule.Freq != FreqMonthly {
+				err = fmt.Errorf("FREQ must be DAILY, WEEKLY or MONTHLY")
+			}
+		case "INTERVAL":
+			rule.Interval, err = positiveInt(name, value)
+		case "COUNT":
+			rule.Count, err = positiveInt(name, value)
+		case "UNTIL":
+			rule.Until, err = parseUntil(value)
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, dayErr := parseWeekdayNum(day)
+				if dayErr != nil {
+					err = dayErr
+					break
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		default:
+			err = fmt.Errorf("%s is not supported", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("COUNT and UNTIL can't both be given")
+	}
+	for _, day := range rule.ByDay {
+		if day.Ordinal != 0 && rule.Freq != FreqMonthly {
+			return nil, fmt.Errorf("BYDAY ordinals such as %s need FREQ=MONTHLY", day)
+		}
+	}
+	return rule, nil
+}
+
+func positiveInt(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return n, nil
+}
+
+// parseUntil reads a UTC date-time such as 20240131T120000Z, or a date, which lasts
+// until the end of the day.
+func parseUntil(value string) (*time.Time, error) {
+	if until, err := time.Parse("20060102T150405Z", value); err == nil {
+		return &until, nil
+	}
+	day, err := time.Parse("20060102", value)
+	if err != nil {
+		return nil, fmt.Errorf("UNTIL must be a date such as 20240131 or a UTC time such as 20240131T120000Z")
+	}
+	until := day.Add(24*time.Hour - time.Second)
+	return &until, nil
+}
+
+// parseWeekdayNum reads a BYDAY entry such as MO, 2TU or -1FR.
+func parseWeekdayNum(value string) (WeekdayNum, error) {
+	if len(value) >= 2 {
+		code := value[len(value)-2:]
+		for weekday, candidate := range weekdayCodes {
+			if code != candidate {
+				continue
+			}
+			day := WeekdayNum{Weekday: time.Weekday(weekday)}
+			if ordinal := value[:len(value)-2]; ordinal != "" {
+				n, err := strconv.Atoi(ordinal)
+				if err != nil || n == 0 || n < -5 || n > 5 {
+					break
+				}
+				day.Ordinal = n
+			}
+			return day, nil
+		}
+	}
+	return WeekdayNum{}, fmt.Errorf("BYDAY entry %q must be a weekday such as MO, optionally numbered as in 2TU or -1FR", value)
+}
+
+// String writes the rule in its canonical form, which is how the stores keep it.
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence after the given one, at the same time of day, or
+// false when it would fall after UNTIL. COUNT is left to the caller.
+func (r *RRule) Next(after time.Time) (time.Time, bool) {
+	interval := max(r.Interval, 1)
+	for period := 0; period < maxPeriods; period++ {
+		for _, candidate := range r.candidates(after, period*interval) {
+			if !candidate.After(after) {
+				continue
+			}
+			if r.Until != nil && candidate.After(*r.Until) {
+				return time.Time{}, false
+			}
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// candidates returns the occurrences in the period offset periods after the one of
+// anchor, in order. Occurrences keep the time of day of anchor.
+func (r *RRule) candidates(anchor time.Time, offset int) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case FreqDaily:
+		day := anchor.AddDate(0, 0, offset)
+		if r.onDay(day) {
+			days = append(days, day)
+		}
+	case FreqWeekly:
+		if len(r.ByDay) == 0 {
+			return []time.Time{anchor.AddDate(0, 0, 7*offset)}
+		}
+		monday := anchor.AddDate(0, 0, 7*offset-(int(anchor.Weekday())+6)%7)
+		for i := 0; i < 7; i++ {
+			if day := monday.AddDate(0, 0, i); r.onDay(day) {
+				days = append(days, day)
+			}
+		}
+	case FreqMonthly:
+		first := time.Date(anchor.Year(), anchor.Month()+time.Month(offset), 1,
+			anchor.Hour(), anchor.Minute(), anchor.Second(), anchor.Nanosecond(), anchor.Location())
+		length := first.AddDate(0, 1, -1).Day()
+		if len(r.ByDay) == 0 {
+			// Months too short for the anchor's day are skipped, as in RFC 5545
+			if anchor.Day() <= length {
+				days = append(days, first.AddDate(0, 0, anchor.Day()-1))
+			}
+			return days
+		}
+		for d := 1; d <= length; d++ {
+			if day := first.AddDate(0, 0, d-1); r.onMonthDay(day, d, length) {
+				days = append(days, day)
+			}
+		}
+	}
+	return days
+}
+
+// onDay reports whether the weekday of day is in BYDAY, which is true when BYDAY is empty.
+func (r *RRule) onDay(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, byDay := range r.ByDay {
+		if byDay.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// onMonthDay reports whether day, the d-th of a month of the given length, matches BYDAY.
+func (r *RRule) onMonthDay(day time.Time, d, length int) bool {
+	for _, byDay := range r.ByDay {
+		if byDay.Weekday != day.Weekday() {
+			continue
+		}
+		switch {
+		case byDay.Ordinal == 0,
+			byDay.Ordinal > 0 && (d-1)/7+1 == byDay.Ordinal,
+			byDay.Ordinal < 0 && -((length-d)/7+1) == byDay.Ordinal:
+			return true
+		}
+	}
+	return false
+}
+
+// nextOccurrence returns the todo that follows a completed recurring todo: a copy due at
+// the rule's next occurrence after its due date, or after now for a todo without one.
+// It returns false once the rule's COUNT or UNTIL is reached.
+func nextOccurrence(todo *Todo, now time.Time) (*Todo, bool) {
+	rule, err := ParseRRule(todo.Recurrence)
+	if err != nil || rule.Count == 1 {
+		return nil, false
+	}
+	anchor := now
+	if todo.DueDate != nil {
+		anchor = *todo.DueDate
+	}
+	due, ok := rule.Next(anchor)
+	if !ok {
+		return nil, false
+	}
+	if rule.Count > 1 {
+		rule.Count--
+	}
+	return &Todo{
+		ListID:      todo.ListID,
+		ParentID:    todo.ParentID,
+		Description: todo.Description,
+		DueDate:     &due,
+		Priority:    todo.Priority,
+		Tags:        append([]string(nil), todo.Tags...),
+		Recurrence:  rule.String(),
+	}, true
+}
+
+// recur creates the next occurrence of a recurring todo that the change from before to
+// after completed. As with rollUp, failures are logged.
+func (t *TodoList) recur(ctx context.Context, before, after *Todo) {
+	if before == nil || before.Completed || !after.Completed || after.Recurrence == "" {
+		return
+	}
+	next, ok := nextOccurrence(after, nowFrom(t.Clock))
+	if !ok {
+		t.Logger.Info("Recurrence ended", "id", after.ID)
+		return
+	}
+	created, err := t.CreateTodo(ctx, next)
+	if err != nil {
+		t.Logger.Error("Failed to create the next occurrence", "id", after.ID, "error", err)
+		return
+	}
+	t.Logger.Info("Created the next occurrence", "id", after.ID, "next", created.ID, "due", created.DueDate)
+}
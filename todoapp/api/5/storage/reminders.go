@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Reminder asks for a nudge about a todo at a set time. A scheduler claims a due reminder
+// with ClaimReminder for the time it takes to deliver it, and marks it fired with
+// MarkReminderFired once delivered; a claim that runs out is up for another attempt.
+type Reminder struct {
+	ID           int        `json:"id"`
+	TodoID       int        `json:"todo_id"`
+	At           time.Time  `json:"at"`
+	FiredAt      *time.Time `json:"fired_at,omitempty"` // Set once the reminder has fired
+	Attempts     int        `json:"attempts,omitempty"` // Number of times the reminder was claimed for delivery
+	ClaimedUntil *time.Time `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ReminderStore defines storage operations for reminders. Reminders of trashed todos are
+// kept but never due, purging a todo drops them.
+type ReminderStore interface {
+	AddReminder(ctx context.Context, todoID int, at time.Time) (*Reminder, error)
+	// ListReminders returns the reminders of a live todo, fired or not, ordered by time
+	ListReminders(ctx context.Context, todoID int) ([]*Reminder, error)
+	DeleteReminder(ctx context.Context, todoID, id int) error
+	// DueReminders returns the reminders of live todos due at now that haven't fired and
+	// aren't claimed, oldest first
+	DueReminders(ctx context.Context, now time.Time) ([]*Reminder, error)
+	// ClaimReminder reserves a reminder for one delivery attempt until now plus lease and
+	// counts the attempt. It reports false when the reminder fired, was deleted or is
+	// claimed by someone else, so only one scheduler delivers it at a time.
+	ClaimReminder(ctx context.Context, id int, now time.Time, lease time.Duration) (bool, error)
+	// MarkReminderFired records that a reminder fired at the given time. It reports false
+	// when the reminder already fired or was deleted.
+	MarkReminderFired(ctx context.Context, id int, at time.Time) (bool, error)
+}
+
+func sortReminders(reminders []*Reminder) {
+	sort.Slice(reminders, func(i, j int) bool {
+		if !reminders[i].At.Equal(reminders[j].At) {
+			return reminders[i].At.Before(reminders[j].At)
+		}
+		return reminders[i].ID < reminders[j].ID
+	})
+}
+
+// AddReminder schedules a reminder for a todo. Times in the past fire right away.
+func (t *TodoList) AddReminder(ctx context.Context, todoID int, at time.Time) (*Reminder, error) {
+	if at.IsZero() {
+		return nil, NewValidationError(FieldError{Field: "at", Message: "must be a time such as 2024-01-31T09:00:00Z"})
+	}
+	reminder, err := t.Store.AddReminder(ctx, todoID, at.UTC())
+	if err != nil {
+		t.Logger.Error("Failed to add reminder", "id", todoID, "error", err)
+		return nil, err
+	}
+	t.Logger.Info("Added a reminder", "id", todoID, "reminder", reminder.ID, "at", reminder.At)
+	return reminder, nil
+}
+
+// ListReminders retrieves the reminders of a todo.
+func (t *TodoList) ListReminders(ctx context.Context, todoID int) ([]*Reminder, error) {
+	t.Logger.Info("Listing reminders", "id", todoID)
+	return t.Store.ListReminders(ctx, todoID)
+}
+
+// DeleteReminder cancels a reminder of a todo.
+func (t *TodoList) DeleteReminder(ctx context.Context, todoID, id int) error {
+	t.Logger.Info("Deleting a reminder", "id", todoID, "reminder", id)
+	return t.Store.DeleteReminder(ctx, todoID, id)
+}
+
+// AddReminder schedules a reminder for a live todo.
+func (s *InMemoryStore) AddReminder(ctx context.Context, todoID int, at time.Time) (*Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.live(todoID); !exists {
+		return nil, NewTodoNotFoundError(todoID)
+	}
+	reminder := &Reminder{ID: s.nextReminderID, TodoID: todoID, At: at.UTC(), CreatedAt: nowFrom(s.Clock)}
+	s.reminders[reminder.ID] = reminder
+	s.nextReminderID++
+	copied := *reminder
+	return &copied, nil
+}
+
+// ListReminders returns copies of the reminders of a live todo.
+func (s *InMemoryStore) ListReminders(ctx context.Context, todoID int) ([]*Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.live(todoID); !exists {
+		return nil, NewTodoNotFoundError(todoID)
+	}
+	reminders := []*Reminder{}
+	for _, reminder := range s.reminders {
+		if reminder.TodoID == todoID {
+			copied := *reminder
+			reminders = append(reminders, &copied)
+		}
+	}
+	sortReminders(reminders)
+	return reminders, nil
+}
+
+// DeleteReminder removes a reminder of a todo.
+func (s *InMemoryStore) DeleteReminder(ctx context.Context, todoID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reminder, exists := s.reminders[id]; !exists || reminder.TodoID != todoID {
+		return NewReminderNotFoundError(todoID, id)
+	}
+	delete(s.reminders, id)
+	return nil
+}
+
+// DueReminders returns copies of the unfired reminders of live todos due at now.
+func (s *InMemoryStore) DueReminders(ctx context.Context, now time.Time) ([]*Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*Reminder{}
+	for _, reminder := range s.reminders {
+		if reminder.FiredAt != nil || reminder.At.After(now) || reminder.claimedAt(now) {
+			continue
+		}
+		if _, exists := s.live(reminder.TodoID); exists {
+			copied := *reminder
+			due = append(due, &copied)
+		}
+	}
+	sortReminders(due)
+	return due, nil
+}
+
+// claimedAt reports whether the reminder is claimed for delivery at now.
+func (r *Reminder) claimedAt(now time.Time) bool {
+	return r.ClaimedUntil != nil && r.ClaimedUntil.After(now)
+}
+
+// ClaimReminder reserves an unfired reminder that isn't claimed yet.
+func (s *InMemoryStore) ClaimReminder(ctx context.Context, id int, now time.Time, lease time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder, exists := s.reminders[id]
+	if !exists || reminder.FiredAt != nil || reminder.claimedAt(now) {
+		return false, nil
+	}
+	claimed := *reminder
+	claimedUntil := now.Add(lease).UTC()
+	claimed.ClaimedUntil = &claimedUntil
+	claimed.Attempts++
+	s.reminders[id] = &claimed
+	return true, nil
+}
+
+// MarkReminderFired stamps a reminder that hasn't fired yet.
+func (s *InMemoryStore) MarkReminderFired(ctx context.Context, id int, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminder, exists := s.reminders[id]
+	if !exists || reminder.FiredAt != nil {
+		return false, nil
+	}
+	fired := *reminder
+	firedAt := at.UTC()
+	fired.FiredAt = &firedAt
+	s.reminders[id] = &fired
+	return true, nil
+}
+
+// forgetReminders drops the reminders of a purged todo. The store mutex must be held.
+func (s *InMemoryStore) forgetReminders(todoID int) {
+	for id, reminder := range s.reminders {
+		if reminder.TodoID == todoID {
+			delete(s.reminders, id)
+		}
+	}
+}
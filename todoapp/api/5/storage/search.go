@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Markers wrapped around matched terms in SearchResult.Snippet
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+// SearchResult is a todo matching a search query.
+// Rank is higher for more relevant results and is only comparable within one query.
+type SearchResult struct {
+	Todo    *Todo   `json:"todo"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// isTokenRune reports whether r is part of a word, mirroring FTS5's unicode61 tokenizer.
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenize splits text into lowercase words.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isTokenRune(r) })
+}
+
+// parseSearchQuery returns the terms of a query. Every term must match (as a word prefix)
+// for a todo to be returned.
+func parseSearchQuery(query string) ([]string, error) {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil, NewInvalidInputError("Search query must contain at least one word")
+	}
+	return terms, nil
+}
+
+// matchesAny reports whether the word starts with one of the terms.
+func matchesAny(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight wraps every word of text that matches one of the terms in highlight markers.
+func highlight(text string, terms []string) string {
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isTokenRune(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isTokenRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if matchesAny(strings.ToLower(word), terms) {
+			b.WriteString(HighlightStart + word + HighlightEnd)
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// searchIndex is an inverted index from words to the todos containing them.
+// It is not safe for concurrent use; InMemoryStore guards it with its mutex.
+type searchIndex struct {
+	postings map[string]map[int]int // word -> todo ID -> occurrences
+	lengths  map[int]int            // todo ID -> number of words
+	words    map[int][]string       // todo ID -> its distinct words, to remove it from postings
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{postings: map[string]map[int]int{}, lengths: map[int]int{}, words: map[int][]string{}}
+}
+
+// add indexes the description of a todo, replacing any previous entry for the ID.
+func (ix *searchIndex) add(id int, description string) {
+	ix.remove(id)
+	words := tokenize(description)
+	for _, word := range words {
+		if ix.postings[word] == nil {
+			ix.postings[word] = map[int]int{}
+		}
+		if ix.postings[word][id] == 0 {
+			ix.words[id] = append(ix.words[id], word)
+		}
+		ix.postings[word][id]++
+	}
+	ix.lengths[id] = len(words)
+}
+
+// remove drops a todo from the index.
+func (ix *searchIndex) remove(id int) {
+	if _, ok := ix.lengths[id]; !ok {
+		return
+	}
+	for _, word := range ix.words[id] {
+		docs := ix.postings[word]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(ix.postings, word)
+		}
+	}
+	delete(ix.lengths, id)
+	delete(ix.words, id)
+}
+
+// search scores the todos containing every term with a length-normalized TF-IDF,
+// returning the matching IDs from most to least relevant.
+func (ix *searchIndex) search(terms []string) ([]int, map[int]float64) {
+	scores := map[int]float64{}
+	for i, term := range terms {
+		// Collect occurrences of every word the term is a prefix of
+		frequencies := map[int]int{}
+		for word, docs := range ix.postings {
+			if strings.HasPrefix(word, term) {
+				for id, count := range docs {
+					frequencies[id] += count
+				}
+			}
+		}
+
+		idf := math.Log(1 + float64(len(ix.lengths))/float64(len(frequencies)+1))
+		next := map[int]float64{}
+		for id, count := range frequencies {
+			if _, ok := scores[id]; i > 0 && !ok {
+				continue
+			}
+			next[id] = scores[id] + float64(count)*idf/math.Sqrt(float64(ix.lengths[id]))
+		}
+		scores = next
+		if len(scores) == 0 {
+			break
+		}
+	}
+
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids, scores
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+)
+
+// AddDependency inserts a dependency unless it exists, refusing ones that close a cycle
+func (s *SQLiteTodoStore) AddDependency(ctx context.Context, todoID, blockerID int) error {
+	if todoID == blockerID {
+		return NewInvalidInputError("A todo can't be blocked by itself")
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, id := range []int{todoID, blockerID} {
+			if _, err := getTodo(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+
+		// The new dependency closes a cycle if the blocker already waits for the todo
+		upstream := `WITH RECURSIVE upstream(id) AS (
+				SELECT ?
+				UNION
+				SELECT blocker_id FROM todo_dependencies JOIN upstream ON todo_id = upstream.id
+			)
+			SELECT COUNT(*) FROM upstream WHERE id = ?`
+		var cycle int
+		if err := tx.QueryRowContext(ctx, upstream, blockerID, todoID).Scan(&cycle); err != nil {
+			return NewStorageError(err)
+		}
+		if cycle > 0 {
+			return NewDependencyCycleError(todoID, blockerID)
+		}
+
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO todo_dependencies (todo_id, blocker_id) VALUES (?, ?)", todoID, blockerID)
+		if err != nil {
+			return NewStorageError(err)
+		}
+		return nil
+	})
+}
+
+// RemoveDependency deletes a dependency
+func (s *SQLiteTodoStore) RemoveDependency(ctx context.Context, todoID, blockerID int) error {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM todo_dependencies WHERE todo_id = ? AND blocker_id = ?", todoID, blockerID)
+	if err != nil {
+		return NewStorageError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return NewStorageError(err)
+	}
+	if affected == 0 {
+		return NewDependencyNotFoundError(todoID, blockerID)
+	}
+	return nil
+}
+
+// GetDependencies fetches the live todos blocking a todo and blocked by it
+func (s *SQLiteTodoStore) GetDependencies(ctx context.Context, id int) (*Dependencies, error) {
+	if _, err := s.GetTodoByID(ctx, id); err != nil {
+		return nil, err
+	}
+	related := func(query string) ([]*Todo, error) {
+		rows, err := s.DB.QueryContext(ctx, "SELECT "+todoColumns+" FROM todos WHERE deleted_at IS NULL AND id IN ("+query+") ORDER BY id", id)
+		if err != nil {
+			return nil, NewStorageError(err)
+		}
+		todos, err := scanTodos(rows)
+		if todos == nil && err == nil {
+			todos = []*Todo{}
+		}
+		return todos, err
+	}
+
+	blockedBy, err := related("SELECT blocker_id FROM todo_dependencies WHERE todo_id = ?")
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := related("SELECT todo_id FROM todo_dependencies WHERE blocker_id = ?")
+	if err != nil {
+		return nil, err
+	}
+	return &Dependencies{BlockedBy: blockedBy, Blocks: blocks}, nil
+}
+
+// ListDependencies fetches the dependencies between live todos
+func (s *SQLiteTodoStore) ListDependencies(ctx context.Context) ([]Dependency, error) {
+	query := `SELECT d.todo_id, d.blocker_id FROM todo_dependencies d
+		JOIN todos blocked ON blocked.id = d.todo_id AND blocked.deleted_at IS NULL
+		JOIN todos blocker ON blocker.id = d.blocker_id AND blocker.deleted_at IS NULL`
+	rows, err := s.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	defer rows.Close()
+
+	dependencies := []Dependency{}
+	for rows.Next() {
+		var dependency Dependency
+		if err := rows.Scan(&dependency.TodoID, &dependency.BlockerID); err != nil {
+			return nil, NewStorageError(err)
+		}
+		dependencies = append(dependencies, dependency)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, NewStorageError(err)
+	}
+	return dependencies, nil
+}
+
+// checkUnblocked refuses to complete an open todo while todos blocking it are open
+func checkUnblocked(ctx context.Context, q queryer, id int, todo *Todo) error {
+	if !todo.Completed {
+		return nil
+	}
+	query := `SELECT d.blocker_id FROM todo_dependencies d
+		JOIN todos blocked ON blocked.id = d.todo_id
+		JOIN todos blocker ON blocker.id = d.blocker_id
+		WHERE d.todo_id = ? AND NOT blocked.completed AND blocker.deleted_at IS NULL AND NOT blocker.completed
+		ORDER BY d.blocker_id`
+	rows, err := q.QueryContext(ctx, query, id)
+	if err != nil {
+		return NewStorageError(err)
+	}
+	defer rows.Close()
+
+	var open []int
+	for rows.Next() {
+		var blockerID int
+		if err := rows.Scan(&blockerID); err != nil {
+			return NewStorageError(err)
+		}
+		open = append(open, blockerID)
+	}
+	if err := rows.Err(); err != nil {
+		return NewStorageError(err)
+	}
+	if len(open) > 0 {
+		return NewTodoBlockedError(id, open)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// SQLiteHistoryStore records changes in the todo_history table, which triggers keep append-only
+type SQLiteHistoryStore struct {
+	DB *sql.DB
+}
+
+// NewSQLiteHistoryStore uses a database migrated with NewSQLiteTodoStoreWithMigrations
+func NewSQLiteHistoryStore(db *sql.DB) *SQLiteHistoryStore {
+	return &SQLiteHistoryStore{DB: db}
+}
+
+// Append inserts a change, storing the snapshots as JSON. Called within a
+// SQLiteTodoStore.Transaction on the same database, it joins the transaction.
+func (h *SQLiteHistoryStore) Append(ctx context.Context, change *Change) error {
+	before, err := marshalSnapshot(change.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalSnapshot(change.After)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO todo_history (todo_id, action, before, after, at, actor, request_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := conn(ctx, h.DB).ExecContext(ctx, query,
+		change.TodoID, change.Action, before, after, change.At.UTC(), change.Actor, change.RequestID)
+	if err != nil {
+		return NewStorageError(err)
+	}
+	if change.Seq, err = result.LastInsertId(); err != nil {
+		return NewStorageError(err)
+	}
+	return nil
+}
+
+// ListChanges fetches the changes of a todo, oldest first
+func (h *SQLiteHistoryStore) ListChanges(ctx context.Context, todoID int) ([]*Change, error) {
+	query := `SELECT seq, todo_id, action, before, after, at, actor, request_id
+		FROM todo_history WHERE todo_id = ? ORDER BY seq`
+	rows, err := conn(ctx, h.DB).QueryContext(ctx, query, todoID)
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	defer rows.Close()
+
+	changes := []*Change{}
+	for rows.Next() {
+		var change Change
+		var before, after sql.NullString
+		err := rows.Scan(&change.Seq, &change.TodoID, &change.Action, &before, &after, &change.At, &change.Actor, &change.RequestID)
+		if err != nil {
+			return nil, NewStorageError(err)
+		}
+		if change.Before, err = unmarshalSnapshot(before); err != nil {
+			return nil, err
+		}
+		if change.After, err = unmarshalSnapshot(after); err != nil {
+			return nil, err
+		}
+		change.At = change.At.UTC()
+		changes = append(changes, &change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, NewStorageError(err)
+	}
+	return changes, nil
+}
+
+func marshalSnapshot(todo *Todo) (sql.NullString, error) {
+	if todo == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return sql.NullString{}, NewStorageError(err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func unmarshalSnapshot(data sql.NullString) (*Todo, error) {
+	if !data.Valid {
+		return nil, nil
+	}
+	var todo Todo
+	if err := json.Unmarshal([]byte(data.String), &todo); err != nil {
+		return nil, NewStorageError(err)
+	}
+	return &todo, nil
+}
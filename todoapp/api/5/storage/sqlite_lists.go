@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+)
+
+// listColumns lists the columns read by scanList, in order
+const listColumns = "id, name, color, archived, created_at, updated_at"
+
+func scanList(row rowScanner) (*List, error) {
+	var list List
+	if err := row.Scan(&list.ID, &list.Name, &list.Color, &list.Archived, &list.CreatedAt, &list.UpdatedAt); err != nil {
+		return nil, err
+	}
+	list.CreatedAt = list.CreatedAt.UTC()
+	list.UpdatedAt = list.UpdatedAt.UTC()
+	return &list, nil
+}
+
+// CreateList inserts a list
+func (s *SQLiteTodoStore) CreateList(ctx context.Context, list *List) (*List, error) {
+	var created *List
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := checkListName(ctx, tx, list.Name, 0); err != nil {
+			return err
+		}
+		now := nowFrom(s.Clock)
+		result, err := tx.ExecContext(ctx,
+			"INSERT INTO lists (name, color, archived, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+			list.Name, list.Color, list.Archived, now, now)
+		if err != nil {
+			return NewStorageError(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return NewStorageError(err)
+		}
+		created = &List{ID: int(id), Name: list.Name, Color: list.Color, Archived: list.Archived, CreatedAt: now, UpdatedAt: now}
+		return nil
+	})
+	return created, err
+}
+
+// checkListName fails with a duplicate error when a list other than except has the name, ignoring case
+func checkListName(ctx context.Context, q queryer, name string, except int) error {
+	var count int
+	err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM lists WHERE name = ? COLLATE NOCASE AND id != ?", name, except).Scan(&count)
+	if err != nil {
+		return NewStorageError(err)
+	}
+	if count > 0 {
+		return NewDuplicateListError(name)
+	}
+	return nil
+}
+
+// checkListExists fails with a not found error for an unknown list ID
+func checkListExists(ctx context.Context, q queryer, id int) error {
+	var count int
+	if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM lists WHERE id = ?", id).Scan(&count); err != nil {
+		return NewStorageError(err)
+	}
+	if count == 0 {
+		return NewListNotFoundError(id)
+	}
+	return nil
+}
+
+// GetList fetches a list by ID
+func (s *SQLiteTodoStore) GetList(ctx context.Context, id int) (*List, error) {
+	list, err := scanList(s.DB.QueryRowContext(ctx, "SELECT "+listColumns+" FROM lists WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, NewListNotFoundError(id)
+	}
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	return list, nil
+}
+
+// ListLists fetches the lists ordered by ID
+func (s *SQLiteTodoStore) ListLists(ctx context.Context, includeArchived bool) ([]*List, error) {
+	query := "SELECT " + listColumns + " FROM lists"
+	if !includeArchived {
+		query += " WHERE NOT archived"
+	}
+	rows, err := s.DB.QueryContext(ctx, query+" ORDER BY id")
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	defer rows.Close()
+
+	lists := []*List{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, NewStorageError(err)
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, NewStorageError(err)
+	}
+	return lists, nil
+}
+
+// UpdateList replaces the name, color and archived flag of a list
+func (s *SQLiteTodoStore) UpdateList(ctx context.Context, id int, list *List) (*List, error) {
+	var updated *List
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := checkListName(ctx, tx, list.Name, id); err != nil {
+			return err
+		}
+		var err error
+		updated, err = scanList(tx.QueryRowContext(ctx,
+			"UPDATE lists SET name = ?, color = ?, archived = ?, updated_at = ? WHERE id = ? RETURNING "+listColumns,
+			list.Name, list.Color, list.Archived, nowFrom(s.Clock), id))
+		if err == sql.ErrNoRows {
+			return NewListNotFoundError(id)
+		}
+		if err != nil {
+			return NewStorageError(err)
+		}
+		return nil
+	})
+	return updated, err
+}
+
+// DeleteList deletes a list without live todos and moves its trashed todos to the inbox
+func (s *SQLiteTodoStore) DeleteList(ctx context.Context, id int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := checkListExists(ctx, tx, id); err != nil {
+			return err
+		}
+		if id == DefaultListID {
+			return NewInvalidInputError("The inbox cannot be deleted")
+		}
+
+		var live int
+		err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM todos WHERE list_id = ? AND deleted_at IS NULL", id).Scan(&live)
+		if err != nil {
+			return NewStorageError(err)
+		}
+		if live > 0 {
+			return NewListNotEmptyError(id, live)
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE todos SET list_id = ? WHERE list_id = ?", DefaultListID, id); err != nil {
+			return NewStorageError(err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM lists WHERE id = ?", id); err != nil {
+			return NewStorageError(err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// reminderColumns lists the columns read by scanReminders, in order
+const reminderColumns = "id, todo_id, at, fired_at, attempts, claimed_until, created_at"
+
+// scanReminders reads every remaining row and closes rows.
+func scanReminders(rows *sql.Rows) ([]*Reminder, error) {
+	defer rows.Close()
+	reminders := []*Reminder{}
+	for rows.Next() {
+		var reminder Reminder
+		var firedAt, claimedUntil sql.NullTime
+		err := rows.Scan(&reminder.ID, &reminder.TodoID, &reminder.At, &firedAt, &reminder.Attempts, &claimedUntil, &reminder.CreatedAt)
+		if err != nil {
+			return nil, NewStorageError(err)
+		}
+		reminder.At = reminder.At.UTC()
+		reminder.FiredAt = timePtr(firedAt)
+		reminder.ClaimedUntil = timePtr(claimedUntil)
+		reminder.CreatedAt = reminder.CreatedAt.UTC()
+		reminders = append(reminders, &reminder)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, NewStorageError(err)
+	}
+	return reminders, nil
+}
+
+// AddReminder inserts a reminder for a todo that is not in the trash
+func (s *SQLiteTodoStore) AddReminder(ctx context.Context, todoID int, at time.Time) (*Reminder, error) {
+	var reminder *Reminder
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getTodo(ctx, tx, todoID); err != nil {
+			return err
+		}
+		now := nowFrom(s.Clock)
+		result, err := tx.ExecContext(ctx, "INSERT INTO todo_reminders (todo_id, at, created_at) VALUES (?, ?, ?)",
+			todoID, at.UTC(), now)
+		if err != nil {
+			return NewStorageError(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return NewStorageError(err)
+		}
+		reminder = &Reminder{ID: int(id), TodoID: todoID, At: at.UTC(), CreatedAt: now}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reminder, nil
+}
+
+// ListReminders fetches the reminders of a todo that is not in the trash
+func (s *SQLiteTodoStore) ListReminders(ctx context.Context, todoID int) ([]*Reminder, error) {
+	if _, err := getTodo(ctx, s.DB, todoID); err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.QueryContext(ctx,
+		"SELECT "+reminderColumns+" FROM todo_reminders WHERE todo_id = ? ORDER BY at, id", todoID)
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	return scanReminders(rows)
+}
+
+// DeleteReminder deletes a reminder of a todo
+func (s *SQLiteTodoStore) DeleteReminder(ctx context.Context, todoID, id int) error {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM todo_reminders WHERE id = ? AND todo_id = ?", id, todoID)
+	if err != nil {
+		return NewStorageError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return NewStorageError(err)
+	}
+	if affected == 0 {
+		return NewReminderNotFoundError(todoID, id)
+	}
+	return nil
+}
+
+// DueReminders fetches the unfired, unclaimed reminders of live todos due at now
+func (s *SQLiteTodoStore) DueReminders(ctx context.Context, now time.Time) ([]*Reminder, error) {
+	query := `SELECT ` + reminderColumns + ` FROM todo_reminders
+		WHERE fired_at IS NULL AND at <= ? AND (claimed_until IS NULL OR claimed_until <= ?)
+			AND todo_id IN (SELECT id FROM todos WHERE deleted_at IS NULL)
+		ORDER BY at, id`
+	rows, err := s.DB.QueryContext(ctx, query, now.UTC(), now.UTC())
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	return scanReminders(rows)
+}
+
+// ClaimReminder reserves a reminder unless it fired or another claim on it still runs,
+// which makes the claim atomic across processes sharing the database
+func (s *SQLiteTodoStore) ClaimReminder(ctx context.Context, id int, now time.Time, lease time.Duration) (bool, error) {
+	query := `UPDATE todo_reminders SET claimed_until = ?, attempts = attempts + 1
+		WHERE id = ? AND fired_at IS NULL AND (claimed_until IS NULL OR claimed_until <= ?)`
+	result, err := s.DB.ExecContext(ctx, query, now.Add(lease).UTC(), id, now.UTC())
+	if err != nil {
+		return false, NewStorageError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, NewStorageError(err)
+	}
+	return affected > 0, nil
+}
+
+// MarkReminderFired stamps a reminder unless it already fired
+func (s *SQLiteTodoStore) MarkReminderFired(ctx context.Context, id int, at time.Time) (bool, error) {
+	result, err := s.DB.ExecContext(ctx, "UPDATE todo_reminders SET fired_at = ? WHERE id = ? AND fired_at IS NULL", at.UTC(), id)
+	if err != nil {
+		return false, NewStorageError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, NewStorageError(err)
+	}
+	return affected > 0, nil
+}
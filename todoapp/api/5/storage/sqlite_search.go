@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// fullTextMigration creates todos_fts, an external-content FTS5 index over
+// todos.description, and the triggers that keep it in sync with the todos table
+const fullTextMigration = 12
+
+// ErrNoFullTextSearch is returned when opening a database that has the FTS5 index
+// on a SQLite library without FTS5, which go-sqlite3 only compiles in with the
+// sqlite_fts5 build tag.
+var ErrNoFullTextSearch = errors.New("the database has a full-text index but SQLite was built without FTS5, build with -tags sqlite_fts5")
+
+// SQLiteMigrations returns the embedded migrations to apply to db. When the SQLite
+// library lacks FTS5 the full-text index is left out and SearchTodos falls back to
+// an in-memory index, unless the database already has the index.
+func SQLiteMigrations(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	migrations, err := DefaultMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var available bool
+	if err := db.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&available); err != nil {
+		return nil, NewStorageError(err)
+	}
+	if available {
+		return migrations, nil
+	}
+
+	indexed, err := hasFullTextIndex(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if indexed {
+		return nil, NewStorageError(ErrNoFullTextSearch)
+	}
+	kept := migrations[:0]
+	for _, migration := range migrations {
+		if migration.Version != fullTextMigration {
+			kept = append(kept, migration)
+		}
+	}
+	return kept, nil
+}
+
+// hasFullTextIndex reports whether the full-text migration created todos_fts.
+func hasFullTextIndex(ctx context.Context, q queryer) (bool, error) {
+	var count int
+	err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'todos_fts'").Scan(&count)
+	if err != nil {
+		return false, NewStorageError(err)
+	}
+	return count > 0, nil
+}
+
+// SearchTodos ranks the todos whose descriptions contain every word of the query,
+// using FTS5's bm25 ranking and highlight function when the database has the index.
+func (s *SQLiteTodoStore) SearchTodos(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	terms, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	indexed, err := hasFullTextIndex(ctx, s.DB)
+	if err != nil {
+		return nil, err
+	}
+	if !indexed {
+		return s.searchWithoutIndex(ctx, terms, limit)
+	}
+
+	// Quote every term so user input cannot use the FTS5 query syntax, and match it as a prefix
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s, m.rank, m.snippet
+		FROM todos JOIN (
+			SELECT rowid, -bm25(todos_fts) AS rank, highlight(todos_fts, 0, '%s', '%s') AS snippet
+			FROM todos_fts WHERE todos_fts MATCH ?
+		) m ON todos.id = m.rowid
+		WHERE deleted_at IS NULL
+		ORDER BY m.rank DESC, id
+		LIMIT ?`, todoColumns, HighlightStart, HighlightEnd),
+		strings.Join(quoted, " "), limit)
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	defer rows.Close()
+
+	results := []*SearchResult{}
+	for rows.Next() {
+		result := &SearchResult{}
+		todo, err := scanTodo(rows, &result.Rank, &result.Snippet)
+		if err != nil {
+			return nil, NewStorageError(err)
+		}
+		result.Todo = todo
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, NewStorageError(err)
+	}
+	return results, nil
+}
+
+// searchWithoutIndex searches a database without the FTS5 index by indexing the
+// descriptions of the live todos in memory, the way InMemoryStore searches.
+func (s *SQLiteTodoStore) searchWithoutIndex(ctx context.Context, terms []string, limit int) ([]*SearchResult, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+todoColumns+" FROM todos WHERE deleted_at IS NULL")
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	todos, err := scanTodos(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	index := newSearchIndex()
+	byID := make(map[int]*Todo, len(todos))
+	for _, todo := range todos {
+		index.add(todo.ID, todo.Description)
+		byID[todo.ID] = todo
+	}
+	ids, scores := index.search(terms)
+	results := []*SearchResult{}
+	for _, id := range ids {
+		if len(results) == limit {
+			break
+		}
+		todo := byID[id]
+		results = append(results, &SearchResult{
+			Todo:    todo,
+			Rank:    scores[id],
+			Snippet: highlight(todo.Description, terms),
+		})
+	}
+	return results, nil
+}
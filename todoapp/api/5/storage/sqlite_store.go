@@ -3,34 +3,224 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // SQLiteTodoStore implements TodoStore using SQLite
 type SQLiteTodoStore struct {
-	DB *sql.DB
+	DB    *sql.DB
+	Clock Clock // Time source for the todo timestamps, the system clock when nil
 }
 
-// NewSQLiteTodoStore initializes a SQLite-backed store
+// NewSQLiteTodoStore initializes a SQLite-backed store on a database whose schema
+// is already migrated; see NewSQLiteTodoStoreWithMigrations
 func NewSQLiteTodoStore(db *sql.DB) *SQLiteTodoStore {
 	return &SQLiteTodoStore{DB: db}
 }
 
-// AddTodo inserts a new todo
-func (s *SQLiteTodoStore) AddTodo(ctx context.Context, description string) (*Todo, error) {
-	// Check for duplicate description
-	var count int
-	err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM todos WHERE description = ?", description).Scan(&count)
+// NewSQLiteTodoStoreWithMigrations brings the schema up to date with the embedded
+// migrations before returning the store, so callers no longer create tables by hand.
+func NewSQLiteTodoStoreWithMigrations(ctx context.Context, db *sql.DB) (*SQLiteTodoStore, error) {
+	migrations, err := SQLiteMigrations(ctx, db)
 	if err != nil {
+		return nil, err
+	}
+	if err := NewMigrator(db, migrations).Up(ctx); err != nil {
+		return nil, err
+	}
+	return NewSQLiteTodoStore(db), nil
+}
+
+// todoColumns lists the columns read by scanTodo, in order. Tags are aggregated into a
+// comma separated list, which is unambiguous because tags cannot contain commas.
+const todoColumns = "id, list_id, parent_id, description, completed, due_date, priority, recurrence, " +
+	"(SELECT group_concat(tag, ',') FROM todo_tags WHERE todo_id = todos.id), " +
+	"version, created_at, updated_at, completed_at, deleted_at"
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTodo reads the todoColumns of a row, followed by any extra destinations.
+func scanTodo(row rowScanner, extra ...interface{}) (*Todo, error) {
+	var todo Todo
+	var tags sql.NullString
+	var dueDate, createdAt, updatedAt, completedAt, deletedAt sql.NullTime
+	dest := append([]interface{}{
+		&todo.ID, &todo.ListID, &todo.ParentID, &todo.Description, &todo.Completed, &dueDate, &todo.Priority, &todo.Recurrence, &tags,
+		&todo.Version, &createdAt, &updatedAt, &completedAt, &deletedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if tags.Valid {
+		todo.Tags = normalizeTags(strings.Split(tags.String, ","))
+	}
+	todo.DueDate = timePtr(dueDate)
+	todo.CreatedAt = createdAt.Time.UTC()
+	todo.UpdatedAt = updatedAt.Time.UTC()
+	todo.CompletedAt = timePtr(completedAt)
+	todo.DeletedAt = timePtr(deletedAt)
+	return &todo, nil
+}
+
+// timePtr converts a nullable timestamp column to UTC, nil for NULL.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+// scanTodos reads every remaining row and closes rows.
+func scanTodos(rows *sql.Rows) ([]*Todo, error) {
+	defer rows.Close()
+
+	var todos []*Todo
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, NewStorageError(err)
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
 		return nil, NewStorageError(err)
 	}
-	if count > 0 {
-		return nil, NewDuplicateTodoError(description)
+	return todos, nil
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx, so the same statements can run
+// on their own or inside a batch transaction
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txKey is the context key of the transaction a Transaction runs in
+type txKey struct{}
+
+// contextTx is a transaction carried in a context, with the database it belongs to
+type contextTx struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+// joinedTx returns the transaction ctx carries for db, if any
+func joinedTx(ctx context.Context, db *sql.DB) (*sql.Tx, bool) {
+	current, ok := ctx.Value(txKey{}).(*contextTx)
+	if !ok || current.db != db {
+		return nil, false
+	}
+	return current.tx, true
+}
+
+// conn returns the transaction ctx carries for db, or db itself. Since the database
+// has a single connection, statements made inside a Transaction must run on it.
+func conn(ctx context.Context, db *sql.DB) queryer {
+	if tx, ok := joinedTx(ctx, db); ok {
+		return tx
+	}
+	return db
+}
+
+// Transaction runs fn in a transaction that is committed when fn succeeds. The store's
+// methods and the SQLiteHistoryStore of the same database join it when given fn's context.
+func (s *SQLiteTodoStore) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := joinedTx(ctx, s.DB); ok {
+		return fn(ctx)
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, &contextTx{db: s.DB, tx: tx}))
+	})
+}
+
+// inTx runs fn in a transaction that is committed when fn succeeds. Inside a Transaction,
+// fn runs in a savepoint instead, so a failed write is undone on its own.
+func (s *SQLiteTodoStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if tx, ok := joinedTx(ctx, s.DB); ok {
+		return inSavepoint(ctx, tx, fn)
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return NewStorageError(err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return NewStorageError(err)
+	}
+	return nil
+}
+
+// inSavepoint runs fn in a savepoint of tx that is rolled back when fn fails
+func inSavepoint(ctx context.Context, tx *sql.Tx, fn func(tx *sql.Tx) error) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT store_write"); err != nil {
+		return NewStorageError(err)
+	}
+	if err := fn(tx); err != nil {
+		tx.ExecContext(ctx, "ROLLBACK TO store_write")
+		tx.ExecContext(ctx, "RELEASE store_write")
+		return err
 	}
+	if _, err := tx.ExecContext(ctx, "RELEASE store_write"); err != nil {
+		return NewStorageError(err)
+	}
+	return nil
+}
+
+// AddTodo inserts a new todo
+func (s *SQLiteTodoStore) AddTodo(ctx context.Context, description string) (*Todo, error) {
+	return s.CreateTodo(ctx, NewTodo(description))
+}
+
+// CreateTodo inserts a todo and its tags
+func (s *SQLiteTodoStore) CreateTodo(ctx context.Context, newTodo *Todo) (*Todo, error) {
+	var todo *Todo
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		todo, err = addTodo(ctx, tx, newTodo, nowFrom(s.Clock))
+		return err
+	})
+	return todo, err
+}
 
-	query := "INSERT INTO todos (description, completed) VALUES (?, ?)"
-	result, err := s.DB.ExecContext(ctx, query, description, false)
+func addTodo(ctx context.Context, q queryer, newTodo *Todo, now time.Time) (*Todo, error) {
+	todo := *newTodo
+	if err := placeTodo(ctx, q, 0, &todo); err != nil {
+		return nil, err
+	}
+	if todo.ListID == 0 {
+		todo.ListID = DefaultListID
+		if err := checkListExists(ctx, q, todo.ListID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkDuplicate(ctx, q, 0, todo.ListID, todo.Description); err != nil {
+		return nil, err
+	}
+
+	todo.Version = 1
+	todo.DeletedAt = nil
+	stamp(&todo, nil, now)
+
+	query := `INSERT INTO todos (list_id, parent_id, description, completed, due_date, priority, recurrence, created_at, updated_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := q.ExecContext(ctx, query, todo.ListID, todo.ParentID, todo.Description, todo.Completed, todo.DueDate, todo.Priority, todo.Recurrence,
+		todo.CreatedAt, todo.UpdatedAt, todo.CompletedAt)
 	if err != nil {
 		return nil, NewStorageError(err)
 	}
@@ -39,80 +229,439 @@ func (s *SQLiteTodoStore) AddTodo(ctx context.Context, description string) (*Tod
 	if err != nil {
 		return nil, NewStorageError(err)
 	}
+	todo.ID = int(id)
 
-	return &Todo{ID: int(id), Description: description, Completed: false}, nil
+	if err := setTags(ctx, q, todo.ID, todo.Tags); err != nil {
+		return nil, err
+	}
+	return &todo, nil
 }
 
-// GetAllTodos fetches all todos
+// checkDuplicate reports a duplicate when an open todo of the list that is not in the trash,
+// other than excludeID, has the description.
+func checkDuplicate(ctx context.Context, q queryer, excludeID, listID int, description string) error {
+	var count int
+	err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM todos WHERE list_id = ? AND description = ? AND NOT completed AND deleted_at IS NULL AND id != ?",
+		listID, description, excludeID).Scan(&count)
+	if err != nil {
+		return NewStorageError(err)
+	}
+	if count > 0 {
+		return NewDuplicateTodoError(description)
+	}
+	return nil
+}
+
+// setTags replaces the tags of a todo.
+func setTags(ctx context.Context, q queryer, id int, tags []string) error {
+	if _, err := q.ExecContext(ctx, "DELETE FROM todo_tags WHERE todo_id = ?", id); err != nil {
+		return NewStorageError(err)
+	}
+	for _, tag := range tags {
+		if _, err := q.ExecContext(ctx, "INSERT INTO todo_tags (todo_id, tag) VALUES (?, ?)", id, tag); err != nil {
+			return NewStorageError(err)
+		}
+	}
+	return nil
+}
+
+// GetAllTodos fetches all todos that are not in the trash
 func (s *SQLiteTodoStore) GetAllTodos(ctx context.Context) ([]*Todo, error) {
-	rows, err := s.DB.QueryContext(ctx, "SELECT id, description, completed FROM todos")
+	rows, err := conn(ctx, s.DB).QueryContext(ctx, "SELECT "+todoColumns+" FROM todos WHERE deleted_at IS NULL ORDER BY id")
 	if err != nil {
 		return nil, NewStorageError(err)
 	}
-	defer rows.Close()
+	return scanTodos(rows)
+}
 
-	var todos []*Todo
-	for rows.Next() {
-		var todo Todo
-		if err := rows.Scan(&todo.ID, &todo.Description, &todo.Completed); err != nil {
-			return nil, NewStorageError(err)
+// ListTodos compiles the filter and sort into parameterized SQL and fetches the requested page
+func (s *SQLiteTodoStore) ListTodos(ctx context.Context, opts ListOptions) (*TodoPage, error) {
+	opts, anchor, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := compileTodoFilter(opts.Filter)
+	where = append(where, "deleted_at IS NULL")
+	column := sortColumns[opts.Sort.Field].column
+	direction, comparison := "ASC", ">"
+	if opts.Sort.Direction == SortDescending {
+		direction, comparison = "DESC", "<"
+	}
+
+	// Keyset pagination: continue strictly after the (sort key, id) of the cursor
+	if anchor != nil {
+		if opts.Sort.Field == SortByID {
+			where = append(where, "id "+comparison+" ?")
+			args = append(args, anchor.ID)
+		} else {
+			key := deref(sortColumns[opts.Sort.Field].field(anchor))
+			where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, comparison))
+			args = append(args, key, key, anchor.ID)
 		}
-		todos = append(todos, &todo)
 	}
-	return todos, nil
+
+	query := "SELECT " + todoColumns + " FROM todos WHERE " + strings.Join(where, " AND ")
+	query += fmt.Sprintf(" ORDER BY %s %s", column, direction)
+	if opts.Sort.Field != SortByID {
+		query += ", id " + direction
+	}
+	// Fetch one extra row to find out whether another page follows
+	query += " LIMIT ?"
+	args = append(args, opts.Limit+1)
+
+	rows, err := conn(ctx, s.DB).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	todos, err := scanTodos(rows)
+	if err != nil {
+		return nil, err
+	}
+	return newTodoPage(todos, opts), nil
 }
 
-// GetTodoByID fetches a todo by ID
+// compileTodoFilter turns a TodoFilter into SQL conditions and their arguments.
+func compileTodoFilter(filter TodoFilter) ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if filter.ListID > 0 {
+		where = append(where, "list_id = ?")
+		args = append(args, filter.ListID)
+	}
+	if filter.Completed != nil {
+		where = append(where, "completed = ?")
+		args = append(args, *filter.Completed)
+	}
+	if filter.Contains != "" {
+		where = append(where, "instr(lower(description), lower(?)) > 0")
+		args = append(args, filter.Contains)
+	}
+	if filter.MinID > 0 {
+		where = append(where, "id >= ?")
+		args = append(args, filter.MinID)
+	}
+	if filter.MaxID > 0 {
+		where = append(where, "id <= ?")
+		args = append(args, filter.MaxID)
+	}
+	if filter.Tag != "" {
+		where = append(where, "id IN (SELECT todo_id FROM todo_tags WHERE tag = ?)")
+		args = append(args, NormalizeTag(filter.Tag))
+	}
+	if filter.Overdue != nil {
+		overdue := "(NOT completed AND due_date IS NOT NULL AND due_date < ?)"
+		if !*filter.Overdue {
+			overdue = "NOT " + overdue
+		}
+		where = append(where, overdue)
+		args = append(args, filter.now())
+	}
+	return where, args
+}
+
+// GetTodoByID fetches a todo by ID, trashed todos are reported as not found
 func (s *SQLiteTodoStore) GetTodoByID(ctx context.Context, id int) (*Todo, error) {
-	query := "SELECT id, description, completed FROM todos WHERE id = ?"
-	row := s.DB.QueryRowContext(ctx, query, id)
+	return getTodo(ctx, conn(ctx, s.DB), id)
+}
 
-	var todo Todo
-	if err := row.Scan(&todo.ID, &todo.Description, &todo.Completed); err != nil {
+func getTodo(ctx context.Context, q queryer, id int) (*Todo, error) {
+	query := "SELECT " + todoColumns + " FROM todos WHERE id = ? AND deleted_at IS NULL"
+	todo, err := scanTodo(q.QueryRowContext(ctx, query, id))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, NewTodoNotFoundError(id)
 		}
 		return nil, NewStorageError(err)
 	}
-	return &todo, nil
+	return todo, nil
 }
 
-// UpdateTodoByID updates a todo
+// UpdateTodoByID updates a todo that is not in the trash, bumping its version
 func (s *SQLiteTodoStore) UpdateTodoByID(ctx context.Context, id int, updatedTodo *Todo) error {
-	// Check if todo exists
-	var count int
-	err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM todos WHERE id = ?", id).Scan(&count)
-	if err != nil {
-		return NewStorageError(err)
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return updateTodo(ctx, tx, id, updatedTodo, nowFrom(s.Clock))
+	})
+}
+
+// updateTodo writes the user-editable fields of updatedTodo and fills in the ones the store maintains.
+// A zero ListID keeps the todo in its list, or moves it to the list of its new parent.
+func updateTodo(ctx context.Context, q queryer, id int, updatedTodo *Todo, now time.Time) error {
+	if err := placeTodo(ctx, q, id, updatedTodo); err != nil {
+		return err
 	}
-	if count == 0 {
-		return NewTodoNotFoundError(id)
+	if err := checkUnblocked(ctx, q, id, updatedTodo); err != nil {
+		return err
+	}
+	if !updatedTodo.Completed {
+		listID := updatedTodo.ListID
+		if listID == 0 {
+			// A todo that is missing is reported by the update below
+			err := q.QueryRowContext(ctx, "SELECT list_id FROM todos WHERE id = ? AND deleted_at IS NULL", id).Scan(&listID)
+			if err != nil && err != sql.ErrNoRows {
+				return NewStorageError(err)
+			}
+		}
+		if err := checkDuplicate(ctx, q, id, listID, updatedTodo.Description); err != nil {
+			return err
+		}
 	}
 
-	query := "UPDATE todos SET description = ?, completed = ? WHERE id = ?"
-	_, err = s.DB.ExecContext(ctx, query, updatedTodo.Description, updatedTodo.Completed, id)
+	// completed_at keeps the time the todo was first marked completed until it is reopened
+	query := `UPDATE todos SET list_id = CASE WHEN ? = 0 THEN list_id ELSE ? END, parent_id = ?,
+			description = ?, completed = ?, due_date = ?, priority = ?, recurrence = ?, updated_at = ?,
+			completed_at = CASE WHEN NOT ? THEN NULL WHEN completed AND completed_at IS NOT NULL THEN completed_at ELSE ? END,
+			version = version + 1
+		WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+		RETURNING list_id, version, created_at, completed_at`
+	updatedTodo.normalize()
+	expected := updatedTodo.Version
+	var listID, version int
+	var createdAt, completedAt sql.NullTime
+	err := q.QueryRowContext(ctx, query,
+		updatedTodo.ListID, updatedTodo.ListID, updatedTodo.ParentID,
+		updatedTodo.Description, updatedTodo.Completed, updatedTodo.DueDate, updatedTodo.Priority, updatedTodo.Recurrence, now,
+		updatedTodo.Completed, now,
+		id, expected, expected,
+	).Scan(&listID, &version, &createdAt, &completedAt)
+	if err == sql.ErrNoRows {
+		return explainMiss(ctx, q, id, expected)
+	}
 	if err != nil {
 		return NewStorageError(err)
 	}
+	if err := setTags(ctx, q, id, updatedTodo.Tags); err != nil {
+		return err
+	}
+
+	updatedTodo.ID = id
+	updatedTodo.ListID = listID
+	updatedTodo.Version = version
+	updatedTodo.CreatedAt = createdAt.Time.UTC()
+	updatedTodo.UpdatedAt = now
+	updatedTodo.CompletedAt = timePtr(completedAt)
+	updatedTodo.DeletedAt = nil
 	return nil
 }
 
-// DeleteTodoByID deletes a todo
+// PatchTodoByID reads the current todo, applies patch and writes the result in one transaction
+func (s *SQLiteTodoStore) PatchTodoByID(ctx context.Context, id int, patch TodoPatch) (*Todo, error) {
+	var todo *Todo
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		todo, err = getTodo(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		version := todo.Version
+
+		if err := patch(todo); err != nil {
+			return err
+		}
+		todo.ID = id
+
+		// Conditioning the write on the version read guards against a writer that committed in between
+		todo.Version = version
+		return updateTodo(ctx, tx, id, todo, nowFrom(s.Clock))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// DeleteTodoByID moves a todo to the trash
 func (s *SQLiteTodoStore) DeleteTodoByID(ctx context.Context, id int) error {
-	// Check if todo exists
-	var count int
-	err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM todos WHERE id = ?", id).Scan(&count)
+	return s.DeleteTodoByIDAtVersion(ctx, id, 0)
+}
+
+// DeleteTodoByIDAtVersion moves a todo to the trash if it is at the given version, 0 matches any
+func (s *SQLiteTodoStore) DeleteTodoByIDAtVersion(ctx context.Context, id, version int) error {
+	_, err := s.DeleteTodoTree(ctx, id, version, DeleteRestrict)
+	return err
+}
+
+// DeleteTodoTree moves a todo to the trash and applies the delete rule to its subtasks in one transaction
+func (s *SQLiteTodoStore) DeleteTodoTree(ctx context.Context, id, version int, rule DeleteRule) ([]SubtaskChange, error) {
+	var subtasks []SubtaskChange
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		subtasks, err = deleteTodo(ctx, tx, id, version, rule, nowFrom(s.Clock))
+		return err
+	})
+	return subtasks, err
+}
+
+// deleteTodo trashes a todo after applying the delete rule to its subtasks, and returns
+// the subtasks it changed
+func deleteTodo(ctx context.Context, q queryer, id, version int, rule DeleteRule, deletedAt time.Time) ([]SubtaskChange, error) {
+	todo, err := getTodo(ctx, q, id)
 	if err != nil {
-		return NewStorageError(err)
+		return nil, err
 	}
-	if count == 0 {
-		return NewTodoNotFoundError(id)
+	changes, err := clearSubtasks(ctx, q, todo, rule, deletedAt)
+	if err != nil {
+		return nil, err
 	}
 
-	query := "DELETE FROM todos WHERE id = ?"
-	_, err = s.DB.ExecContext(ctx, query, id)
+	query := "UPDATE todos SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)"
+	result, err := q.ExecContext(ctx, query, deletedAt, id, version, version)
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	if affected == 0 {
+		return nil, explainMiss(ctx, q, id, version)
+	}
+	return changes, nil
+}
+
+// explainMiss tells why a conditional write matched no row: the todo is missing
+// or trashed, or it moved past the expected version.
+func explainMiss(ctx context.Context, q queryer, id, expected int) error {
+	todo, err := getTodo(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	return NewVersionConflictError(id, expected, todo.Version)
+}
+
+// ApplyBatch runs every operation in one transaction, which is rolled back when one fails
+func (s *SQLiteTodoStore) ApplyBatch(ctx context.Context, ops []BatchOp) ([]*BatchResult, error) {
+	if err := validateBatch(ops); err != nil {
+		return nil, err
+	}
+
+	results := make([]*BatchResult, 0, len(ops))
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		now := nowFrom(s.Clock)
+		for i, op := range ops {
+			var err error
+			result := &BatchResult{Op: op.Op, ID: op.ID}
+			switch op.Op {
+			case BatchCreate:
+				result.Todo, err = addTodo(ctx, tx, op.Todo(), now)
+			case BatchUpdate:
+				result.Todo = op.Todo()
+				err = updateTodo(ctx, tx, op.ID, result.Todo, now)
+			case BatchDelete:
+				result.Subtasks, err = deleteTodo(ctx, tx, op.ID, op.Version, op.OnDelete, now)
+			}
+			if err != nil {
+				return batchOpError(i, op, err)
+			}
+			if result.Todo != nil {
+				result.ID = result.Todo.ID
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListTrash fetches the trashed todos, most recently deleted first
+func (s *SQLiteTodoStore) ListTrash(ctx context.Context) ([]*Todo, error) {
+	query := "SELECT " + todoColumns + " FROM todos WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC"
+	rows, err := conn(ctx, s.DB).QueryContext(ctx, query)
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	todos, err := scanTodos(rows)
+	if todos == nil && err == nil {
+		todos = []*Todo{}
+	}
+	return todos, err
+}
+
+// RestoreTodoByID takes a todo out of the trash as a new version, into the inbox when its list is gone
+func (s *SQLiteTodoStore) RestoreTodoByID(ctx context.Context, id int) (*Todo, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var listID int
+		var description string
+		var completed bool
+		err := tx.QueryRowContext(ctx, "SELECT list_id, description, completed FROM todos WHERE id = ? AND deleted_at IS NOT NULL", id).
+			Scan(&listID, &description, &completed)
+		if err == sql.ErrNoRows {
+			return NewTodoNotFoundError(id)
+		}
+		if err != nil {
+			return NewStorageError(err)
+		}
+		if err := checkListExists(ctx, tx, listID); errors.Is(err, ErrListNotFound) {
+			listID = DefaultListID
+		} else if err != nil {
+			return err
+		}
+		if !completed {
+			if err := checkDuplicate(ctx, tx, id, listID, description); err != nil {
+				return err
+			}
+		}
+
+		// A subtask whose parent is gone, or now in another list, comes back at the top level
+		query := `UPDATE todos SET deleted_at = NULL, list_id = ?, version = version + 1, updated_at = ?,
+				parent_id = CASE WHEN EXISTS (
+					SELECT 1 FROM todos AS parent
+					WHERE parent.id = todos.parent_id AND parent.deleted_at IS NULL AND parent.list_id = ?
+				) THEN parent_id ELSE 0 END
+			WHERE id = ? AND deleted_at IS NOT NULL`
+		result, err := tx.ExecContext(ctx, query, listID, nowFrom(s.Clock), listID, id)
+		return expectAffected(result, err, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetTodoByID(ctx, id)
+}
+
+// PurgeTodoByID permanently deletes a trashed todo
+func (s *SQLiteTodoStore) PurgeTodoByID(ctx context.Context, id int) error {
+	result, err := conn(ctx, s.DB).ExecContext(ctx, "DELETE FROM todos WHERE id = ? AND deleted_at IS NOT NULL", id)
+	return expectAffected(result, err, id)
+}
+
+// PurgeDeletedBefore permanently deletes the todos trashed before the cutoff
+func (s *SQLiteTodoStore) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) ([]int, error) {
+	rows, err := conn(ctx, s.DB).QueryContext(ctx, "DELETE FROM todos WHERE deleted_at IS NOT NULL AND deleted_at < ? RETURNING id", cutoff.UTC())
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	defer rows.Close()
+
+	purged := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, NewStorageError(err)
+		}
+		purged = append(purged, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, NewStorageError(err)
+	}
+	sort.Ints(purged)
+	return purged, nil
+}
+
+// expectAffected turns the result of a statement targeting one todo into
+// a not found error when no row matched.
+func expectAffected(result sql.Result, err error, id int) error {
+	if err != nil {
+		return NewStorageError(err)
+	}
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return NewStorageError(err)
 	}
+	if affected == 0 {
+		return NewTodoNotFoundError(id)
+	}
 	return nil
 }
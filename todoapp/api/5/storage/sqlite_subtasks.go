@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetTodoTree fetches a todo and its live descendants with a recursive query
+func (s *SQLiteTodoStore) GetTodoTree(ctx context.Context, id int) (*TodoNode, error) {
+	// UNION rather than UNION ALL stops the recursion even if the tree had a cycle
+	query := `WITH RECURSIVE tree(id) AS (
+			SELECT id FROM todos WHERE id = ? AND deleted_at IS NULL
+			UNION
+			SELECT todos.id FROM todos JOIN tree ON todos.parent_id = tree.id WHERE todos.deleted_at IS NULL
+		)
+		SELECT ` + todoColumns + ` FROM todos WHERE id IN (SELECT id FROM tree)`
+	rows, err := s.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	todos, err := scanTodos(rows)
+	if err != nil {
+		return nil, err
+	}
+	return buildTree(id, todos)
+}
+
+// placeTodo checks the list and parent of a todo about to be written under id, 0 for a
+// new todo. A subtask without a ListID goes to its parent's list and must stay there,
+// and its parent can't be one of its own subtasks.
+func placeTodo(ctx context.Context, q queryer, id int, todo *Todo) error {
+	if todo.ParentID != 0 {
+		parent, err := getTodo(ctx, q, todo.ParentID)
+		if errors.Is(err, ErrTodoNotFound) {
+			return parentError(fmt.Sprintf("todo %d does not exist", todo.ParentID))
+		}
+		if err != nil {
+			return err
+		}
+		if todo.ListID == 0 {
+			todo.ListID = parent.ListID
+		}
+		if todo.ListID != parent.ListID {
+			return parentError("must be in the same list")
+		}
+		if id != 0 {
+			ancestors := `WITH RECURSIVE ancestors(id) AS (
+					SELECT ?
+					UNION
+					SELECT parent_id FROM todos JOIN ancestors USING (id) WHERE parent_id != 0
+				)
+				SELECT COUNT(*) FROM ancestors WHERE id = ?`
+			var cycle int
+			if err := q.QueryRowContext(ctx, ancestors, todo.ParentID, id).Scan(&cycle); err != nil {
+				return NewStorageError(err)
+			}
+			if cycle > 0 {
+				return parentError("must not be the todo itself or one of its subtasks")
+			}
+		}
+	}
+	if todo.ListID == 0 {
+		return nil
+	}
+	if err := checkListExists(ctx, q, todo.ListID); err != nil {
+		return err
+	}
+	if id != 0 {
+		moved, err := countSubtasks(ctx, q, id, "list_id != ?", todo.ListID)
+		if err != nil {
+			return err
+		}
+		if moved > 0 {
+			return NewHasSubtasksError(id, moved)
+		}
+	}
+	return nil
+}
+
+// countSubtasks counts the live subtasks of a todo, optionally matching a condition
+func countSubtasks(ctx context.Context, q queryer, id int, condition string, args ...interface{}) (int, error) {
+	query := "SELECT COUNT(*) FROM todos WHERE parent_id = ? AND deleted_at IS NULL"
+	if condition != "" {
+		query += " AND " + condition
+	}
+	var count int
+	if err := q.QueryRowContext(ctx, query, append([]interface{}{id}, args...)...).Scan(&count); err != nil {
+		return 0, NewStorageError(err)
+	}
+	return count, nil
+}
+
+// clearSubtasks applies the delete rule to the subtasks of a todo about to be trashed
+func clearSubtasks(ctx context.Context, q queryer, todo *Todo, rule DeleteRule, now time.Time) ([]SubtaskChange, error) {
+	query := "SELECT " + todoColumns + " FROM todos WHERE parent_id = ? AND deleted_at IS NULL ORDER BY id"
+	rows, err := q.QueryContext(ctx, query, todo.ID)
+	if err != nil {
+		return nil, NewStorageError(err)
+	}
+	subtasks, err := scanTodos(rows)
+	if err != nil || len(subtasks) == 0 {
+		return nil, err
+	}
+
+	var changes []SubtaskChange
+	switch rule {
+	case DeleteCascade:
+		for _, subtask := range subtasks {
+			nested, err := deleteTodo(ctx, q, subtask.ID, 0, rule, now)
+			if err != nil {
+				return nil, err
+			}
+			trashed := *subtask
+			trashed.DeletedAt = &now
+			changes = append(append(changes, nested...), SubtaskChange{Before: subtask, After: &trashed})
+		}
+	case DeletePromote:
+		for _, subtask := range subtasks {
+			promoted := *subtask
+			promoted.ParentID = todo.ParentID
+			if err := updateTodo(ctx, q, subtask.ID, &promoted, now); err != nil {
+				return nil, err
+			}
+			changes = append(changes, SubtaskChange{Before: subtask, After: &promoted})
+		}
+	default:
+		return nil, NewHasSubtasksError(todo.ID, len(subtasks))
+	}
+	return changes, nil
+}
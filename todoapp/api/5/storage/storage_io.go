@@ -16,7 +16,17 @@ type StorageIOInterface interface {
 }
 
 // StorageIO abstracts file I/O operations for easier testing.
-type StorageIO struct{}
+type StorageIO struct {
+	Registry *CodecRegistry // The formats Download and Upload support, DefaultCodecs when nil
+}
+
+// Codecs returns the registry of the formats todos are read and written in.
+func (s *StorageIO) Codecs() *CodecRegistry {
+	if s.Registry == nil {
+		return DefaultCodecs()
+	}
+	return s.Registry
+}
 
 // OpenFile opens a file with the given path and flag.
 func (s *StorageIO) OpenFile(path string, flag int, perm os.FileMode) (*os.File, error) {
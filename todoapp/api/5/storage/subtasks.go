@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// TodoNode is a todo with its subtasks, as returned by GetTodoTree.
+type TodoNode struct {
+	Todo
+	Subtasks []*TodoNode `json:"subtasks"` // Ordered by ID, empty for a leaf
+}
+
+// buildTree nests todos under the todo rootID. Todos that don't descend from it are ignored.
+func buildTree(rootID int, todos []*Todo) (*TodoNode, error) {
+	children := map[int][]*Todo{}
+	var root *Todo
+	for _, todo := range todos {
+		if todo.ID == rootID {
+			root = todo
+		} else {
+			children[todo.ParentID] = append(children[todo.ParentID], todo)
+		}
+	}
+	if root == nil {
+		return nil, NewTodoNotFoundError(rootID)
+	}
+
+	var nest func(todo *Todo) *TodoNode
+	nest = func(todo *Todo) *TodoNode {
+		node := &TodoNode{Todo: *todo, Subtasks: []*TodoNode{}}
+		subtasks := children[todo.ID]
+		sort.Slice(subtasks, func(i, j int) bool { return subtasks[i].ID < subtasks[j].ID })
+		for _, subtask := range subtasks {
+			node.Subtasks = append(node.Subtasks, nest(subtask))
+		}
+		return node
+	}
+	return nest(root), nil
+}
+
+// parentError rejects the parent_id of a todo.
+func parentError(message string) error {
+	return NewValidationError(FieldError{Field: "parent_id", Message: message})
+}
+
+// DeleteRule decides what happens to the subtasks of a todo that is deleted.
+type DeleteRule string
+
+// Delete rules
+const (
+	DeleteCascade  DeleteRule = "cascade"  // The subtasks go to the trash with their parent
+	DeletePromote  DeleteRule = "promote"  // The subtasks move up to the deleted todo's parent
+	DeleteRestrict DeleteRule = "restrict" // Todos with subtasks cannot be deleted
+)
+
+// ParseDeleteRule reads a delete rule name such as "cascade".
+func ParseDeleteRule(name string) (DeleteRule, error) {
+	switch rule := DeleteRule(name); rule {
+	case DeleteCascade, DeletePromote, DeleteRestrict:
+		return rule, nil
+	}
+	return "", NewInvalidInputError(fmt.Sprintf("Unknown delete rule %q, expected cascade, promote or restrict", name))
+}
+
+// SubtaskChange is a subtask as it was before and after the delete of an ancestor
+// trashed or promoted it.
+type SubtaskChange struct {
+	Before *Todo
+	After  *Todo
+}
+
+// SubtaskOptions configures how a TodoList maintains its todo trees.
+type SubtaskOptions struct {
+	OnDelete DeleteRule // DeleteCascade when empty
+	// RollUp completes a parent once all its subtasks are completed and reopens it
+	// when one of them is reopened or added open
+	RollUp bool
+}
+
+// GetTodoTree retrieves a todo with its subtasks, nested to any depth.
+func (t *TodoList) GetTodoTree(ctx context.Context, id int) (*TodoNode, error) {
+	t.Logger.Info("Getting a todo tree", "id", id)
+	return t.Store.GetTodoTree(ctx, id)
+}
+
+// deleteRule is the rule the stores apply to the subtasks of a deleted todo.
+func (t *TodoList) deleteRule() DeleteRule {
+	if t.Subtasks.OnDelete == "" {
+		return DeleteCascade
+	}
+	return t.Subtasks.OnDelete
+}
+
+// recordSubtasks records the subtasks a delete trashed or promoted.
+func (c *changeLog) recordSubtasks(ctx context.Context, subtasks []SubtaskChange) error {
+	for _, subtask := range subtasks {
+		var err error
+		if subtask.After.DeletedAt != nil {
+			err = c.record(ctx, ActionDelete, subtask.Before.ID, subtask.Before, nil)
+		} else {
+			err = c.record(ctx, ActionUpdate, subtask.Before.ID, subtask.Before, subtask.After)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollUp brings the completion of parentID in line with its subtasks when roll-up is
+// enabled, and carries the change on to the parent's own parent. It runs after the change
+// that triggered it has been applied, as a change of its own, so failures are only logged.
+func (t *TodoList) rollUp(ctx context.Context, parentID int) {
+	for t.Subtasks.RollUp && parentID != 0 {
+		tree, err := t.Store.GetTodoTree(ctx, parentID)
+		if err != nil {
+			t.Logger.Error("Failed to roll up subtask completion", "id", parentID, "error", err)
+			return
+		}
+		if len(tree.Subtasks) == 0 {
+			return
+		}
+		completed := true
+		for _, subtask := range tree.Subtasks {
+			completed = completed && subtask.Completed
+		}
+		if tree.Completed == completed {
+			return
+		}
+
+		// Pinned to the version read, a concurrent change to the parent wins
+		parent := tree.Todo
+		parent.Completed = completed
+		if _, err := t.updateTodo(ctx, parentID, &parent); err != nil {
+			t.Logger.Error("Failed to roll up subtask completion", "id", parentID, "error", err)
+			return
+		}
+		t.Logger.Info("Rolled up subtask completion", "id", parentID, "completed", completed)
+		t.recur(ctx, &tree.Todo, &parent)
+		parentID = parent.ParentID
+	}
+}
@@ -2,9 +2,13 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"sync"
+	"time"
 )
 
 // TodoList represents a set of todos backed by a storage implementation.
@@ -12,18 +16,38 @@ type TodoList struct {
 	Logger    *slog.Logger
 	Store     TodoStore // Can be SQLite or InMemoryStore
 	StorageIO StorageIOInterface
+	Clock     Clock        // Time source for background work and history timestamps
+	History   HistoryStore // Audit log of every change, nothing is recorded when nil
+	Events    *EventBus    // Change feed, nothing is published when nil
+	Subtasks  SubtaskOptions
+	writeMu   sync.Mutex // Held by write, so changes are recorded and published in order
 }
 
 // Todo struct represents a task with an ID and a description
 type Todo struct {
-	ID          int    `json:"id"`
-	Description string `json:"description"`
-	Completed   bool   `json:"completed"`
+	ID          int        `json:"id"`
+	ListID      int        `json:"list_id"`             // The list the todo belongs to, DefaultListID when created without one
+	ParentID    int        `json:"parent_id,omitempty"` // The todo this is a subtask of, in the same list, 0 for none
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	Priority    Priority   `json:"priority,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`       // Lowercase and sorted, see NormalizeTag
+	Recurrence  string     `json:"recurrence,omitempty"` // An RRULE, see ParseRRule
+	Version     int        `json:"version"`              // Incremented by every update, starting at 1
+	CreatedAt   time.Time  `json:"created_at"`           // Set by the stores, as are UpdatedAt and CompletedAt
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // Set while the todo is completed
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`   // Set while the todo is in the trash
 }
 
 type Options struct {
-	Logger *slog.Logger
-	Store  TodoStore
+	Logger   *slog.Logger
+	Store    TodoStore
+	Clock    Clock
+	History  HistoryStore // Defaults to an in-memory history without a file
+	Events   *EventBus
+	Subtasks SubtaskOptions
 }
 
 // TodoList represents a set of todos
@@ -41,29 +65,56 @@ func NewTodo(description string) *Todo {
 
 // NewTodoListWithOptions creates a TodoList with custom storage.
 func NewTodoListWithOptions(options Options) *TodoList {
-	if options.Store == nil {
-		options.Store = NewInMemoryStore() // Assume you have an in-memory implementation
-	}
 	if options.Logger == nil {
 		options.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
 	}
+	if options.Clock == nil {
+		options.Clock = SystemClock
+	}
+	if options.Store == nil {
+		store := NewInMemoryStore()
+		store.Clock = options.Clock
+		options.Store = store
+	}
+	if options.History == nil {
+		options.History, _ = NewMemoryHistoryStore(DefaultHistoryCapacity, "")
+	}
+	if options.Events == nil {
+		options.Events = NewEventBus(DefaultEventReplay)
+	}
 	return &TodoList{
 		Logger:    options.Logger,
 		Store:     options.Store,
-		StorageIO: &StorageIO{}, // Default StorageIO instance
+		StorageIO: &StorageIO{Registry: DefaultCodecs()}, // Default StorageIO instance
+		Clock:     options.Clock,
+		History:   options.History,
+		Events:    options.Events,
+		Subtasks:  options.Subtasks,
 	}
 }
 
 // AddTodo adds a new todo using the configured storage backend.
 func (t *TodoList) AddTodo(ctx context.Context, description string) (*Todo, error) {
-	todo, err := t.Store.AddTodo(ctx, description)
+	return t.CreateTodo(ctx, NewTodo(description))
+}
+
+// CreateTodo adds a todo with the description, completion, due date, priority and tags of newTodo.
+func (t *TodoList) CreateTodo(ctx context.Context, newTodo *Todo) (*Todo, error) {
+	var todo *Todo
+	err := t.write(ctx, func(ctx context.Context, changes *changeLog) error {
+		var err error
+		if todo, err = t.Store.CreateTodo(ctx, newTodo); err != nil {
+			return err
+		}
+		return changes.record(ctx, ActionCreate, todo.ID, nil, todo)
+	})
 	if err != nil {
 		t.Logger.Error("Failed to add todo", "error", err)
 		return nil, err
 	}
 	t.Logger.Info("Added a todo", "id", todo.ID)
+	t.rollUp(ctx, todo.ParentID)
 	return todo, nil
-
 }
 
 // GetAllTodos retrieves all todos.
@@ -73,6 +124,15 @@ func (t *TodoList) GetAllTodos(ctx context.Context) ([]*Todo, error) {
 	return todos, err
 }
 
+// ListTodos retrieves a page of todos. Overdue filters are evaluated at the list's clock.
+func (t *TodoList) ListTodos(ctx context.Context, opts ListOptions) (*TodoPage, error) {
+	t.Logger.Info("Listing todos", "limit", opts.Limit, "cursor", opts.Cursor)
+	if opts.Filter.Now.IsZero() {
+		opts.Filter.Now = nowFrom(t.Clock)
+	}
+	return t.Store.ListTodos(ctx, opts)
+}
+
 // GetTodoByID retrieves a todo by ID.
 func (t *TodoList) GetTodoByID(ctx context.Context, id int) (*Todo, error) {
 	t.Logger.Info("Getting a todo", "id", id)
@@ -83,13 +143,203 @@ func (t *TodoList) GetTodoByID(ctx context.Context, id int) (*Todo, error) {
 // UpdateTodoByID updates a todo by its ID.
 func (t *TodoList) UpdateTodoByID(ctx context.Context, id int, updatedTodo *Todo) error {
 	t.Logger.Info("Updating a todo", "id", id)
-	return t.Store.UpdateTodoByID(ctx, id, updatedTodo)
+	before, err := t.updateTodo(ctx, id, updatedTodo)
+	if err != nil {
+		return err
+	}
+	t.recur(ctx, before, updatedTodo)
+	t.rollUp(ctx, before.ParentID)
+	t.rollUp(ctx, updatedTodo.ParentID)
+	return nil
 }
 
-// DeleteTodoByID deletes a todo by ID.
+// maxConflictRetries bounds how often an unconditional write that lost a race is retried
+// before its version conflict is returned
+const maxConflictRetries = 5
+
+// updateTodo updates and records a todo without rolling up its parents' completion,
+// and returns the todo as it was before.
+func (t *TodoList) updateTodo(ctx context.Context, id int, updatedTodo *Todo) (*Todo, error) {
+	var before *Todo
+	err := t.write(ctx, func(ctx context.Context, changes *changeLog) error {
+		// The write is pinned to the version read for the history's before snapshot, an
+		// unconditional update that lost a race with a writer bypassing the TodoList is retried
+		for retries := 0; ; retries++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var err error
+			before, err = t.Store.GetTodoByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			attempt := *updatedTodo
+			if attempt.Version == 0 {
+				attempt.Version = before.Version
+			}
+			err = t.Store.UpdateTodoByID(ctx, id, &attempt)
+			if updatedTodo.Version == 0 && errors.Is(err, ErrVersionConflict) && retries < maxConflictRetries {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			attempt.ID = id
+			if err := changes.record(ctx, ActionUpdate, id, before, &attempt); err != nil {
+				return err
+			}
+			*updatedTodo = attempt
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return before, nil
+}
+
+// PatchTodoByID applies a partial update to the current state of a todo.
+func (t *TodoList) PatchTodoByID(ctx context.Context, id int, patch TodoPatch) (*Todo, error) {
+	t.Logger.Info("Patching a todo", "id", id)
+
+	// The store hands the patch the current todo, which is the before snapshot
+	var before Todo
+	var todo *Todo
+	err := t.write(ctx, func(ctx context.Context, changes *changeLog) error {
+		var err error
+		todo, err = t.Store.PatchTodoByID(ctx, id, func(todo *Todo) error {
+			before = *todo
+			return patch(todo)
+		})
+		if err != nil {
+			return err
+		}
+		return changes.record(ctx, ActionUpdate, id, &before, todo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.recur(ctx, &before, todo)
+	t.rollUp(ctx, before.ParentID)
+	t.rollUp(ctx, todo.ParentID)
+	return todo, nil
+}
+
+// DeleteTodoByID moves a todo to the trash.
 func (t *TodoList) DeleteTodoByID(ctx context.Context, id int) error {
-	t.Logger.Info("Deleting a todo", "id", id)
-	return t.Store.DeleteTodoByID(ctx, id)
+	return t.DeleteTodoByIDAtVersion(ctx, id, 0)
+}
+
+// DeleteTodoByIDAtVersion moves a todo to the trash only while it is still at the given version.
+// The store applies the Subtasks.OnDelete rule to its subtasks in the same transaction.
+func (t *TodoList) DeleteTodoByIDAtVersion(ctx context.Context, id, version int) error {
+	t.Logger.Info("Deleting a todo", "id", id, "version", version)
+
+	var before *Todo
+	err := t.write(ctx, func(ctx context.Context, changes *changeLog) error {
+		// As in updateTodo, the delete is pinned to the version of the before snapshot
+		for retries := 0; ; retries++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var err error
+			before, err = t.Store.GetTodoByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			expected := version
+			if expected == 0 {
+				expected = before.Version
+			}
+			subtasks, err := t.Store.DeleteTodoTree(ctx, id, expected, t.deleteRule())
+			if version == 0 && errors.Is(err, ErrVersionConflict) && retries < maxConflictRetries {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := changes.recordSubtasks(ctx, subtasks); err != nil {
+				return err
+			}
+			return changes.record(ctx, ActionDelete, id, before, nil)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	t.rollUp(ctx, before.ParentID)
+	return nil
+}
+
+// ApplyBatch applies a set of creates, updates and deletes atomically.
+func (t *TodoList) ApplyBatch(ctx context.Context, ops []BatchOp) ([]*BatchResult, error) {
+	t.Logger.Info("Applying a batch", "operations", len(ops))
+
+	var results []*BatchResult
+	var parents []int
+	var updates [][2]*Todo // Before and after each update, for recurrences
+	err := t.write(ctx, func(ctx context.Context, changes *changeLog) error {
+		// Before snapshots are read ahead of the batch, under the write lock
+		befores := map[int]*Todo{}
+		ops := append([]BatchOp(nil), ops...)
+		for i, op := range ops {
+			if op.Op == BatchUpdate || op.Op == BatchDelete {
+				if todo, err := t.Store.GetTodoByID(ctx, op.ID); err == nil {
+					befores[op.ID] = todo
+				}
+			}
+			if op.Op == BatchDelete {
+				ops[i].OnDelete = t.deleteRule()
+			}
+		}
+
+		var err error
+		if results, err = t.Store.ApplyBatch(ctx, ops); err != nil {
+			return err
+		}
+		for _, result := range results {
+			before := befores[result.ID]
+			switch result.Op {
+			case BatchCreate:
+				err = changes.record(ctx, ActionCreate, result.ID, nil, result.Todo)
+			case BatchUpdate:
+				err = changes.record(ctx, ActionUpdate, result.ID, before, result.Todo)
+				updates = append(updates, [2]*Todo{before, result.Todo})
+				befores[result.ID] = result.Todo
+			case BatchDelete:
+				if err = changes.recordSubtasks(ctx, result.Subtasks); err == nil {
+					err = changes.record(ctx, ActionDelete, result.ID, before, nil)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			if before != nil {
+				parents = append(parents, before.ParentID)
+			}
+			if result.Todo != nil {
+				parents = append(parents, result.Todo.ParentID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Logger.Error("Failed to apply batch", "error", err)
+		return nil, err
+	}
+	for _, update := range updates {
+		t.recur(ctx, update[0], update[1])
+	}
+	for _, parentID := range parents {
+		t.rollUp(ctx, parentID)
+	}
+	return results, nil
+}
+
+// SearchTodos runs a full-text search over todo descriptions.
+func (t *TodoList) SearchTodos(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	t.Logger.Info("Searching todos", "query", query)
+	return t.Store.SearchTodos(ctx, query, limit)
 }
 
 func (t *TodoList) Download(ctx context.Context, path string) error {
@@ -108,7 +358,12 @@ func (t *TodoList) Download(ctx context.Context, path string) error {
 	}
 	defer file.Close()
 
-	if err := t.StorageIO.EncodeJSON(file, todos); err != nil {
+	codec, err := t.withLists(ctx, t.codecFor(path), false)
+	if err != nil {
+		t.Logger.Error("Failed to retrieve lists for download", "error", err)
+		return err
+	}
+	if err := codec.Encode(file, todos); err != nil {
 		t.Logger.Error("Failed to write todos to file", "error", err)
 		return err
 	}
@@ -127,21 +382,139 @@ func (t *TodoList) Upload(ctx context.Context, path string) error {
 	}
 	defer file.Close()
 
-	var todos []*Todo
-	if err := t.StorageIO.DecodeJSON(file, &todos); err != nil {
+	result, err := t.ImportFrom(ctx, file, t.codecFor(path))
+	if err != nil {
 		t.Logger.Error("Failed to parse todos from file", "error", err)
 		return err
 	}
 
-	for _, todo := range todos {
-		_, err := t.Store.AddTodo(ctx, todo.Description)
+	t.Logger.Info("Successfully uploaded todos from file", "path", path, "created", result.Created, "skipped", result.Skipped)
+	return nil
+}
+
+// ImportFrom decodes todos from r with the codec and imports them. Decoding failures are
+// invalid input, and an import that skipped every todo fails with ErrImportRejected.
+func (t *TodoList) ImportFrom(ctx context.Context, r io.Reader, codec Codec) (*ImportResult, error) {
+	codec, err := t.withLists(ctx, codec, true)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := codec.Decode(r)
+	if err != nil {
+		var todoErr *TodoError
+		if errors.As(err, &todoErr) && todoErr.Code != ErrInvalidInput {
+			return nil, err
+		}
+		return nil, &TodoError{Code: ErrInvalidInput, Message: "Failed to parse todos", Err: err}
+	}
+	result, err := t.Import(ctx, todos)
+	if err != nil {
+		return nil, err
+	}
+	if result.Created == 0 && result.Skipped > 0 {
+		return result, NewImportRejectedError(result.Errors)
+	}
+	return result, nil
+}
+
+// withLists hands a ListAware codec the lists of the store, to name them.
+// On import, create also has it create the lists the file names that don't exist yet.
+func (t *TodoList) withLists(ctx context.Context, codec Codec, create bool) (Codec, error) {
+	aware, ok := codec.(ListAware)
+	if !ok {
+		return codec, nil
+	}
+	lists, err := t.Store.ListLists(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	var createList func(name string) (*List, error)
+	if create {
+		createList = func(name string) (*List, error) {
+			return t.CreateList(ctx, &List{Name: name})
+		}
+	}
+	return aware.WithLists(lists, createList), nil
+}
+
+// Codecs returns the formats of the StorageIO, only JSON when it doesn't provide any.
+func (t *TodoList) Codecs() *CodecRegistry {
+	if provider, ok := t.StorageIO.(CodecProvider); ok {
+		return provider.Codecs()
+	}
+	return NewCodecRegistry(&JSONCodec{IO: t.StorageIO})
+}
+
+// codecFor returns the codec Download and Upload use for a file, picked by its
+// extension. Files with other extensions are JSON.
+func (t *TodoList) codecFor(path string) Codec {
+	if codec, ok := t.Codecs().ForPath(path); ok {
+		return codec
+	}
+	return &JSONCodec{IO: t.StorageIO}
+}
+
+// ImportResult tells how many todos an import created and why it skipped the others.
+type ImportResult struct {
+	Created int `json:"created"`
+	Skipped int `json:"skipped"`
+	// Errors has the reasons for the skipped todos, their fields qualified with the
+	// position of the todo, such as todos[2].description
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// skip counts a todo the import left out, at index among the decoded todos.
+func (r *ImportResult) skip(index int, err error) {
+	r.Skipped++
+	cause := Classify(err)
+	if len(cause.Fields) == 0 {
+		r.Errors = append(r.Errors, FieldError{Field: fmt.Sprintf("todos[%d]", index), Message: cause.Error()})
+	}
+	for _, field := range cause.Fields {
+		field.Field = fmt.Sprintf("todos[%d].%s", index, field.Field)
+		r.Errors = append(r.Errors, field)
+	}
+}
+
+// Import creates the valid todos, keeping everything but their IDs, versions and
+// timestamps. Invalid todos and todos the store rejects are skipped. Todos of lists
+// that don't exist go to the inbox, subtasks are attached to the new ID of their
+// parent, or created at the top level when their parent isn't imported before them.
+func (t *TodoList) Import(ctx context.Context, todos []*Todo) (*ImportResult, error) {
+	lists, err := t.Store.ListLists(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	known := map[int]bool{}
+	for _, list := range lists {
+		known[list.ID] = true
+	}
+
+	result := &ImportResult{}
+	ids := map[int]int{}
+	for i, todo := range todos {
+		if err := ValidateTodo(todo); err != nil {
+			t.Logger.Error("Skipping invalid todo", "id", todo.ID, "error", err)
+			result.skip(i, err)
+			continue
+		}
+		if todo.ListID != 0 && !known[todo.ListID] {
+			t.Logger.Info("Importing a todo of an unknown list to the inbox", "id", todo.ID, "list", todo.ListID)
+			todo.ListID = DefaultListID
+		}
+		todo.ParentID = ids[todo.ParentID]
+		added, err := t.CreateTodo(ctx, todo)
 		if err != nil {
 			t.Logger.Error("Failed to add todo", "id", todo.ID, "error", err)
+			result.skip(i, err)
+			continue
 		}
+		if todo.ID != 0 {
+			ids[todo.ID] = added.ID
+		}
+		result.Created++
 	}
-
-	t.Logger.Info("Successfully uploaded todos from file", "path", path)
-	return nil
+	return result, nil
 }
 
 // Disable logging by setting output to io.Discard
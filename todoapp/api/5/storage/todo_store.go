@@ -1,12 +1,54 @@
 package storage
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // TodoStore defines storage operations for todos.
+//
+// Updates use optimistic concurrency: when updatedTodo.Version is non-zero the update only
+// applies if the stored todo is still at that version, otherwise it fails with ErrVersionConflict.
+// On success updatedTodo.Version is set to the new version.
 type TodoStore interface {
+	ListStore
+	DependencyStore
+	ReminderStore
+
 	AddTodo(ctx context.Context, description string) (*Todo, error)
+	// CreateTodo adds a todo with the user-editable fields of newTodo, the stores assign
+	// the ID, version and timestamps
+	CreateTodo(ctx context.Context, newTodo *Todo) (*Todo, error)
 	GetAllTodos(ctx context.Context) ([]*Todo, error)
+	ListTodos(ctx context.Context, opts ListOptions) (*TodoPage, error)
 	GetTodoByID(ctx context.Context, id int) (*Todo, error)
+	// GetTodoTree returns a todo with its subtasks, trashed todos are left out
+	GetTodoTree(ctx context.Context, id int) (*TodoNode, error)
 	UpdateTodoByID(ctx context.Context, id int, updatedTodo *Todo) error
-	DeleteTodoByID(ctx context.Context, id int) error
+	PatchTodoByID(ctx context.Context, id int, patch TodoPatch) (*Todo, error)
+	DeleteTodoByID(ctx context.Context, id int) error // Moves the todo to the trash, refused while it has subtasks
+	DeleteTodoByIDAtVersion(ctx context.Context, id, version int) error
+	// DeleteTodoTree moves a todo at the given version, 0 matches any, to the trash and applies
+	// rule to its subtasks in the same transaction. It returns the subtasks it trashed or
+	// promoted, descendants before their parents.
+	DeleteTodoTree(ctx context.Context, id, version int, rule DeleteRule) ([]SubtaskChange, error)
+	SearchTodos(ctx context.Context, query string, limit int) ([]*SearchResult, error)
+
+	// ApplyBatch applies the operations all-or-nothing and returns one result per operation.
+	// When an operation fails nothing is written and the error names the failing operation.
+	ApplyBatch(ctx context.Context, ops []BatchOp) ([]*BatchResult, error)
+
+	// Trash operations, trashed todos are hidden from every other method
+	ListTrash(ctx context.Context) ([]*Todo, error)
+	RestoreTodoByID(ctx context.Context, id int) (*Todo, error)
+	PurgeTodoByID(ctx context.Context, id int) error
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) ([]int, error) // Returns the IDs of the purged todos
+}
+
+// Transactional is implemented by stores that can make several writes atomic. TodoList
+// runs each change in a Transaction, so the change and its history commit together.
+type Transactional interface {
+	// Transaction runs fn in a transaction that the store's methods called with the
+	// context fn is given join, and commits it when fn succeeds
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// todo.txt (https://github.com/todotxt/todo.txt) export and import, one todo per line:
+//
+//	x 2024-01-05 2024-01-01 Book flights @travel +trip due:2024-01-10 pri:B
+//	(A) 2024-01-02 Renew passport list:2 id:4 rrule:FREQ=MONTHLY
+//
+// A completed todo starts with x and its completion date, any todo with its creation
+// date. Priorities A to D stand for urgent, high, medium and low, and completed todos keep
+// theirs as pri:. @context tokens are tags and +project tokens are tags starting with +,
+// so every tag survives a round trip. Everything else the format has no syntax for is
+// written as key:value extensions: due:, list:, id: and parent: for subtasks, and rrule:
+// for recurrence. The simpler rec: of other tools, such as rec:2w, is read too.
+
+// todoTxtPriorities maps priorities onto todo.txt letters
+var todoTxtPriorities = map[Priority]string{
+	PriorityUrgent: "A",
+	PriorityHigh:   "B",
+	PriorityMedium: "C",
+	PriorityLow:    "D",
+}
+
+var (
+	todoTxtPriorityPattern = regexp.MustCompile(`^\([A-Z]\)$`)
+	todoTxtDate            = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	todoTxtRec             = regexp.MustCompile(`^\+?(\d+)([dwmy])$`)
+)
+
+// TodoTxtCodec reads and writes todos as todo.txt lines.
+type TodoTxtCodec struct{}
+
+func (TodoTxtCodec) MediaType() string    { return "text/plain" }
+func (TodoTxtCodec) Extensions() []string { return []string{".txt"} }
+
+func (TodoTxtCodec) Encode(w io.Writer, todos []*Todo) error {
+	parents := map[int]bool{}
+	for _, todo := range todos {
+		parents[todo.ParentID] = true
+	}
+	out := bufio.NewWriter(w)
+	for _, todo := range todos {
+		if _, err := io.WriteString(out, todoTxtLine(todo, parents[todo.ID])+"\n"); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// todoTxtLine writes a todo as one line, with an id: when it has subtasks to refer to it.
+func todoTxtLine(todo *Todo, hasSubtasks bool) string {
+	fields := []string{}
+	if todo.Completed {
+		fields = append(fields, "x")
+		if todo.CompletedAt != nil {
+			fields = append(fields, todo.CompletedAt.UTC().Format(time.DateOnly))
+		}
+	} else if letter, ok := todoTxtPriorities[todo.Priority]; ok {
+		fields = append(fields, "("+letter+")")
+	}
+	if !todo.CreatedAt.IsZero() && (!todo.Completed || todo.CompletedAt != nil) {
+		// A lone date after x is read as the completion date
+		fields = append(fields, todo.CreatedAt.UTC().Format(time.DateOnly))
+	}
+	fields = append(fields, strings.Fields(todo.Description)...)
+
+	for _, tag := range todo.Tags {
+		if strings.HasPrefix(tag, "+") {
+			fields = append(fields, tag)
+		} else {
+			fields = append(fields, "@"+tag)
+		}
+	}
+	if todo.DueDate != nil {
+		due := todo.DueDate.UTC()
+		if due.Equal(due.Truncate(24 * time.Hour)) {
+			fields = append(fields, "due:"+due.Format(time.DateOnly))
+		} else {
+			fields = append(fields, "due:"+due.Format(time.RFC3339))
+		}
+	}
+	if letter, ok := todoTxtPriorities[todo.Priority]; ok && todo.Completed {
+		fields = append(fields, "pri:"+letter)
+	}
+	if todo.ListID != 0 && todo.ListID != DefaultListID {
+		fields = append(fields, "list:"+strconv.Itoa(todo.ListID))
+	}
+	if hasSubtasks && todo.ID != 0 {
+		fields = append(fields, "id:"+strconv.Itoa(todo.ID))
+	}
+	if todo.ParentID != 0 {
+		fields = append(fields, "parent:"+strconv.Itoa(todo.ParentID))
+	}
+	if todo.Recurrence != "" {
+		fields = append(fields, "rrule:"+todo.Recurrence)
+	}
+	return strings.Join(fields, " ")
+}
+
+func (TodoTxtCodec) Decode(r io.Reader) ([]*Todo, error) {
+	todos := []*Todo{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" {
+			continue
+		}
+		todo, err := parseTodoTxtLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		todos = append(todos, todo)
+	}
+	return todos, scanner.Err()
+}
+
+// parseTodoTxtLine reads one todo. Unknown key:value extensions stay in the description.
+func parseTodoTxtLine(line string) (*Todo, error) {
+	todo := &Todo{}
+	fields := strings.Fields(line)
+	if fields[0] == "x" {
+		todo.Completed = true
+		fields = fields[1:]
+	} else if todoTxtPriorityPattern.MatchString(fields[0]) {
+		todo.Priority = priorityFromLetter(fields[0][1])
+		fields = fields[1:]
+	}
+
+	dates := []time.Time{}
+	for len(fields) > 0 && len(dates) < 2 && todoTxtDate.MatchString(fields[0]) {
+		date, err := time.Parse(time.DateOnly, fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a date", fields[0])
+		}
+		dates = append(dates, date)
+		fields = fields[1:]
+		if !todo.Completed {
+			break
+		}
+	}
+	if todo.Completed && len(dates) > 0 {
+		todo.CompletedAt = &dates[0]
+		dates = dates[1:]
+	}
+	if len(dates) > 0 {
+		todo.CreatedAt = dates[0]
+	}
+
+	words := []string{}
+	for _, field := range fields {
+		if len(field) > 1 && field[0] == '@' {
+			todo.Tags = append(todo.Tags, field[1:])
+			continue
+		}
+		if len(field) > 1 && field[0] == '+' {
+			todo.Tags = append(todo.Tags, field)
+			continue
+		}
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			words = append(words, field)
+			continue
+		}
+		known, err := setTodoTxtExtension(todo, key, value)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			words = append(words, field)
+		}
+	}
+	todo.Description = strings.Join(words, " ")
+	return todo, nil
+}
+
+// setTodoTxtExtension sets the field a key:value extension stands for, reporting whether
+// the key is one todos have a field for.
+func setTodoTxtExtension(todo *Todo, key, value string) (bool, error) {
+	var err error
+	switch key {
+	case "due":
+		due, err := parseCSVTime("due", value)
+		if err != nil {
+			return true, err
+		}
+		todo.DueDate = due
+	case "pri":
+		if !todoTxtPriorityPattern.MatchString("(" + value + ")") {
+			return true, fmt.Errorf("pri:%s is not a priority from A to Z", value)
+		}
+		todo.Priority = priorityFromLetter(value[0])
+	case "list", "id", "parent":
+		n, convErr := strconv.Atoi(value)
+		if convErr != nil || n <= 0 {
+			return true, fmt.Errorf("%s:%s is not an ID", key, value)
+		}
+		switch key {
+		case "list":
+			todo.ListID = n
+		case "id":
+			todo.ID = n
+		default:
+			todo.ParentID = n
+		}
+	case "rrule":
+		todo.Recurrence = value
+	case "rec":
+		todo.Recurrence, err = todoTxtRecurrence(value)
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// priorityFromLetter maps a todo.txt letter onto a priority, D to Z being low.
+func priorityFromLetter(letter byte) Priority {
+	for priority, candidate := range todoTxtPriorities {
+		if candidate[0] == letter {
+			return priority
+		}
+	}
+	return PriorityLow
+}
+
+// todoTxtRecurrence turns a rec: value such as 2w or +1m into a rule. Years are 12 months.
+func todoTxtRecurrence(value string) (string, error) {
+	match := todoTxtRec.FindStringSubmatch(value)
+	if match == nil {
+		return "", fmt.Errorf("rec:%s is not a recurrence such as 1d, 2w or 1m", value)
+	}
+	interval, err := strconv.Atoi(match[1])
+	if err != nil || interval <= 0 {
+		return "", fmt.Errorf("rec:%s is not a recurrence such as 1d, 2w or 1m", value)
+	}
+	rule := &RRule{Interval: interval}
+	switch match[2] {
+	case "d":
+		rule.Freq = FreqDaily
+	case "w":
+		rule.Freq = FreqWeekly
+	case "m":
+		rule.Freq = FreqMonthly
+	case "y":
+		rule.Freq = FreqMonthly
+		rule.Interval *= 12
+	}
+	return rule.String(), nil
+}
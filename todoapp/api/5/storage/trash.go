@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// ListTrash retrieves the todos in the trash.
+func (t *TodoList) ListTrash(ctx context.Context) ([]*Todo, error) {
+	t.Logger.Info("Listing trashed todos")
+	return t.Store.ListTrash(ctx)
+}
+
+// RestoreTodoByID takes a todo out of the trash.
+func (t *TodoList) RestoreTodoByID(ctx context.Context, id int) (*Todo, error) {
+	t.Logger.Info("Restoring a todo", "id", id)
+	var todo *Todo
+	err := t.write(ctx, func(ctx context.Context, changes *changeLog) error {
+		var err error
+		if todo, err = t.Store.RestoreTodoByID(ctx, id); err != nil {
+			return err
+		}
+		return changes.record(ctx, ActionRestore, id, nil, todo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.rollUp(ctx, todo.ParentID)
+	return todo, nil
+}
+
+// PurgeTodoByID permanently deletes a todo that is in the trash.
+func (t *TodoList) PurgeTodoByID(ctx context.Context, id int) error {
+	t.Logger.Info("Purging a todo", "id", id)
+	return t.write(ctx, func(ctx context.Context, changes *changeLog) error {
+		if err := t.Store.PurgeTodoByID(ctx, id); err != nil {
+			return err
+		}
+		return changes.record(ctx, ActionPurge, id, nil, nil)
+	})
+}
+
+// PurgeExpiredTrash permanently deletes the todos that have been in the trash longer than retention.
+// Each purge is recorded and published like one made with PurgeTodoByID.
+func (t *TodoList) PurgeExpiredTrash(ctx context.Context, retention time.Duration) (int, error) {
+	cutoff := nowFrom(t.Clock).Add(-retention)
+	var purged []int
+	err := t.write(ctx, func(ctx context.Context, changes *changeLog) error {
+		var err error
+		if purged, err = t.Store.PurgeDeletedBefore(ctx, cutoff); err != nil {
+			return err
+		}
+		for _, id := range purged {
+			if err := changes.record(ctx, ActionPurge, id, nil, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Logger.Error("Failed to purge expired trash", "error", err)
+		return 0, err
+	}
+	if len(purged) > 0 {
+		t.Logger.Info("Purged expired trash", "count", len(purged), "cutoff", cutoff)
+	}
+	return len(purged), nil
+}
+
+// StartTrashSweeper purges expired trash every interval in a background goroutine
+// until ctx is cancelled. The returned channel is closed once the sweeper has stopped.
+func (t *TodoList) StartTrashSweeper(ctx context.Context, retention, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.PurgeExpiredTrash(ctx, retention)
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxDescriptionLength bounds the length of a todo description, in characters
+const MaxDescriptionLength = 1000
+
+// ValidateTodo checks the user-supplied fields of a todo and reports every invalid one.
+func ValidateTodo(todo *Todo) error {
+	var fields []FieldError
+	switch {
+	case strings.TrimSpace(todo.Description) == "":
+		fields = append(fields, FieldError{Field: "description", Message: "must not be empty"})
+	case utf8.RuneCountInString(todo.Description) > MaxDescriptionLength:
+		fields = append(fields, FieldError{Field: "description", Message: fmt.Sprintf("must be at most %d characters", MaxDescriptionLength)})
+	}
+	if todo.ListID < 0 {
+		fields = append(fields, FieldError{Field: "list_id", Message: "must not be negative"})
+	}
+	if todo.ParentID < 0 {
+		fields = append(fields, FieldError{Field: "parent_id", Message: "must not be negative"})
+	}
+	if todo.Priority < PriorityNone || todo.Priority > PriorityUrgent {
+		fields = append(fields, FieldError{Field: "priority", Message: "must be one of " + strings.Join(priorityNames, ", ")})
+	}
+	if len(todo.Tags) > MaxTags {
+		fields = append(fields, FieldError{Field: "tags", Message: fmt.Sprintf("must have at most %d entries", MaxTags)})
+	}
+	for i, tag := range todo.Tags {
+		if !validTag(NormalizeTag(tag)) {
+			fields = append(fields, FieldError{Field: fmt.Sprintf("tags[%d]", i), Message: fmt.Sprintf(tagFieldMessage, MaxTagLength)})
+		}
+	}
+	if todo.Recurrence != "" {
+		if _, err := ParseRRule(todo.Recurrence); err != nil {
+			fields = append(fields, FieldError{Field: "recurrence", Message: err.Error()})
+		}
+	}
+	if len(fields) > 0 {
+		return NewValidationError(fields...)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+func todoTreeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTodoID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("tree"))
+	defer cancel()
+
+	tree, err := todoList.GetTodoTree(ctx, id)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
@@ -0,0 +1,50 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteApplyBatch(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.Clock = &fixedClock{now: now}
+
+	existing, err := store.AddTodo(ctx, "Existing")
+	require.NoError(t, err)
+
+	results, err := store.ApplyBatch(ctx, []storage.BatchOp{
+		{Op: storage.BatchCreate, Description: "Created"},
+		{Op: storage.BatchUpdate, ID: existing.ID, Description: "Updated", Completed: true, Version: 1},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "Created", results[0].Todo.Description)
+	assert.Equal(t, &storage.Todo{
+		ID: existing.ID, ListID: storage.DefaultListID, Description: "Updated", Completed: true, Version: 2,
+		CreatedAt: now, UpdatedAt: now, CompletedAt: &now,
+	}, results[1].Todo)
+
+	before, err := store.GetAllTodos(ctx)
+	require.NoError(t, err)
+
+	// The stale version fails the batch and rolls back the create and delete before it
+	_, err = store.ApplyBatch(ctx, []storage.BatchOp{
+		{Op: storage.BatchCreate, Description: "Rolled back"},
+		{Op: storage.BatchDelete, ID: results[0].ID},
+		{Op: storage.BatchUpdate, ID: existing.ID, Description: "Stale", Version: 1},
+	})
+	assert.True(t, errors.Is(err, storage.ErrVersionConflict))
+
+	after, err := store.GetAllTodos(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
@@ -0,0 +1,59 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteDependencies(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, db)
+	require.NoError(t, err)
+	for _, description := range []string{"Design", "Build", "Ship"} {
+		_, err := store.AddTodo(ctx, description)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, store.AddDependency(ctx, 2, 1))
+	require.NoError(t, store.AddDependency(ctx, 3, 2))
+	require.NoError(t, store.AddDependency(ctx, 3, 2))
+	assert.True(t, errors.Is(store.AddDependency(ctx, 1, 3), storage.ErrDependencyCycle))
+	assert.True(t, errors.Is(store.AddDependency(ctx, 1, 42), storage.ErrTodoNotFound))
+
+	dependencies, err := store.GetDependencies(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, dependencies.BlockedBy, 1)
+	assert.Equal(t, 1, dependencies.BlockedBy[0].ID)
+	require.Len(t, dependencies.Blocks, 1)
+	assert.Equal(t, 3, dependencies.Blocks[0].ID)
+
+	err = store.UpdateTodoByID(ctx, 2, &storage.Todo{Description: "Build", Completed: true})
+	require.True(t, errors.Is(err, storage.ErrTodoBlocked))
+	assert.Equal(t, "Todo with ID 2 is blocked by open todos 1", err.Error())
+	require.NoError(t, store.UpdateTodoByID(ctx, 1, &storage.Todo{Description: "Design", Completed: true}))
+	require.NoError(t, store.UpdateTodoByID(ctx, 2, &storage.Todo{Description: "Build", Completed: true}))
+
+	// Trashed todos drop out of the graph until they are restored, purged ones for good
+	require.NoError(t, store.DeleteTodoByID(ctx, 2))
+	edges, err := store.ListDependencies(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, edges)
+	_, err = store.RestoreTodoByID(ctx, 2)
+	require.NoError(t, err)
+	edges, err = store.ListDependencies(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []storage.Dependency{{TodoID: 2, BlockerID: 1}, {TodoID: 3, BlockerID: 2}}, edges)
+
+	require.NoError(t, store.DeleteTodoByID(ctx, 2))
+	require.NoError(t, store.PurgeTodoByID(ctx, 2))
+	var rows int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM todo_dependencies").Scan(&rows))
+	assert.Zero(t, rows)
+	assert.True(t, errors.Is(store.RemoveDependency(ctx, 3, 2), storage.ErrDependencyNotFound))
+}
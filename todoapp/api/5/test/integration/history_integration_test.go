@@ -0,0 +1,118 @@
+package integration_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteHistoryStore(t *testing.T) {
+	db := openTestDB(t)
+	ctx := storage.WithActor(context.Background(), "alice")
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, db)
+	require.NoError(t, err)
+
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock
+	todoList := storage.NewTodoListWithOptions(storage.Options{
+		Store:   store,
+		Clock:   clock,
+		History: storage.NewSQLiteHistoryStore(db),
+	})
+	todoList.DisableLogging()
+
+	todo, err := todoList.AddTodo(ctx, "Audited")
+	require.NoError(t, err)
+	require.NoError(t, todoList.UpdateTodoByID(ctx, todo.ID, &storage.Todo{Description: "Changed"}))
+	require.NoError(t, todoList.DeleteTodoByID(ctx, todo.ID))
+	require.NoError(t, todoList.PurgeTodoByID(ctx, todo.ID))
+
+	// The history outlives the todo
+	changes, err := todoList.TodoHistory(ctx, todo.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 4)
+	assert.Equal(t, &storage.Change{
+		Seq:    2,
+		TodoID: todo.ID,
+		Action: storage.ActionUpdate,
+		Before: &storage.Todo{ID: todo.ID, ListID: storage.DefaultListID, Description: "Audited", Version: 1, CreatedAt: clock.now, UpdatedAt: clock.now},
+		After:  &storage.Todo{ID: todo.ID, ListID: storage.DefaultListID, Description: "Changed", Version: 2, CreatedAt: clock.now, UpdatedAt: clock.now},
+		At:     clock.Now(),
+		Actor:  "alice",
+	}, changes[1])
+	assert.Equal(t, storage.ActionPurge, changes[3].Action)
+
+	// The table is append-only
+	_, err = db.Exec("DELETE FROM todo_history")
+	assert.Error(t, err)
+	_, err = db.Exec("UPDATE todo_history SET actor = 'mallory'")
+	assert.Error(t, err)
+}
+
+func TestSQLiteHistory_RecordedWithTheChange(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, db)
+	require.NoError(t, err)
+	bus := storage.NewEventBus(100)
+	todoList := storage.NewTodoListWithOptions(storage.Options{
+		Store:   store,
+		History: storage.NewSQLiteHistoryStore(db),
+		Events:  bus,
+	})
+	todoList.DisableLogging()
+	todo, err := todoList.AddTodo(ctx, "Audited")
+	require.NoError(t, err)
+
+	// Concurrent changes are recorded and published in the order they were committed
+	sub := bus.Subscribe()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, todoList.UpdateTodoByID(ctx, todo.ID, &storage.Todo{Description: "Audited", Completed: i%2 == 0}))
+		}()
+	}
+	wg.Wait()
+	sub.Close()
+	changes, err := todoList.TodoHistory(ctx, todo.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 11)
+	version := 1
+	for event := range sub.Events() {
+		version++
+		assert.Equal(t, version, event.Todo.Version)
+		assert.Equal(t, version, changes[version-1].After.Version)
+	}
+	assert.Equal(t, 11, version)
+
+	// A change whose history can't be written is rolled back and not published
+	_, err = db.Exec(`CREATE TRIGGER todo_history_unavailable BEFORE INSERT ON todo_history
+		BEGIN SELECT RAISE(ABORT, 'unavailable'); END`)
+	require.NoError(t, err)
+	sub = bus.Subscribe()
+	defer sub.Close()
+	assert.Error(t, todoList.UpdateTodoByID(ctx, todo.ID, &storage.Todo{Description: "Unaudited"}))
+	assert.Error(t, todoList.DeleteTodoByID(ctx, todo.ID))
+	_, err = todoList.AddTodo(ctx, "Unaudited")
+	assert.Error(t, err)
+
+	current, err := store.GetTodoByID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Audited", current.Description)
+	assert.Equal(t, 11, current.Version)
+	all, err := store.GetAllTodos(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+	select {
+	case event := <-sub.Events():
+		t.Fatalf("published %v for a change that was rolled back", event.Type)
+	default:
+	}
+}
@@ -0,0 +1,106 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteLists(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+
+	inbox, err := store.GetList(ctx, storage.DefaultListID)
+	require.NoError(t, err)
+	assert.Equal(t, "Inbox", inbox.Name)
+
+	work, err := store.CreateList(ctx, &storage.List{Name: "Work", Color: "#1e90ff"})
+	require.NoError(t, err)
+	stored, err := store.GetList(ctx, work.ID)
+	require.NoError(t, err)
+	assert.Equal(t, work, stored)
+
+	_, err = store.CreateList(ctx, &storage.List{Name: "work"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateList))
+	_, err = store.UpdateList(ctx, storage.DefaultListID, &storage.List{Name: "WORK"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateList))
+
+	inboxTodo, err := store.AddTodo(ctx, "Call Bob")
+	require.NoError(t, err)
+	assert.Equal(t, storage.DefaultListID, inboxTodo.ListID)
+	workTodo, err := store.CreateTodo(ctx, &storage.Todo{ListID: work.ID, Description: "Call Bob"})
+	require.NoError(t, err)
+	_, err = store.CreateTodo(ctx, &storage.Todo{ListID: work.ID, Description: "Call Bob"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo))
+	_, err = store.CreateTodo(ctx, &storage.Todo{ListID: 42, Description: "Nowhere"})
+	assert.True(t, errors.Is(err, storage.ErrListNotFound))
+
+	page, err := store.ListTodos(ctx, storage.ListOptions{Filter: storage.TodoFilter{ListID: storage.DefaultListID}})
+	require.NoError(t, err)
+	require.Len(t, page.Todos, 1)
+	assert.Equal(t, inboxTodo.ID, page.Todos[0].ID)
+
+	update := &storage.Todo{Description: "Call Bob back"}
+	require.NoError(t, store.UpdateTodoByID(ctx, workTodo.ID, update))
+	assert.Equal(t, work.ID, update.ListID)
+	err = store.UpdateTodoByID(ctx, workTodo.ID, &storage.Todo{ListID: 42, Description: "Lost"})
+	assert.True(t, errors.Is(err, storage.ErrListNotFound))
+
+	assert.True(t, errors.Is(store.DeleteList(ctx, work.ID), storage.ErrListNotEmpty))
+	require.NoError(t, store.DeleteTodoByID(ctx, workTodo.ID))
+	require.NoError(t, store.DeleteList(ctx, work.ID))
+	restored, err := store.RestoreTodoByID(ctx, workTodo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.DefaultListID, restored.ListID)
+
+	_, err = store.GetList(ctx, work.ID)
+	assert.True(t, errors.Is(err, storage.ErrListNotFound))
+	assert.True(t, errors.Is(store.DeleteList(ctx, storage.DefaultListID), storage.ErrInvalidInput))
+}
+
+func TestSQLiteLists_UniqueOnEveryWrite(t *testing.T) {
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(context.Background(), openTestDB(t))
+	require.NoError(t, err)
+	ctx := context.Background()
+	work, err := store.CreateList(ctx, &storage.List{Name: "Work"})
+	require.NoError(t, err)
+	a, err := store.AddTodo(ctx, "a")
+	require.NoError(t, err)
+	_, err = store.AddTodo(ctx, "b")
+	require.NoError(t, err)
+	done, err := store.CreateTodo(ctx, &storage.Todo{Description: "c", Completed: true})
+	require.NoError(t, err)
+	_, err = store.AddTodo(ctx, "c")
+	require.NoError(t, err)
+	elsewhere, err := store.CreateTodo(ctx, &storage.Todo{ListID: work.ID, Description: "b"})
+	require.NoError(t, err)
+
+	err = store.UpdateTodoByID(ctx, a.ID, &storage.Todo{Description: "b"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "update")
+	_, err = store.PatchTodoByID(ctx, a.ID, func(todo *storage.Todo) error {
+		todo.Description = "b"
+		return nil
+	})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "patch")
+	_, err = store.ApplyBatch(ctx, []storage.BatchOp{{Op: storage.BatchUpdate, ID: a.ID, Description: "b"}})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "batch update")
+	err = store.UpdateTodoByID(ctx, elsewhere.ID, &storage.Todo{ListID: storage.DefaultListID, Description: "b"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "move")
+	err = store.UpdateTodoByID(ctx, done.ID, &storage.Todo{Description: "c"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "reopen")
+
+	// A todo keeps its own description, and completed ones don't count
+	require.NoError(t, store.UpdateTodoByID(ctx, a.ID, &storage.Todo{Description: "a", Priority: storage.PriorityHigh}))
+	require.NoError(t, store.UpdateTodoByID(ctx, done.ID, &storage.Todo{Description: "c", Completed: true}))
+
+	require.NoError(t, store.DeleteTodoByID(ctx, a.ID))
+	_, err = store.AddTodo(ctx, "a")
+	require.NoError(t, err)
+	_, err = store.RestoreTodoByID(ctx, a.ID)
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "restore")
+}
@@ -0,0 +1,104 @@
+package integration_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"todoapp/5/storage"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB opens an in-memory SQLite database pinned to a single connection,
+// since every new connection to ":memory:" would otherwise see an empty database.
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrations_StoreUsableWithoutManualSchema(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, db)
+	require.NoError(t, err)
+
+	todoList := storage.NewTodoListWithOptions(storage.Options{Store: store})
+	todoList.DisableLogging()
+
+	todo, err := todoList.AddTodo(ctx, "Migrated schema")
+	require.NoError(t, err)
+
+	fetched, err := todoList.GetTodoByID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Migrated schema", fetched.Description)
+
+	// Running the migrations again is a no-op
+	_, err = storage.NewSQLiteTodoStoreWithMigrations(ctx, db)
+	assert.NoError(t, err)
+
+	// Every embedded migration can be rolled back and re-applied
+	migrations, err := storage.SQLiteMigrations(ctx, db)
+	require.NoError(t, err)
+	migrator := storage.NewMigrator(db, migrations)
+	require.NoError(t, migrator.Down(ctx, len(migrations)))
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	require.NoError(t, migrator.Up(ctx))
+}
+
+func TestMigrator_UpAndDown(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"m/0001_first.up.sql":    {Data: []byte("CREATE TABLE first (id INTEGER);")},
+		"m/0001_first.down.sql":  {Data: []byte("DROP TABLE first;")},
+		"m/0002_second.up.sql":   {Data: []byte("CREATE TABLE second (id INTEGER);")},
+		"m/0002_second.down.sql": {Data: []byte("DROP TABLE second;")},
+	}
+	migrations, err := storage.LoadMigrations(fsys, "m")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	migrator := storage.NewMigrator(db, migrations)
+	require.NoError(t, migrator.Up(ctx))
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	require.NoError(t, migrator.Down(ctx, 1))
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	_, err = db.Exec("SELECT * FROM second")
+	assert.Error(t, err, "second table should be dropped")
+	_, err = db.Exec("SELECT * FROM first")
+	assert.NoError(t, err)
+}
+
+func TestMigrator_ChecksumGuard(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	original := []storage.Migration{{Version: 1, Name: "first", Up: "CREATE TABLE first (id INTEGER);"}}
+	require.NoError(t, storage.NewMigrator(db, original).Up(ctx))
+
+	edited := []storage.Migration{{Version: 1, Name: "first", Up: "CREATE TABLE first (id INTEGER, name TEXT);"}}
+	err := storage.NewMigrator(db, edited).Up(ctx)
+	require.Error(t, err)
+
+	var todoErr *storage.TodoError
+	require.True(t, errors.As(err, &todoErr))
+	assert.Equal(t, storage.ErrMigrationFailed, todoErr.Code)
+}
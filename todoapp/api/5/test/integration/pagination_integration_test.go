@@ -0,0 +1,39 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteListTodosPagination(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+
+	for i := 1; i <= 5; i++ {
+		_, err := store.AddTodo(ctx, fmt.Sprintf("Todo %d", i))
+		require.NoError(t, err)
+	}
+
+	first, err := store.ListTodos(ctx, storage.ListOptions{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, first.Todos, 2)
+	assert.Equal(t, 1, first.Todos[0].ID)
+	require.NotEmpty(t, first.NextCursor)
+
+	// Rows added after the first page keep the cursor stable
+	_, err = store.AddTodo(ctx, "Todo 6")
+	require.NoError(t, err)
+
+	rest, err := store.ListTodos(ctx, storage.ListOptions{Limit: 10, Cursor: first.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, rest.Todos, 4)
+	assert.Equal(t, 3, rest.Todos[0].ID)
+	assert.Equal(t, 6, rest.Todos[3].ID)
+	assert.Empty(t, rest.NextCursor)
+}
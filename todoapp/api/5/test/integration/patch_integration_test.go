@@ -0,0 +1,49 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLitePatchTodoByID(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.Clock = &fixedClock{now: now}
+
+	todo, err := store.AddTodo(ctx, "Write report")
+	require.NoError(t, err)
+
+	patch, err := storage.NewMergePatch([]byte(`{"completed": true}`))
+	require.NoError(t, err)
+	patched, err := store.PatchTodoByID(ctx, todo.ID, patch)
+	require.NoError(t, err)
+	assert.Equal(t, &storage.Todo{
+		ID: todo.ID, ListID: storage.DefaultListID, Description: "Write report", Completed: true, Version: 2,
+		CreatedAt: now, UpdatedAt: now, CompletedAt: &now,
+	}, patched)
+
+	// A failing operation rolls back the whole patch
+	patch, err = storage.NewJSONPatch([]byte(`[
+		{"op": "replace", "path": "/description", "value": "Rewritten"},
+		{"op": "test", "path": "/completed", "value": false}
+	]`))
+	require.NoError(t, err)
+	_, err = store.PatchTodoByID(ctx, todo.ID, patch)
+	assert.True(t, errors.Is(err, storage.ErrPatchConflict))
+
+	stored, err := store.GetTodoByID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, patched, stored)
+
+	require.NoError(t, store.DeleteTodoByID(ctx, todo.ID))
+	_, err = store.PatchTodoByID(ctx, todo.ID, patch)
+	assert.True(t, errors.Is(err, storage.ErrTodoNotFound))
+}
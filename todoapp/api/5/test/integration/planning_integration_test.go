@@ -0,0 +1,67 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLitePlanningFields(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, db)
+	require.NoError(t, err)
+	clock := &fixedClock{now: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock
+
+	past := clock.now.Add(-24 * time.Hour)
+	late, err := store.CreateTodo(ctx, &storage.Todo{
+		Description: "Late",
+		DueDate:     &past,
+		Priority:    storage.PriorityUrgent,
+		Tags:        []string{"Work", "errands"},
+	})
+	require.NoError(t, err)
+	_, err = store.CreateTodo(ctx, &storage.Todo{Description: "Untagged"})
+	require.NoError(t, err)
+
+	stored, err := store.GetTodoByID(ctx, late.ID)
+	require.NoError(t, err)
+	assert.Equal(t, late, stored)
+	assert.Equal(t, []string{"errands", "work"}, stored.Tags)
+	assert.Equal(t, storage.PriorityUrgent, stored.Priority)
+	assert.Equal(t, past, *stored.DueDate)
+	assert.Equal(t, clock.now, stored.CreatedAt)
+
+	yes := true
+	page, err := store.ListTodos(ctx, storage.ListOptions{Filter: storage.TodoFilter{Tag: "work", Overdue: &yes, Now: clock.now}})
+	require.NoError(t, err)
+	require.Len(t, page.Todos, 1)
+	assert.Equal(t, late.ID, page.Todos[0].ID)
+
+	// Completing the todo stamps it and takes it out of the overdue list
+	clock.now = clock.now.Add(time.Hour)
+	update := &storage.Todo{Description: "Late", Completed: true, Tags: []string{"work"}}
+	require.NoError(t, store.UpdateTodoByID(ctx, late.ID, update))
+	assert.Equal(t, clock.now, *update.CompletedAt)
+	assert.Equal(t, clock.now, update.UpdatedAt)
+	assert.Equal(t, late.CreatedAt, update.CreatedAt)
+	stored, err = store.GetTodoByID(ctx, late.ID)
+	require.NoError(t, err)
+	assert.Equal(t, update, stored)
+
+	page, err = store.ListTodos(ctx, storage.ListOptions{Filter: storage.TodoFilter{Overdue: &yes, Now: clock.now}})
+	require.NoError(t, err)
+	assert.Empty(t, page.Todos)
+
+	// Purging a todo removes its tags
+	require.NoError(t, store.DeleteTodoByID(ctx, late.ID))
+	require.NoError(t, store.PurgeTodoByID(ctx, late.ID))
+	var tags int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM todo_tags").Scan(&tags))
+	assert.Zero(t, tags)
+}
@@ -0,0 +1,66 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listAll follows the cursors until the listing is exhausted and returns the IDs in order
+func listAll(t *testing.T, store storage.TodoStore, opts storage.ListOptions) []int {
+	var ids []int
+	for {
+		page, err := store.ListTodos(context.Background(), opts)
+		require.NoError(t, err)
+		for _, todo := range page.Todos {
+			ids = append(ids, todo.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// TestListTodosQueryParity checks that SQLite compiles every filter and sort
+// to the same results the in-memory store computes in Go
+func TestListTodosQueryParity(t *testing.T) {
+	ctx := context.Background()
+	sqliteStore, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+	memoryStore := storage.NewInMemoryStore()
+
+	descriptions := []string{"banana", "Apple", "cherry", "apple pie", "Banana bread", "date", "apple"}
+	for _, store := range []storage.TodoStore{sqliteStore, memoryStore} {
+		for i, description := range descriptions {
+			todo, err := store.AddTodo(ctx, description)
+			require.NoError(t, err)
+			if i%2 == 0 {
+				todo = &storage.Todo{ID: todo.ID, Description: description, Completed: true}
+				require.NoError(t, store.UpdateTodoByID(ctx, todo.ID, todo))
+			}
+		}
+	}
+
+	completed, open := true, false
+	cases := map[string]storage.ListOptions{
+		"default":          {},
+		"completed":        {Filter: storage.TodoFilter{Completed: &completed}},
+		"open by desc":     {Filter: storage.TodoFilter{Completed: &open}, Sort: storage.TodoSort{Field: storage.SortByDescription, Direction: storage.SortDescending}},
+		"contains apple":   {Filter: storage.TodoFilter{Contains: "APPLE"}, Sort: storage.TodoSort{Field: storage.SortByDescription}},
+		"id range":         {Filter: storage.TodoFilter{MinID: 2, MaxID: 5}, Sort: storage.TodoSort{Direction: storage.SortDescending}},
+		"by completed":     {Sort: storage.TodoSort{Field: storage.SortByCompleted}},
+		"by completed rev": {Sort: storage.TodoSort{Field: storage.SortByCompleted, Direction: storage.SortDescending}},
+	}
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			opts.Limit = 2
+			expected := listAll(t, memoryStore, opts)
+			assert.Equal(t, expected, listAll(t, sqliteStore, opts))
+			assert.NotEmpty(t, expected)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteRecurrence(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+	clock := &fixedClock{now: time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock
+	todoList := storage.NewTodoListWithOptions(storage.Options{Store: store, Clock: clock})
+	todoList.DisableLogging()
+
+	due := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+	rent, err := todoList.CreateTodo(ctx, &storage.Todo{
+		Description: "Pay rent",
+		DueDate:     &due,
+		Recurrence:  "rrule:freq=monthly;until=20240401",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=MONTHLY;UNTIL=20240401T235959Z", rent.Recurrence)
+
+	// February has no 31st, so the next occurrence is in March, the last before UNTIL
+	rent.Completed = true
+	require.NoError(t, todoList.UpdateTodoByID(ctx, rent.ID, rent))
+	stored, err := store.GetTodoByID(ctx, rent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, rent.Recurrence, stored.Recurrence)
+
+	page, err := store.ListTodos(ctx, storage.ListOptions{Filter: storage.TodoFilter{Completed: new(bool)}})
+	require.NoError(t, err)
+	require.Len(t, page.Todos, 1)
+	next := page.Todos[0]
+	assert.Equal(t, "Pay rent", next.Description)
+	assert.Equal(t, time.Date(2024, 3, 31, 9, 0, 0, 0, time.UTC), *next.DueDate)
+
+	next.Completed = true
+	require.NoError(t, todoList.UpdateTodoByID(ctx, next.ID, next))
+	todos, err := store.GetAllTodos(ctx)
+	require.NoError(t, err)
+	assert.Len(t, todos, 2)
+}
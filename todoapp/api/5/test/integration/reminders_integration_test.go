@@ -0,0 +1,125 @@
+package integration_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+	"todoapp/5/reminder"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingNotifier counts the notifications it is given.
+type countingNotifier struct{ count int }
+
+func (n *countingNotifier) Notify(ctx context.Context, notification *reminder.Notification) error {
+	n.count++
+	return nil
+}
+
+func TestSQLiteReminders_SurviveRestarts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "todos.db")
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	notifier := &countingNotifier{}
+
+	// start opens the database as a restarted server would
+	start := func() (*storage.SQLiteTodoStore, *reminder.Scheduler) {
+		db, err := sql.Open("sqlite3", path)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, db)
+		require.NoError(t, err)
+		store.Clock = clock
+		scheduler := reminder.NewScheduler(store, notifier)
+		scheduler.Clock = clock
+		scheduler.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		return store, scheduler
+	}
+
+	store, scheduler := start()
+	todo, err := store.AddTodo(ctx, "Submit expenses")
+	require.NoError(t, err)
+	for _, after := range []time.Duration{time.Hour, 3 * time.Hour} {
+		_, err := store.AddReminder(ctx, todo.ID, clock.now.Add(after))
+		require.NoError(t, err)
+	}
+
+	clock.now = clock.now.Add(2 * time.Hour)
+	fired, err := scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fired)
+
+	// After a restart the fired reminder stays fired and the one that came due while
+	// the server was down fires on the first run
+	clock.now = clock.now.Add(2 * time.Hour)
+	store, scheduler = start()
+	fired, err = scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fired)
+	fired, err = scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, fired)
+	assert.Equal(t, 2, notifier.count)
+
+	reminders, err := store.ListReminders(ctx, todo.ID)
+	require.NoError(t, err)
+	require.Len(t, reminders, 2)
+	assert.Equal(t, time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC), reminders[0].At)
+	assert.Equal(t, time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC), *reminders[0].FiredAt)
+	assert.Equal(t, clock.now, *reminders[1].FiredAt)
+
+	// Purging the todo drops its reminders
+	require.NoError(t, store.DeleteTodoByID(ctx, todo.ID))
+	require.NoError(t, store.PurgeTodoByID(ctx, todo.ID))
+	var left int
+	require.NoError(t, store.DB.QueryRow("SELECT COUNT(*) FROM todo_reminders").Scan(&left))
+	assert.Zero(t, left)
+}
+
+func TestSQLiteReminders_ClaimLease(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock
+
+	todo, err := store.AddTodo(ctx, "File taxes")
+	require.NoError(t, err)
+	added, err := store.AddReminder(ctx, todo.ID, clock.now)
+	require.NoError(t, err)
+
+	// A scheduler that claims the reminder and crashes holds it for the lease only
+	claimed, err := store.ClaimReminder(ctx, added.ID, clock.now, time.Minute)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	claimed, err = store.ClaimReminder(ctx, added.ID, clock.now, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	due, err := store.DueReminders(ctx, clock.now)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	clock.now = clock.now.Add(time.Minute)
+	notifier := &countingNotifier{}
+	scheduler := reminder.NewScheduler(store, notifier)
+	scheduler.Clock = clock
+	scheduler.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	fired, err := scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fired)
+
+	reminders, err := store.ListReminders(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, reminders[0].Attempts)
+	assert.Equal(t, clock.now, *reminders[0].FiredAt)
+	claimed, err = store.ClaimReminder(ctx, added.ID, clock.now.Add(time.Hour), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed, "fired reminders can't be claimed")
+}
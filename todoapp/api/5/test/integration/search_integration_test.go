@@ -0,0 +1,79 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSQLiteSearchTodos runs against FTS5 with -tags sqlite_fts5, and against the
+// in-memory fallback without
+func TestSQLiteSearchTodos(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+
+	for _, description := range []string{
+		"Deploy the API",
+		"Write deployment notes for the deploy",
+		"Buy milk",
+	} {
+		_, err := store.AddTodo(ctx, description)
+		require.NoError(t, err)
+	}
+
+	results, err := store.SearchTodos(ctx, "deploy", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Contains(t, results[0].Snippet, "<mark>deploy</mark>")
+	assert.GreaterOrEqual(t, results[0].Rank, results[1].Rank)
+
+	// Triggers keep the index in sync with updates and deletes
+	require.NoError(t, store.UpdateTodoByID(ctx, 3, &storage.Todo{ID: 3, Description: "Deploy milk"}))
+	require.NoError(t, store.DeleteTodoByID(ctx, 2))
+	results, err = store.SearchTodos(ctx, "deploy", 10)
+	require.NoError(t, err)
+	ids := []int{}
+	for _, result := range results {
+		ids = append(ids, result.Todo.ID)
+	}
+	assert.ElementsMatch(t, []int{1, 3}, ids)
+
+	// Query syntax characters are treated as plain text
+	_, err = store.SearchTodos(ctx, `milk" OR NEAR(`, 10)
+	assert.NoError(t, err)
+}
+
+func TestSQLiteSearchTodos_WithoutFullTextIndex(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	// The schema a SQLite library without FTS5 migrates to
+	migrations, err := storage.DefaultMigrations()
+	require.NoError(t, err)
+	require.NoError(t, storage.NewMigrator(db, migrations[:11]).Up(ctx))
+	store := storage.NewSQLiteTodoStore(db)
+
+	for _, description := range []string{"Deploy the API", "Buy milk", "Write deployment notes"} {
+		_, err := store.AddTodo(ctx, description)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.DeleteTodoByID(ctx, 3))
+
+	results, err := store.SearchTodos(ctx, "deploy", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 1, results[0].Todo.ID)
+	assert.Equal(t, "<mark>Deploy</mark> the API", results[0].Snippet)
+
+	// With FTS5, migrating creates the index over the todos written without it
+	_, err = storage.NewSQLiteTodoStoreWithMigrations(ctx, db)
+	require.NoError(t, err)
+	results, err = store.SearchTodos(ctx, "milk", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 2, results[0].Todo.ID)
+}
@@ -0,0 +1,104 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteSubtasks(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+
+	release, err := store.AddTodo(ctx, "Release")
+	require.NoError(t, err)
+	test, err := store.CreateTodo(ctx, &storage.Todo{Description: "Test", ParentID: release.ID})
+	require.NoError(t, err)
+	unit, err := store.CreateTodo(ctx, &storage.Todo{Description: "Unit", ParentID: test.ID})
+	require.NoError(t, err)
+	assert.Equal(t, storage.DefaultListID, unit.ListID)
+
+	tree, err := store.GetTodoTree(ctx, release.ID)
+	require.NoError(t, err)
+	require.Len(t, tree.Subtasks, 1)
+	require.Len(t, tree.Subtasks[0].Subtasks, 1)
+	assert.Equal(t, *unit, tree.Subtasks[0].Subtasks[0].Todo)
+
+	// The parent can't be the todo itself or one of its descendants
+	for _, parentID := range []int{release.ID, unit.ID} {
+		err = store.UpdateTodoByID(ctx, release.ID, &storage.Todo{Description: "Release", ParentID: parentID})
+		require.True(t, errors.Is(err, storage.ErrInvalidInput))
+		assert.Equal(t, "parent_id", storage.Classify(err).Fields[0].Field)
+	}
+
+	// Todos with subtasks stay out of the trash, in batches too
+	assert.True(t, errors.Is(store.DeleteTodoByID(ctx, test.ID), storage.ErrHasSubtasks))
+	_, err = store.ApplyBatch(ctx, []storage.BatchOp{{Op: storage.BatchDelete, ID: test.ID}})
+	assert.True(t, errors.Is(err, storage.ErrHasSubtasks))
+
+	// Once its subtasks are gone a todo can go, a subtask restored without it moves up
+	require.NoError(t, store.DeleteTodoByID(ctx, unit.ID))
+	require.NoError(t, store.DeleteTodoByID(ctx, test.ID))
+	restored, err := store.RestoreTodoByID(ctx, unit.ID)
+	require.NoError(t, err)
+	assert.Zero(t, restored.ParentID)
+	restored, err = store.RestoreTodoByID(ctx, test.ID)
+	require.NoError(t, err)
+	assert.Equal(t, release.ID, restored.ParentID)
+
+	tree, err = store.GetTodoTree(ctx, release.ID)
+	require.NoError(t, err)
+	assert.Len(t, tree.Subtasks, 1)
+	assert.Empty(t, tree.Subtasks[0].Subtasks)
+}
+
+func TestSQLiteDeleteTodoTree(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+
+	release, err := store.AddTodo(ctx, "Release")
+	require.NoError(t, err)
+	test, err := store.CreateTodo(ctx, &storage.Todo{Description: "Test", ParentID: release.ID})
+	require.NoError(t, err)
+	unit, err := store.CreateTodo(ctx, &storage.Todo{Description: "Unit", ParentID: test.ID})
+	require.NoError(t, err)
+
+	// A stale version rolls back the subtasks' changes with the parent's delete
+	_, err = store.DeleteTodoTree(ctx, test.ID, test.Version+1, storage.DeletePromote)
+	require.True(t, errors.Is(err, storage.ErrVersionConflict))
+	current, err := store.GetTodoByID(ctx, unit.ID)
+	require.NoError(t, err)
+	assert.Equal(t, test.ID, current.ParentID)
+
+	changes, err := store.DeleteTodoTree(ctx, test.ID, test.Version, storage.DeletePromote)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, test.ID, changes[0].Before.ParentID)
+	assert.Equal(t, release.ID, changes[0].After.ParentID)
+
+	changes, err = store.DeleteTodoTree(ctx, release.ID, 0, storage.DeleteCascade)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.NotNil(t, changes[0].After.DeletedAt)
+	trash, err := store.ListTrash(ctx)
+	require.NoError(t, err)
+	assert.Len(t, trash, 3)
+
+	// Batches apply the rule they are given in their transaction
+	docs, err := store.AddTodo(ctx, "Docs")
+	require.NoError(t, err)
+	_, err = store.CreateTodo(ctx, &storage.Todo{Description: "Draft", ParentID: docs.ID})
+	require.NoError(t, err)
+	results, err := store.ApplyBatch(ctx, []storage.BatchOp{{Op: storage.BatchDelete, ID: docs.ID, OnDelete: storage.DeleteCascade}})
+	require.NoError(t, err)
+	assert.Len(t, results[0].Subtasks, 1)
+	trash, err = store.ListTrash(ctx)
+	require.NoError(t, err)
+	assert.Len(t, trash, 5)
+}
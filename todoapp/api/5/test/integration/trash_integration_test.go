@@ -0,0 +1,78 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedClock struct{ now time.Time }
+
+func (c *fixedClock) Now() time.Time { return c.now }
+
+func TestSQLiteTrash(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock
+
+	first, _ := store.AddTodo(ctx, "First")
+	second, _ := store.AddTodo(ctx, "Second")
+	require.NoError(t, store.DeleteTodoByID(ctx, first.ID))
+	clock.now = clock.now.Add(48 * time.Hour)
+	require.NoError(t, store.DeleteTodoByID(ctx, second.ID))
+
+	_, err = store.GetTodoByID(ctx, first.ID)
+	assert.True(t, errors.Is(err, storage.ErrTodoNotFound))
+	assert.True(t, errors.Is(store.UpdateTodoByID(ctx, first.ID, first), storage.ErrTodoNotFound))
+
+	// A trashed description can be reused
+	_, err = store.AddTodo(ctx, "First")
+	assert.NoError(t, err)
+
+	trashed, err := store.ListTrash(ctx)
+	require.NoError(t, err)
+	require.Len(t, trashed, 2)
+	assert.Equal(t, second.ID, trashed[0].ID)
+	assert.Equal(t, clock.now, *trashed[0].DeletedAt)
+
+	purged, err := store.PurgeDeletedBefore(ctx, clock.now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []int{first.ID}, purged)
+
+	restored, err := store.RestoreTodoByID(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, second.Version+1, restored.Version)
+	assert.True(t, errors.Is(store.PurgeTodoByID(ctx, second.ID), storage.ErrTodoNotFound))
+}
+
+func TestSQLiteTrash_RestoreIntoMissingList(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, db)
+	require.NoError(t, err)
+	work, err := store.CreateList(ctx, &storage.List{Name: "Work"})
+	require.NoError(t, err)
+	todo, err := store.CreateTodo(ctx, &storage.Todo{ListID: work.ID, Description: "Orphaned"})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteTodoByID(ctx, todo.ID))
+	_, err = db.ExecContext(ctx, "DELETE FROM lists WHERE id = ?", work.ID)
+	require.NoError(t, err)
+
+	// The todo comes back in the inbox, where its description must still be unique
+	restored, err := store.RestoreTodoByID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.DefaultListID, restored.ListID)
+	require.NoError(t, store.DeleteTodoByID(ctx, todo.ID))
+	_, err = store.AddTodo(ctx, "Orphaned")
+	require.NoError(t, err)
+	_, err = store.RestoreTodoByID(ctx, todo.ID)
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo))
+}
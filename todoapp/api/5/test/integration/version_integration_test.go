@@ -0,0 +1,37 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteOptimisticConcurrency(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewSQLiteTodoStoreWithMigrations(ctx, openTestDB(t))
+	require.NoError(t, err)
+
+	todo, err := store.AddTodo(ctx, "Shared todo")
+	require.NoError(t, err)
+	assert.Equal(t, 1, todo.Version)
+
+	first := &storage.Todo{ID: todo.ID, Description: "First writer", Version: 1}
+	require.NoError(t, store.UpdateTodoByID(ctx, todo.ID, first))
+	assert.Equal(t, 2, first.Version)
+
+	second := &storage.Todo{ID: todo.ID, Description: "Second writer", Version: 1}
+	assert.True(t, errors.Is(store.UpdateTodoByID(ctx, todo.ID, second), storage.ErrVersionConflict))
+
+	stored, err := store.GetTodoByID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "First writer", stored.Description)
+	assert.Equal(t, 2, stored.Version)
+
+	assert.True(t, errors.Is(store.DeleteTodoByIDAtVersion(ctx, todo.ID, 1), storage.ErrVersionConflict))
+	assert.NoError(t, store.DeleteTodoByIDAtVersion(ctx, todo.ID, 2))
+	assert.True(t, errors.Is(store.DeleteTodoByIDAtVersion(ctx, todo.ID, 2), storage.ErrTodoNotFound))
+}
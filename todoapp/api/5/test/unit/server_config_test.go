@@ -0,0 +1,125 @@
+package unit_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"todoapp/5/config"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envFrom(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg, err := config.Load(nil, envFrom(nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, config.BackendMemory, cfg.Backend)
+	assert.Equal(t, ":8080", cfg.ListenAddr)
+	assert.Equal(t, 10*time.Second, cfg.Timeouts.For("list"))
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{
+		"backend": "sqlite",
+		"dsn": "file.db",
+		"listen_addr": ":9000",
+		"log_level": "debug",
+		"timeouts": {"default": "3s", "routes": {"upload": "1m"}}
+	}`), 0644)
+	require.NoError(t, err)
+
+	env := envFrom(map[string]string{
+		"TODO_CONFIG": path,
+		"TODO_DSN":    "env.db",
+		"TODO_ADDR":   ":9100",
+	})
+	cfg, err := config.Load([]string{"-addr", ":9200", "-route-timeouts", "list=2s"}, env)
+	require.NoError(t, err)
+
+	assert.Equal(t, config.BackendSQLite, cfg.Backend) // from the file
+	assert.Equal(t, "env.db", cfg.DSN)                 // env overrides the file
+	assert.Equal(t, ":9200", cfg.ListenAddr)           // flags override env
+	assert.Equal(t, time.Minute, cfg.Timeouts.For("upload"))
+	assert.Equal(t, 2*time.Second, cfg.Timeouts.For("list"))
+	assert.Equal(t, 3*time.Second, cfg.Timeouts.For("get"))
+}
+
+func TestConfigValidation(t *testing.T) {
+	_, err := config.Load([]string{"-backend", "postgres"}, envFrom(nil))
+	assert.Error(t, err)
+
+	_, err = config.Load([]string{"-log-level", "loud"}, envFrom(nil))
+	assert.Error(t, err)
+
+	_, err = config.Load(nil, envFrom(map[string]string{"TODO_ROUTE_TIMEOUTS": "list"}))
+	assert.Error(t, err)
+	_, err = config.Load([]string{"-route-timeouts", "list=2s,uplaod=30s"}, envFrom(nil))
+	assert.ErrorContains(t, err, `unknown route "uplaod"`)
+
+	_, err = config.Load([]string{"-subtask-delete", "orphan"}, envFrom(nil))
+	assert.Error(t, err)
+
+	_, err = config.Load([]string{"-reminder-webhook", "ftp://example.com"}, envFrom(nil))
+	assert.Error(t, err)
+
+	_, err = config.Load([]string{"-ws-origins", "app.example.com"}, envFrom(nil))
+	assert.Error(t, err)
+	cfg, err := config.Load(nil, envFrom(map[string]string{"TODO_WS_ORIGINS": "https://app.example.com, *"}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://app.example.com", "*"}, cfg.WebSocketOrigins)
+}
+
+func TestConfigStorageOptionsSQLite(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "todos.db")
+	cfg, err := config.Load([]string{"-backend", "sqlite", "-dsn", dsn}, envFrom(nil))
+	require.NoError(t, err)
+
+	options, closeStore, err := cfg.StorageOptions(context.Background())
+	require.NoError(t, err)
+	defer closeStore()
+
+	_, ok := options.Store.(*storage.SQLiteTodoStore)
+	assert.True(t, ok, "expected the sqlite backend to be selected")
+
+	todo, err := options.Store.AddTodo(context.Background(), "Persisted")
+	require.NoError(t, err)
+	assert.Equal(t, "Persisted", todo.Description)
+}
+
+func TestConfigStorageOptionsSQLite_Concurrent(t *testing.T) {
+	for _, dsn := range []string{":memory:", filepath.Join(t.TempDir(), "todos.db")} {
+		cfg, err := config.Load([]string{"-backend", "sqlite", "-dsn", dsn}, envFrom(nil))
+		require.NoError(t, err)
+		options, closeStore, err := cfg.StorageOptions(context.Background())
+		require.NoError(t, err)
+		defer closeStore()
+
+		// Every write sees the migrated database and none fails on a lock
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := options.Store.AddTodo(context.Background(), fmt.Sprintf("Todo %d", i))
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			assert.NoError(t, err, dsn)
+		}
+	}
+}
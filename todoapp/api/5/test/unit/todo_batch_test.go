@@ -0,0 +1,91 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyBatch(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.Clock = newFakeClock(now)
+	ctx := context.Background()
+	existing, err := store.AddTodo(ctx, "Existing")
+	require.NoError(t, err)
+
+	results, err := store.ApplyBatch(ctx, []storage.BatchOp{
+		{Op: storage.BatchCreate, Description: "Created"},
+		{Op: storage.BatchUpdate, ID: existing.ID, Description: "Updated", Completed: true, Version: 1},
+		{Op: storage.BatchDelete, ID: 2},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, &storage.BatchResult{Op: storage.BatchCreate, ID: 2, Todo: &storage.Todo{ID: 2, ListID: storage.DefaultListID, Description: "Created", Version: 1, CreatedAt: now, UpdatedAt: now}}, results[0])
+	assert.Equal(t, 2, results[1].Todo.Version)
+	assert.Equal(t, &storage.BatchResult{Op: storage.BatchDelete, ID: 2}, results[2])
+
+	todos, _ := store.GetAllTodos(ctx)
+	require.Len(t, todos, 1)
+	assert.Equal(t, "Updated", todos[0].Description)
+}
+
+func TestApplyBatch_AllOrNothing(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	existing, err := store.AddTodo(ctx, "Existing")
+	require.NoError(t, err)
+
+	_, err = store.ApplyBatch(ctx, []storage.BatchOp{
+		{Op: storage.BatchCreate, Description: "Never created"},
+		{Op: storage.BatchDelete, ID: existing.ID},
+		{Op: storage.BatchUpdate, ID: existing.ID, Description: "Deleted above"},
+	})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, storage.ErrTodoNotFound))
+	assert.Contains(t, err.Error(), "Batch operation 2 (update)")
+
+	todos, _ := store.GetAllTodos(ctx)
+	assert.Equal(t, []*storage.Todo{existing}, todos)
+
+	// The failed batch did not consume IDs
+	todo, err := store.AddTodo(ctx, "Next")
+	require.NoError(t, err)
+	assert.Equal(t, 2, todo.ID)
+}
+
+func TestApplyBatch_Validation(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		ops  []storage.BatchOp
+		code storage.ErrorCode
+	}{
+		{"empty", nil, storage.ErrInvalidInput},
+		{"unknown op", []storage.BatchOp{{Op: "rename", ID: 1}}, storage.ErrInvalidInput},
+		{"empty description", []storage.BatchOp{{Op: storage.BatchCreate}}, storage.ErrInvalidInput},
+		{"missing ID", []storage.BatchOp{{Op: storage.BatchDelete}}, storage.ErrInvalidInput},
+		{"duplicate within batch", []storage.BatchOp{
+			{Op: storage.BatchCreate, Description: "Twice"},
+			{Op: storage.BatchCreate, Description: "Twice"},
+		}, storage.ErrDuplicateTodo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := store.ApplyBatch(ctx, tt.ops)
+			assert.True(t, errors.Is(err, tt.code), "got %v", err)
+		})
+	}
+
+	_, err := store.ApplyBatch(ctx, []storage.BatchOp{{Op: storage.BatchCreate, Description: "Fine"}, {Op: storage.BatchCreate}})
+	var todoErr *storage.TodoError
+	require.True(t, errors.As(err, &todoErr))
+	assert.Equal(t, []storage.FieldError{{Field: "operations[1].description", Message: "must not be empty"}}, todoErr.Fields)
+}
@@ -0,0 +1,187 @@
+package unit_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecRegistry(t *testing.T) {
+	codecs := storage.DefaultCodecs()
+
+	for path, mediaType := range map[string]string{
+		"todos.json":     "application/json",
+		"Backup.CSV":     "text/csv",
+		"/tmp/todos.tsv": "text/tab-separated-values",
+		"calendar.ics":   "text/calendar",
+		"calendar.ical":  "text/calendar",
+	} {
+		codec, ok := codecs.ForPath(path)
+		require.True(t, ok, path)
+		assert.Equal(t, mediaType, codec.MediaType(), path)
+	}
+	for _, path := range []string{"todos", "todos.xml"} {
+		_, ok := codecs.ForPath(path)
+		assert.False(t, ok, path)
+	}
+
+	codec, ok := codecs.ForMediaType("text/csv; charset=utf-8")
+	require.True(t, ok)
+	assert.Equal(t, []string{".csv"}, codec.Extensions())
+
+	for accept, mediaType := range map[string]string{
+		"":                                 "application/json",
+		"*/*":                              "application/json",
+		"text/*":                           "text/calendar",
+		"text/csv":                         "text/csv",
+		"application/json;q=0.5, text/csv": "text/csv",
+		"text/html, text/calendar;q=0.1":   "text/calendar",
+	} {
+		codec, ok := codecs.Negotiate(accept)
+		require.True(t, ok, accept)
+		assert.Equal(t, mediaType, codec.MediaType(), accept)
+	}
+	for _, accept := range []string{"text/html", "text/csv;q=0, application/xml"} {
+		_, ok := codecs.Negotiate(accept)
+		assert.False(t, ok, accept)
+	}
+
+	// Later registrations win
+	codecs.Register(&storage.CSVCodec{Delimiter: ';'})
+	codec, ok = codecs.Negotiate("text/csv")
+	require.True(t, ok)
+	assert.Equal(t, ';', codec.(*storage.CSVCodec).Delimiter)
+}
+
+func TestCSVCodec_RoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 1, 5, 9, 30, 0, 0, time.UTC)
+	todos := []*storage.Todo{
+		{
+			ID:          1,
+			ListID:      storage.DefaultListID,
+			Description: "Plan the offsite; venue, \"catering\"\nand travel",
+			DueDate:     &due,
+			Priority:    storage.PriorityHigh,
+			Tags:        []string{"planning", "work"},
+			Recurrence:  "FREQ=MONTHLY;BYDAY=-1FR",
+			CreatedAt:   created,
+			UpdatedAt:   created,
+		},
+		{
+			ID:          2,
+			ListID:      3,
+			ParentID:    1,
+			Description: "Book rooms",
+			Completed:   true,
+			CompletedAt: &created,
+			CreatedAt:   created,
+			UpdatedAt:   created,
+		},
+	}
+
+	for delimiter, codec := range map[string]*storage.CSVCodec{",": {}, ";": {Delimiter: ';'}, "\t": {Delimiter: '\t'}} {
+		var out bytes.Buffer
+		require.NoError(t, codec.Encode(&out, todos))
+		assert.True(t, strings.HasPrefix(out.String(), strings.Join(storage.CSVColumns, delimiter)+"\n"), "header row")
+
+		decoded, err := codec.Decode(&out)
+		require.NoError(t, err)
+		assert.Equal(t, todos, decoded)
+	}
+}
+
+func TestCSVCodec_DecodeSpreadsheet(t *testing.T) {
+	codec := &storage.CSVCodec{Columns: map[string]string{"Task": "description", "Due": "due_date"}}
+	sheet := "\ufeffTask,Owner,Due,Priority,Tags,Completed\r\n" +
+		"\"Write \"\"Q3\"\" report\",Ana,2024-03-01,Urgent,\"reports, finance\",\r\n" +
+		",,,,,\r\n" +
+		"Ship it,Bo\r\n"
+
+	todos, err := codec.Decode(strings.NewReader(sheet))
+	require.NoError(t, err)
+	require.Len(t, todos, 2, "blank rows are skipped")
+	assert.Equal(t, `Write "Q3" report`, todos[0].Description)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), *todos[0].DueDate)
+	assert.Equal(t, storage.PriorityUrgent, todos[0].Priority)
+	assert.Equal(t, []string{"reports", "finance"}, todos[0].Tags)
+	assert.False(t, todos[0].Completed)
+	assert.Equal(t, "Ship it", todos[1].Description)
+	assert.Nil(t, todos[1].DueDate)
+
+	for _, invalid := range []string{
+		"",
+		"title,completed\nWrite report,false\n",
+		"description,completed\nWrite report,maybe\n",
+		"description,due_date\nWrite report,next week\n",
+		"description,priority\nWrite report,asap\n",
+		"description,id\nWrite report,one\n",
+		"description\n\"unterminated\n",
+	} {
+		_, err := (&storage.CSVCodec{}).Decode(strings.NewReader(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestDownloadUpload_CSV(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewTodoListWithOptions(storage.Options{})
+	source.DisableLogging()
+	parent, err := source.AddTodo(ctx, "Release, part 1")
+	require.NoError(t, err)
+	_, err = source.CreateTodo(ctx, &storage.Todo{Description: "Write notes", ParentID: parent.ID, Tags: []string{"docs"}})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "todos.csv")
+	require.NoError(t, source.Download(ctx, path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Release, part 1"`)
+
+	target := storage.NewTodoListWithOptions(storage.Options{})
+	target.DisableLogging()
+	_, err = target.AddTodo(ctx, "Existing")
+	require.NoError(t, err)
+	require.NoError(t, target.Upload(ctx, path))
+
+	todos, err := target.GetAllTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, todos, 3)
+	assert.Equal(t, "Release, part 1", todos[1].Description)
+	assert.Equal(t, todos[1].ID, todos[2].ParentID)
+	assert.Equal(t, []string{"docs"}, todos[2].Tags)
+}
+
+func TestImportFrom_Result(t *testing.T) {
+	ctx := context.Background()
+	todoList := storage.NewTodoListWithOptions(storage.Options{})
+	todoList.DisableLogging()
+	codec, ok := storage.DefaultCodecs().ForMediaType("application/json")
+	require.True(t, ok)
+
+	// The todo of a list that doesn't exist goes to the inbox, the others are skipped
+	input := `[{"description": "Kept", "list_id": 42}, {"description": ""}, {"description": "Kept"}]`
+	result, err := todoList.ImportFrom(ctx, strings.NewReader(input), codec)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Created)
+	assert.Equal(t, 2, result.Skipped)
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, "todos[1].description", result.Errors[0].Field)
+	assert.Equal(t, "todos[2]", result.Errors[1].Field)
+
+	todos, err := todoList.GetAllTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, storage.DefaultListID, todos[0].ListID)
+
+	_, err = todoList.ImportFrom(ctx, strings.NewReader(`[{"description": ""}]`), codec)
+	assert.Equal(t, storage.ErrImportRejected, storage.Classify(err).Code)
+}
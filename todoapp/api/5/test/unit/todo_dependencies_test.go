@@ -0,0 +1,99 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func todoIDs(todos []*storage.Todo) []int {
+	ids := []int{}
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func TestDependencies_RejectCycles(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	for _, description := range []string{"Design", "Build", "Ship"} {
+		_, err := store.AddTodo(ctx, description)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, store.AddDependency(ctx, 2, 1))
+	require.NoError(t, store.AddDependency(ctx, 3, 2))
+	require.NoError(t, store.AddDependency(ctx, 3, 2), "adding a dependency twice is a no-op")
+
+	assert.True(t, errors.Is(store.AddDependency(ctx, 1, 3), storage.ErrDependencyCycle))
+	assert.True(t, errors.Is(store.AddDependency(ctx, 1, 1), storage.ErrInvalidInput))
+	assert.True(t, errors.Is(store.AddDependency(ctx, 1, 42), storage.ErrTodoNotFound))
+
+	dependencies, err := store.GetDependencies(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, todoIDs(dependencies.BlockedBy))
+	assert.Equal(t, []int{3}, todoIDs(dependencies.Blocks))
+
+	require.NoError(t, store.RemoveDependency(ctx, 3, 2))
+	assert.True(t, errors.Is(store.RemoveDependency(ctx, 3, 2), storage.ErrDependencyNotFound))
+	require.NoError(t, store.AddDependency(ctx, 1, 3), "the cycle is gone with the dependency")
+}
+
+func TestDependencies_BlockCompletion(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	blocker, err := store.AddTodo(ctx, "Review")
+	require.NoError(t, err)
+	blocked, err := store.AddTodo(ctx, "Merge")
+	require.NoError(t, err)
+	require.NoError(t, store.AddDependency(ctx, blocked.ID, blocker.ID))
+
+	err = store.UpdateTodoByID(ctx, blocked.ID, &storage.Todo{Description: "Merge", Completed: true})
+	require.True(t, errors.Is(err, storage.ErrTodoBlocked))
+	assert.Equal(t, "completed", storage.Classify(err).Fields[0].Field)
+
+	patch, err := storage.NewMergePatch([]byte(`{"completed": true}`))
+	require.NoError(t, err)
+	_, err = store.PatchTodoByID(ctx, blocked.ID, patch)
+	assert.True(t, errors.Is(err, storage.ErrTodoBlocked))
+
+	// A batch completing the blocker first goes through
+	_, err = store.ApplyBatch(ctx, []storage.BatchOp{
+		{Op: storage.BatchUpdate, ID: blocker.ID, Description: "Review", Completed: true},
+		{Op: storage.BatchUpdate, ID: blocked.ID, Description: "Merge", Completed: true},
+	})
+	require.NoError(t, err)
+
+	// Reopening the blocker leaves the completed todo alone, edits included
+	require.NoError(t, store.UpdateTodoByID(ctx, blocker.ID, &storage.Todo{Description: "Review"}))
+	require.NoError(t, store.UpdateTodoByID(ctx, blocked.ID, &storage.Todo{Description: "Merged", Completed: true}))
+}
+
+func TestOrderTodos(t *testing.T) {
+	todoList := storage.NewTodoList()
+	todoList.DisableLogging()
+	ctx := context.Background()
+	for _, description := range []string{"Ship", "Build", "Docs", "Design", "Done"} {
+		_, err := todoList.CreateTodo(ctx, &storage.Todo{Description: description, Completed: description == "Done"})
+		require.NoError(t, err)
+	}
+	// Ship waits for Build and Docs, Build for Design, and Docs for Done, which is completed
+	for _, dependency := range [][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 5}} {
+		require.NoError(t, todoList.AddDependency(ctx, dependency[0], dependency[1]))
+	}
+
+	ordered, err := todoList.OrderTodos(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 4, 2, 1}, todoIDs(ordered))
+
+	// Trashed todos don't hold anything up
+	require.NoError(t, todoList.DeleteTodoByID(ctx, 4))
+	ordered, err = todoList.OrderTodos(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 1}, todoIDs(ordered))
+}
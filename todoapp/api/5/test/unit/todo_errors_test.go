@@ -0,0 +1,56 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoErrorSentinels(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+
+	_, err := store.GetTodoByID(ctx, 42)
+	assert.True(t, errors.Is(err, storage.ErrTodoNotFound))
+	assert.False(t, errors.Is(err, storage.ErrInvalidInput))
+
+	// Wrapping keeps the classification reachable
+	wrapped := fmt.Errorf("handler: %w", err)
+	var todoErr *storage.TodoError
+	require.True(t, errors.As(wrapped, &todoErr))
+	assert.Equal(t, storage.ErrTodoNotFound, todoErr.Code)
+
+	_, err = store.AddTodo(ctx, "Only once")
+	require.NoError(t, err)
+	_, err = store.AddTodo(ctx, "Only once")
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo))
+}
+
+func TestClassify(t *testing.T) {
+	notFound := storage.NewTodoNotFoundError(7)
+	assert.Equal(t, storage.ErrTodoNotFound, storage.Classify(storage.NewStorageError(notFound)).Code)
+
+	timeout := storage.Classify(fmt.Errorf("query: %w", context.DeadlineExceeded))
+	assert.Equal(t, storage.ErrOperationTimeout, timeout.Code)
+	assert.True(t, errors.Is(timeout, context.DeadlineExceeded))
+
+	unknown := storage.Classify(errors.New("disk on fire"))
+	assert.Equal(t, storage.ErrStorageError, unknown.Code)
+
+	assert.Nil(t, storage.Classify(nil))
+}
+
+func TestValidateTodo(t *testing.T) {
+	assert.NoError(t, storage.ValidateTodo(&storage.Todo{Description: "Fine"}))
+
+	err := storage.ValidateTodo(&storage.Todo{Description: "   "})
+	require.True(t, errors.Is(err, storage.ErrInvalidInput))
+	var todoErr *storage.TodoError
+	require.True(t, errors.As(err, &todoErr))
+	assert.Equal(t, []storage.FieldError{{Field: "description", Message: "must not be empty"}}, todoErr.Fields)
+}
@@ -0,0 +1,141 @@
+package unit_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receive reads the events already delivered to a subscription.
+func receive(sub *storage.Subscription) []*storage.Event {
+	var events []*storage.Event
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestTodoListPublishesEvents(t *testing.T) {
+	bus := storage.NewEventBus(10)
+	todoList := storage.NewTodoListWithOptions(storage.Options{Events: bus})
+	todoList.DisableLogging()
+	ctx := context.Background()
+
+	sub := bus.Subscribe()
+	defer sub.Close()
+
+	todo, err := todoList.AddTodo(ctx, "Watched")
+	require.NoError(t, err)
+	require.NoError(t, todoList.UpdateTodoByID(ctx, todo.ID, &storage.Todo{Description: "Watched", Completed: true}))
+	require.NoError(t, todoList.DeleteTodoByID(ctx, todo.ID))
+	// Failed operations publish nothing
+	assert.Error(t, todoList.DeleteTodoByID(ctx, todo.ID))
+
+	events := receive(sub)
+	require.Len(t, events, 3)
+	assert.Equal(t, storage.EventCreated, events[0].Type)
+	assert.Equal(t, int64(1), events[0].ID)
+	assert.Equal(t, storage.EventUpdated, events[1].Type)
+	assert.True(t, events[1].Todo.Completed)
+	assert.Equal(t, storage.EventDeleted, events[2].Type)
+	assert.Equal(t, todo.ID, events[2].TodoID)
+	assert.Nil(t, events[2].Todo)
+}
+
+func TestTodoListPublishesEventsInOrder(t *testing.T) {
+	bus := storage.NewEventBus(100)
+	todoList := storage.NewTodoListWithOptions(storage.Options{Events: bus})
+	todoList.DisableLogging()
+	ctx := context.Background()
+	todo, err := todoList.AddTodo(ctx, "Contended")
+	require.NoError(t, err)
+
+	sub := bus.Subscribe()
+	defer sub.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, todoList.UpdateTodoByID(ctx, todo.ID, &storage.Todo{Description: "Contended", Completed: i%2 == 0}))
+		}()
+	}
+	wg.Wait()
+
+	// Events and history follow the order the updates were applied in
+	events := receive(sub)
+	require.Len(t, events, 20)
+	changes, err := todoList.TodoHistory(ctx, todo.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 21)
+	for i, event := range events {
+		assert.Equal(t, i+2, event.Todo.Version)
+		assert.Equal(t, i+2, changes[i+1].After.Version)
+	}
+}
+
+func TestEventBusResume(t *testing.T) {
+	bus := storage.NewEventBus(3)
+	for i := 1; i <= 5; i++ {
+		bus.Publish(&storage.Event{Type: storage.EventCreated, TodoID: i})
+	}
+
+	// Events 3 to 5 are still buffered
+	sub, replay, complete := bus.Resume(3)
+	defer sub.Close()
+	assert.True(t, complete)
+	require.Len(t, replay, 2)
+	assert.Equal(t, []int64{4, 5}, []int64{replay[0].ID, replay[1].ID})
+
+	_, replay, complete = bus.Resume(2)
+	assert.True(t, complete)
+	assert.Len(t, replay, 3)
+
+	// Event 2 was evicted, so the replay from 1 has a gap
+	_, replay, complete = bus.Resume(1)
+	assert.False(t, complete)
+	assert.Len(t, replay, 3)
+
+	// An ID the bus never issued comes from an earlier server
+	_, replay, complete = bus.Resume(42)
+	assert.False(t, complete)
+	assert.Empty(t, replay)
+
+	_, replay, complete = bus.Resume(5)
+	assert.True(t, complete)
+	assert.Empty(t, replay)
+
+	bus.Publish(&storage.Event{Type: storage.EventDeleted, TodoID: 1})
+	events := receive(sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, int64(6), events[0].ID)
+}
+
+func TestEventBusDropsSlowSubscribers(t *testing.T) {
+	bus := storage.NewEventBus(0)
+	slow := bus.Subscribe()
+	for i := 0; i < 100; i++ {
+		bus.Publish(&storage.Event{Type: storage.EventCreated, TodoID: i})
+	}
+
+	// The subscription is closed after the events it could buffer
+	events := receive(slow)
+	assert.NotEmpty(t, events)
+	assert.Less(t, len(events), 100)
+	_, open := <-slow.Events()
+	assert.False(t, open)
+
+	// Closing it again is harmless
+	slow.Close()
+}
@@ -0,0 +1,101 @@
+package unit_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoListRecordsHistory(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	history, err := storage.NewMemoryHistoryStore(100, "")
+	require.NoError(t, err)
+	todoList := storage.NewTodoListWithOptions(storage.Options{Clock: clock, History: history})
+	todoList.DisableLogging()
+	ctx := storage.WithRequestID(storage.WithActor(context.Background(), "alice"), "req-1")
+
+	todo, err := todoList.AddTodo(ctx, "Audited")
+	require.NoError(t, err)
+	clock.Advance(time.Minute)
+	require.NoError(t, todoList.UpdateTodoByID(ctx, todo.ID, &storage.Todo{Description: "Audited", Completed: true}))
+	patch, _ := storage.NewMergePatch([]byte(`{"description": "Patched"}`))
+	_, err = todoList.PatchTodoByID(ctx, todo.ID, patch)
+	require.NoError(t, err)
+	require.NoError(t, todoList.DeleteTodoByID(ctx, todo.ID))
+	_, err = todoList.RestoreTodoByID(ctx, todo.ID)
+	require.NoError(t, err)
+
+	// A failed change is not recorded
+	assert.Error(t, todoList.UpdateTodoByID(ctx, todo.ID, &storage.Todo{Description: "Stale", Version: 1}))
+
+	changes, err := todoList.TodoHistory(ctx, todo.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 5)
+
+	actions := make([]storage.ChangeAction, len(changes))
+	for i, change := range changes {
+		actions[i] = change.Action
+		assert.Equal(t, int64(i+1), change.Seq)
+		assert.Equal(t, "alice", change.Actor)
+		assert.Equal(t, "req-1", change.RequestID)
+	}
+	assert.Equal(t, []storage.ChangeAction{
+		storage.ActionCreate, storage.ActionUpdate, storage.ActionUpdate, storage.ActionDelete, storage.ActionRestore,
+	}, actions)
+
+	assert.Nil(t, changes[0].Before)
+	created, updated := clock.Now().Add(-time.Minute), clock.Now()
+	assert.Equal(t, &storage.Todo{ID: todo.ID, ListID: storage.DefaultListID, Description: "Audited", Version: 1, CreatedAt: created, UpdatedAt: created}, changes[0].After)
+	assert.Equal(t, created, changes[0].At)
+	assert.Equal(t, changes[0].After, changes[1].Before)
+	assert.Equal(t, &storage.Todo{
+		ID: todo.ID, ListID: storage.DefaultListID, Description: "Audited", Completed: true, Version: 2,
+		CreatedAt: created, UpdatedAt: updated, CompletedAt: &updated,
+	}, changes[1].After)
+	assert.Equal(t, "Patched", changes[2].After.Description)
+	assert.Equal(t, changes[2].After, changes[3].Before)
+	assert.Nil(t, changes[3].After)
+
+	_, err = todoList.TodoHistory(ctx, 42)
+	assert.True(t, errors.Is(err, storage.ErrTodoNotFound))
+}
+
+func TestMemoryHistoryStore_RingBufferAndFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history, err := storage.NewMemoryHistoryStore(3, path)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, history.Append(ctx, &storage.Change{TodoID: 1, Action: storage.ActionUpdate}))
+	}
+	require.NoError(t, history.Close())
+
+	// Only the three most recent changes remain in memory
+	changes, err := history.ListChanges(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	assert.Equal(t, []int64{3, 4, 5}, []int64{changes[0].Seq, changes[1].Seq, changes[2].Seq})
+
+	// while the file has every change
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var seqs []int64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var change storage.Change
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &change))
+		seqs = append(seqs, change.Seq)
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, seqs)
+}
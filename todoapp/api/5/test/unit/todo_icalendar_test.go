@@ -0,0 +1,169 @@
+package unit_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestICalendar_RoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 1, 5, 9, 30, 0, 0, time.UTC)
+	todos := []*storage.Todo{
+		{
+			ID:          1,
+			ListID:      storage.DefaultListID,
+			Description: "Plan the offsite; venue, catering\nand travel \\ visas",
+			DueDate:     &due,
+			Priority:    storage.PriorityHigh,
+			Tags:        []string{"planning", "work,travel"},
+			Recurrence:  "FREQ=MONTHLY;BYDAY=-1FR",
+			CreatedAt:   created,
+			UpdatedAt:   created,
+		},
+		{
+			ID:          2,
+			ListID:      3,
+			ParentID:    1,
+			Description: strings.Repeat("Très long résumé ", 10),
+			Completed:   true,
+			CompletedAt: &created,
+			CreatedAt:   created,
+			UpdatedAt:   created,
+		},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, storage.EncodeICalendar(&out, todos))
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, "lines are folded")
+	}
+	assert.Contains(t, out.String(), "UID:todo-1@todoapp\r\n")
+	assert.Contains(t, out.String(), "STATUS:COMPLETED\r\n")
+	assert.Contains(t, out.String(), "DUE:20240105T093000Z\r\n")
+	assert.Contains(t, out.String(), "RELATED-TO:todo-1@todoapp\r\n")
+
+	decoded, err := storage.DecodeICalendar(&out)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+	for i, todo := range decoded {
+		assert.Equal(t, todos[i].ID, todo.ID)
+		assert.Equal(t, todos[i].ListID, todo.ListID)
+		assert.Equal(t, todos[i].ParentID, todo.ParentID)
+		assert.Equal(t, todos[i].Description, todo.Description)
+		assert.Equal(t, todos[i].Completed, todo.Completed)
+		assert.Equal(t, todos[i].DueDate, todo.DueDate)
+		assert.Equal(t, todos[i].Priority, todo.Priority)
+		assert.Equal(t, todos[i].Tags, todo.Tags)
+		assert.Equal(t, todos[i].Recurrence, todo.Recurrence)
+	}
+}
+
+func TestICalendar_BareCarriageReturn(t *testing.T) {
+	todos := []*storage.Todo{{ID: 1, ListID: storage.DefaultListID, Description: "Old Mac line\rbreak\r\nand a CRLF"}}
+
+	// A bare CR would end the content line early, it is written as a line break instead
+	var out bytes.Buffer
+	require.NoError(t, storage.EncodeICalendar(&out, todos))
+	assert.NotContains(t, strings.ReplaceAll(out.String(), "\r\n", ""), "\r")
+	assert.Contains(t, out.String(), `SUMMARY:Old Mac line\nbreak\nand a CRLF`)
+
+	decoded, err := storage.DecodeICalendar(&out)
+	require.NoError(t, err)
+	require.Len(t, decoded, 1)
+	assert.Equal(t, "Old Mac line\nbreak\nand a CRLF", decoded[0].Description)
+}
+
+func TestDecodeICalendar_ForeignCalendar(t *testing.T) {
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Example//Calendar//EN",
+		"BEGIN:VEVENT",
+		"UID:event@example.com",
+		"SUMMARY:Not a todo",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"UID:subtask@example.com",
+		"SUMMARY:Book flig",
+		" hts",
+		"RELATED-TO;RELTYPE=PARENT:trip@example.com",
+		"DUE;TZID=Europe/Paris:20240301T100000",
+		"PRIORITY:9",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"SUMMARY:Alarm text",
+		"END:VALARM",
+		"END:VTODO",
+		"BEGIN:VTODO",
+		"UID:trip@example.com",
+		"SUMMARY:Plan trip",
+		"STATUS:NEEDS-ACTION",
+		"DUE;VALUE=DATE:20240315",
+		"PRIORITY:1",
+		"CATEGORIES:Travel,Family",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	todos, err := storage.DecodeICalendar(strings.NewReader(calendar))
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+
+	// Parents come before their subtasks
+	trip, flights := todos[0], todos[1]
+	assert.Equal(t, "Plan trip", trip.Description)
+	assert.Equal(t, storage.PriorityUrgent, trip.Priority)
+	assert.Equal(t, []string{"Travel", "Family"}, trip.Tags)
+	assert.Equal(t, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), *trip.DueDate)
+
+	assert.Equal(t, "Book flights", flights.Description)
+	assert.Equal(t, trip.ID, flights.ParentID)
+	assert.NotZero(t, flights.ID)
+	assert.Equal(t, storage.PriorityLow, flights.Priority)
+	assert.Equal(t, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), *flights.DueDate)
+
+	for _, invalid := range []string{
+		"BEGIN:VTODO\r\nEND:VTODO",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VCALENDAR",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nPRIORITY:high\r\nEND:VTODO\r\nEND:VCALENDAR",
+		"BEGIN:VCALENDAR\r\nno colon\r\nEND:VCALENDAR",
+	} {
+		_, err := storage.DecodeICalendar(strings.NewReader(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestDownloadUpload_ICalendar(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewTodoListWithOptions(storage.Options{})
+	source.DisableLogging()
+	parent, err := source.AddTodo(ctx, "Release")
+	require.NoError(t, err)
+	_, err = source.CreateTodo(ctx, &storage.Todo{Description: "Write notes", ParentID: parent.ID, Tags: []string{"docs"}})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "todos.ics")
+	require.NoError(t, source.Download(ctx, path))
+
+	target := storage.NewTodoListWithOptions(storage.Options{})
+	target.DisableLogging()
+	_, err = target.AddTodo(ctx, "Existing")
+	require.NoError(t, err)
+	require.NoError(t, target.Upload(ctx, path))
+
+	todos, err := target.GetAllTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, todos, 3)
+	assert.Equal(t, "Release", todos[1].Description)
+	assert.Equal(t, "Write notes", todos[2].Description)
+	assert.Equal(t, todos[1].ID, todos[2].ParentID, "subtasks are attached to the new ID of their parent")
+	assert.Equal(t, []string{"docs"}, todos[2].Tags)
+}
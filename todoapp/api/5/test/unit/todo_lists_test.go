@@ -0,0 +1,136 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLists_CRUD(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+
+	lists, err := store.ListLists(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, lists, 1)
+	assert.Equal(t, "Inbox", lists[0].Name)
+
+	work, err := store.CreateList(ctx, &storage.List{Name: "Work", Color: "#1e90ff"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, work.ID)
+
+	_, err = store.CreateList(ctx, &storage.List{Name: "WORK"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateList))
+
+	archived, err := store.UpdateList(ctx, work.ID, &storage.List{Name: "Work", Archived: true})
+	require.NoError(t, err)
+	assert.True(t, archived.Archived)
+	assert.Empty(t, archived.Color)
+
+	lists, err = store.ListLists(ctx, false)
+	require.NoError(t, err)
+	assert.Len(t, lists, 1, "archived lists are hidden by default")
+	lists, err = store.ListLists(ctx, true)
+	require.NoError(t, err)
+	assert.Len(t, lists, 2)
+
+	_, err = store.GetList(ctx, 42)
+	assert.True(t, errors.Is(err, storage.ErrListNotFound))
+}
+
+func TestLists_ScopeTodos(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	work, err := store.CreateList(ctx, &storage.List{Name: "Work"})
+	require.NoError(t, err)
+
+	inboxTodo, err := store.AddTodo(ctx, "Call Bob")
+	require.NoError(t, err)
+	assert.Equal(t, storage.DefaultListID, inboxTodo.ListID)
+
+	// Descriptions only have to be unique within a list
+	workTodo, err := store.CreateTodo(ctx, &storage.Todo{ListID: work.ID, Description: "Call Bob"})
+	require.NoError(t, err)
+	_, err = store.CreateTodo(ctx, &storage.Todo{ListID: work.ID, Description: "Call Bob"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo))
+	_, err = store.CreateTodo(ctx, &storage.Todo{ListID: 42, Description: "Nowhere"})
+	assert.True(t, errors.Is(err, storage.ErrListNotFound))
+
+	page, err := store.ListTodos(ctx, storage.ListOptions{Filter: storage.TodoFilter{ListID: work.ID}})
+	require.NoError(t, err)
+	require.Len(t, page.Todos, 1)
+	assert.Equal(t, workTodo.ID, page.Todos[0].ID)
+
+	// Updates without a list keep the todo where it is
+	update := &storage.Todo{Description: "Call Bob back"}
+	require.NoError(t, store.UpdateTodoByID(ctx, workTodo.ID, update))
+	assert.Equal(t, work.ID, update.ListID)
+
+	err = store.DeleteList(ctx, work.ID)
+	require.True(t, errors.Is(err, storage.ErrListNotEmpty))
+
+	// Trashed todos don't keep a list alive, they move to the inbox
+	require.NoError(t, store.DeleteTodoByID(ctx, workTodo.ID))
+	require.NoError(t, store.DeleteList(ctx, work.ID))
+	restored, err := store.RestoreTodoByID(ctx, workTodo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.DefaultListID, restored.ListID)
+
+	assert.True(t, errors.Is(store.DeleteList(ctx, storage.DefaultListID), storage.ErrInvalidInput))
+}
+
+func TestValidateList(t *testing.T) {
+	err := storage.ValidateList(&storage.List{Name: " ", Color: "blue"})
+	require.True(t, errors.Is(err, storage.ErrInvalidInput))
+	assert.Equal(t, []storage.FieldError{
+		{Field: "name", Message: "must not be empty"},
+		{Field: "color", Message: "must be a hex color such as #1e90ff"},
+	}, storage.Classify(err).Fields)
+
+	assert.NoError(t, storage.ValidateList(&storage.List{Name: "Home", Color: "#A0B1C2"}))
+}
+
+func TestLists_UniqueOnEveryWrite(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	work, err := store.CreateList(ctx, &storage.List{Name: "Work"})
+	require.NoError(t, err)
+	a, err := store.AddTodo(ctx, "a")
+	require.NoError(t, err)
+	_, err = store.AddTodo(ctx, "b")
+	require.NoError(t, err)
+	done, err := store.CreateTodo(ctx, &storage.Todo{Description: "c", Completed: true})
+	require.NoError(t, err)
+	_, err = store.AddTodo(ctx, "c")
+	require.NoError(t, err)
+	elsewhere, err := store.CreateTodo(ctx, &storage.Todo{ListID: work.ID, Description: "b"})
+	require.NoError(t, err)
+
+	err = store.UpdateTodoByID(ctx, a.ID, &storage.Todo{Description: "b"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "update")
+	_, err = store.PatchTodoByID(ctx, a.ID, func(todo *storage.Todo) error {
+		todo.Description = "b"
+		return nil
+	})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "patch")
+	_, err = store.ApplyBatch(ctx, []storage.BatchOp{{Op: storage.BatchUpdate, ID: a.ID, Description: "b"}})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "batch update")
+	err = store.UpdateTodoByID(ctx, elsewhere.ID, &storage.Todo{ListID: storage.DefaultListID, Description: "b"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "move")
+	err = store.UpdateTodoByID(ctx, done.ID, &storage.Todo{Description: "c"})
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "reopen")
+
+	// A todo keeps its own description, and completed ones don't count
+	require.NoError(t, store.UpdateTodoByID(ctx, a.ID, &storage.Todo{Description: "a", Priority: storage.PriorityHigh}))
+	require.NoError(t, store.UpdateTodoByID(ctx, done.ID, &storage.Todo{Description: "c", Completed: true}))
+
+	require.NoError(t, store.DeleteTodoByID(ctx, a.ID))
+	_, err = store.AddTodo(ctx, "a")
+	require.NoError(t, err)
+	_, err = store.RestoreTodoByID(ctx, a.ID)
+	assert.True(t, errors.Is(err, storage.ErrDuplicateTodo), "restore")
+}
@@ -0,0 +1,156 @@
+package unit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdownCodec_RoundTrip(t *testing.T) {
+	codec := &storage.MarkdownCodec{Lists: []*storage.List{
+		{ID: storage.DefaultListID, Name: "Inbox"},
+		{ID: 2, Name: "Release 1.2"},
+	}}
+	todos := []*storage.Todo{
+		{ID: 1, ListID: 2, Description: "Cut the branch"},
+		{ID: 2, ListID: storage.DefaultListID, Description: "Water plants", Completed: true},
+		{ID: 3, ListID: 2, ParentID: 1, Description: "Freeze\nmerges", Completed: true},
+		{ID: 4, ListID: 2, ParentID: 3, Description: "Tell the team"},
+		{ID: 5, ListID: 7, Description: "Unnamed list"},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, codec.Encode(&out, todos))
+	assert.Equal(t, strings.Join([]string{
+		"## Inbox",
+		"",
+		"- [x] Water plants",
+		"",
+		"## Release 1.2",
+		"",
+		"- [ ] Cut the branch",
+		"  - [x] Freeze merges",
+		"    - [ ] Tell the team",
+		"",
+		"## List 7",
+		"",
+		"- [ ] Unnamed list",
+		"",
+	}, "\n"), out.String())
+
+	decoded, err := codec.Decode(&out)
+	require.NoError(t, err)
+	require.Len(t, decoded, 5)
+	for i, want := range []struct {
+		description string
+		listID      int
+		parent      int
+		completed   bool
+	}{
+		{"Water plants", storage.DefaultListID, 0, true},
+		{"Cut the branch", 2, 0, false},
+		{"Freeze merges", 2, 2, true},
+		{"Tell the team", 2, 3, false},
+		{"Unnamed list", 0, 0, false},
+	} {
+		assert.Equal(t, i+1, decoded[i].ID)
+		assert.Equal(t, want.description, decoded[i].Description)
+		assert.Equal(t, want.listID, decoded[i].ListID, want.description)
+		assert.Equal(t, want.parent, decoded[i].ParentID, want.description)
+		assert.Equal(t, want.completed, decoded[i].Completed, want.description)
+	}
+}
+
+func TestMarkdownCodec_DecodePullRequest(t *testing.T) {
+	description := strings.Join([]string{
+		"# Release checklist",
+		"Some prose with a - [ ] that is not an item.",
+		"* [X] Changelog",
+		"\t1. [ ] Breaking changes",
+		"- Plain bullet",
+		"```",
+		"- [ ] in a code block",
+		"```",
+		"+ [ ] Tag the release",
+	}, "\n")
+
+	created := []string{}
+	codec := &storage.MarkdownCodec{CreateList: func(name string) (*storage.List, error) {
+		created = append(created, name)
+		return &storage.List{ID: 9, Name: name}, nil
+	}}
+	todos, err := codec.Decode(strings.NewReader(description))
+	require.NoError(t, err)
+	require.Len(t, todos, 3)
+	assert.Equal(t, []string{"Release checklist"}, created)
+	assert.True(t, todos[0].Completed)
+	assert.Equal(t, 9, todos[0].ListID)
+	assert.Equal(t, todos[0].ID, todos[1].ParentID)
+	assert.Equal(t, "Tag the release", todos[2].Description)
+	assert.Zero(t, todos[2].ParentID)
+
+	failing := &storage.MarkdownCodec{CreateList: func(name string) (*storage.List, error) {
+		return nil, errors.New("disk full")
+	}}
+	_, err = failing.Decode(strings.NewReader("## New\n- [ ] Item\n"))
+	assert.Error(t, err)
+}
+
+func TestMarkdownCodec_WithLists(t *testing.T) {
+	var codec storage.ListAware = &storage.MarkdownCodec{}
+	lists := []*storage.List{{ID: 2, Name: "Work"}}
+	configured := codec.WithLists(lists, nil).(*storage.MarkdownCodec)
+	assert.Equal(t, lists, configured.Lists)
+	assert.Nil(t, configured.CreateList)
+
+	// Lists set on the codec win over those of the store
+	own := &storage.MarkdownCodec{Lists: []*storage.List{{ID: 3, Name: "Home"}}}
+	configured = own.WithLists(lists, nil).(*storage.MarkdownCodec)
+	assert.Equal(t, "Home", configured.Lists[0].Name)
+}
+
+func TestDownloadUpload_Markdown(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewTodoListWithOptions(storage.Options{})
+	source.DisableLogging()
+	list, err := source.CreateList(ctx, &storage.List{Name: "Groceries"})
+	require.NoError(t, err)
+	parent, err := source.CreateTodo(ctx, &storage.Todo{Description: "Bake a cake", ListID: list.ID})
+	require.NoError(t, err)
+	_, err = source.CreateTodo(ctx, &storage.Todo{Description: "Buy flour", ListID: list.ID, ParentID: parent.ID})
+	require.NoError(t, err)
+	_, err = source.AddTodo(ctx, "Call the bank")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "todos.md")
+	require.NoError(t, source.Download(ctx, path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "## Groceries\n\n- [ ] Bake a cake\n  - [ ] Buy flour\n")
+
+	// A fresh store gets the lists the headings name
+	target := storage.NewTodoListWithOptions(storage.Options{})
+	target.DisableLogging()
+	require.NoError(t, target.Upload(ctx, path))
+
+	lists, err := target.ListLists(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, lists, 2)
+	assert.Equal(t, "Groceries", lists[1].Name)
+
+	todos, err := target.GetAllTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, todos, 3)
+	assert.Equal(t, "Call the bank", todos[0].Description)
+	assert.Equal(t, storage.DefaultListID, todos[0].ListID)
+	assert.Equal(t, lists[1].ID, todos[1].ListID)
+	assert.Equal(t, todos[1].ID, todos[2].ParentID)
+}
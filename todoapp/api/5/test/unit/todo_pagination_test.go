@@ -0,0 +1,58 @@
+package unit_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListTodosPagination walks every page and checks the todos come back once, in ID order
+func TestListTodosPagination(t *testing.T) {
+	todoList := storage.NewTodoListWithOptions(storage.Options{Store: storage.NewInMemoryStore()})
+	todoList.DisableLogging()
+	ctx := context.Background()
+
+	for i := 1; i <= 7; i++ {
+		_, err := todoList.AddTodo(ctx, fmt.Sprintf("Todo %d", i))
+		require.NoError(t, err)
+	}
+
+	var ids []int
+	opts := storage.ListOptions{Limit: 3}
+	pages := 0
+	for {
+		page, err := todoList.ListTodos(ctx, opts)
+		require.NoError(t, err)
+		pages++
+		for _, todo := range page.Todos {
+			ids = append(ids, todo.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	assert.Equal(t, 3, pages)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7}, ids)
+}
+
+func TestListTodosInvalidOptions(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+
+	_, err := store.ListTodos(ctx, storage.ListOptions{Cursor: "not a cursor"})
+	assert.Error(t, err)
+
+	_, err = store.ListTodos(ctx, storage.ListOptions{Limit: storage.MaxPageLimit + 1})
+	assert.Error(t, err)
+
+	page, err := store.ListTodos(ctx, storage.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Todos)
+	assert.Empty(t, page.NextCursor)
+}
@@ -0,0 +1,94 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	todo, err := store.AddTodo(ctx, "Write report")
+	require.NoError(t, err)
+
+	patch, err := storage.NewMergePatch([]byte(`{"completed": true}`))
+	require.NoError(t, err)
+	patched, err := store.PatchTodoByID(ctx, todo.ID, patch)
+	require.NoError(t, err)
+	assert.Equal(t, "Write report", patched.Description)
+	assert.True(t, patched.Completed)
+	assert.Equal(t, 2, patched.Version)
+
+	// null removes the member, which leaves an empty description
+	patch, err = storage.NewMergePatch([]byte(`{"description": null}`))
+	require.NoError(t, err)
+	_, err = store.PatchTodoByID(ctx, todo.ID, patch)
+	assert.True(t, errors.Is(err, storage.ErrInvalidInput))
+
+	_, err = storage.NewMergePatch([]byte(`{"completed":`))
+	assert.True(t, errors.Is(err, storage.ErrInvalidInput))
+}
+
+func TestJSONPatch(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	todo, err := store.AddTodo(ctx, "Write report")
+	require.NoError(t, err)
+
+	patch, err := storage.NewJSONPatch([]byte(`[
+		{"op": "test", "path": "/completed", "value": false},
+		{"op": "replace", "path": "/completed", "value": true},
+		{"op": "copy", "from": "/description", "path": "/description"}
+	]`))
+	require.NoError(t, err)
+	patched, err := store.PatchTodoByID(ctx, todo.ID, patch)
+	require.NoError(t, err)
+	assert.True(t, patched.Completed)
+	assert.Equal(t, "Write report", patched.Description)
+
+	tests := []struct {
+		name  string
+		patch string
+		code  storage.ErrorCode
+	}{
+		{"failed test", `[{"op": "test", "path": "/completed", "value": false}]`, storage.ErrPatchConflict},
+		{"read-only field", `[{"op": "replace", "path": "/version", "value": 7}]`, storage.ErrInvalidInput},
+		{"unknown field", `[{"op": "add", "path": "/owner", "value": "me"}]`, storage.ErrInvalidInput},
+		{"missing path", `[{"op": "remove", "path": "/missing"}]`, storage.ErrInvalidInput},
+		{"wrong type", `[{"op": "replace", "path": "/completed", "value": "yes"}]`, storage.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := storage.NewJSONPatch([]byte(tt.patch))
+			require.NoError(t, err)
+			_, err = store.PatchTodoByID(ctx, todo.ID, patch)
+			assert.True(t, errors.Is(err, tt.code), "got %v", err)
+		})
+	}
+
+	// A failed patch leaves the todo unchanged
+	stored, _ := store.GetTodoByID(ctx, todo.ID)
+	assert.Equal(t, patched, stored)
+
+	for _, invalid := range []string{
+		`{"op": "add"}`,
+		`[{"op": "frobnicate", "path": "/completed"}]`,
+		`[{"op": "add", "path": "/completed"}]`,
+		`[{"op": "add", "path": "completed", "value": true}]`,
+	} {
+		_, err := storage.NewJSONPatch([]byte(invalid))
+		assert.True(t, errors.Is(err, storage.ErrInvalidInput), invalid)
+	}
+}
+
+func TestPatchTodoByID_NotFound(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	patch, _ := storage.NewMergePatch([]byte(`{"completed": true}`))
+	_, err := store.PatchTodoByID(context.Background(), 42, patch)
+	assert.True(t, errors.Is(err, storage.ErrTodoNotFound))
+}
@@ -0,0 +1,166 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTodo_PlanningFields(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	store := storage.NewInMemoryStore()
+	store.Clock = clock
+	ctx := context.Background()
+
+	due := time.Date(2024, 1, 5, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	todo, err := store.CreateTodo(ctx, &storage.Todo{
+		Description: "Plan sprint",
+		DueDate:     &due,
+		Priority:    storage.PriorityHigh,
+		Tags:        []string{"Work", "planning", "work"},
+		Version:     7,
+		CreatedAt:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, todo.Version)
+	assert.Equal(t, []string{"planning", "work"}, todo.Tags)
+	assert.Equal(t, due.UTC(), *todo.DueDate)
+	assert.Equal(t, time.UTC, todo.DueDate.Location())
+	assert.Equal(t, clock.Now(), todo.CreatedAt, "client timestamps are ignored")
+	assert.Equal(t, clock.Now(), todo.UpdatedAt)
+	assert.Nil(t, todo.CompletedAt)
+}
+
+func TestUpdateTodo_Timestamps(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	store := storage.NewInMemoryStore()
+	store.Clock = clock
+	ctx := context.Background()
+	todo, err := store.AddTodo(ctx, "Ship it")
+	require.NoError(t, err)
+	created := clock.Now()
+
+	clock.Advance(time.Hour)
+	completed := &storage.Todo{Description: "Ship it", Completed: true}
+	require.NoError(t, store.UpdateTodoByID(ctx, todo.ID, completed))
+	completedAt := clock.Now()
+	assert.Equal(t, created, completed.CreatedAt)
+	assert.Equal(t, completedAt, completed.UpdatedAt)
+	assert.Equal(t, completedAt, *completed.CompletedAt)
+
+	// Editing a completed todo keeps the time it was completed
+	clock.Advance(time.Hour)
+	edited := &storage.Todo{Description: "Shipped", Completed: true}
+	require.NoError(t, store.UpdateTodoByID(ctx, todo.ID, edited))
+	assert.Equal(t, completedAt, *edited.CompletedAt)
+	assert.Equal(t, clock.Now(), edited.UpdatedAt)
+
+	// Reopening clears it
+	reopened := &storage.Todo{Description: "Shipped"}
+	require.NoError(t, store.UpdateTodoByID(ctx, todo.ID, reopened))
+	assert.Nil(t, reopened.CompletedAt)
+}
+
+func TestValidateTodo_PlanningFields(t *testing.T) {
+	tags := make([]string, storage.MaxTags+1)
+	for i := range tags {
+		tags[i] = "tag"
+	}
+	err := storage.ValidateTodo(&storage.Todo{
+		Description: "Tagged",
+		Priority:    storage.Priority(9),
+		Tags:        []string{"ok", "two words", "a,b", " "},
+	})
+	require.True(t, errors.Is(err, storage.ErrInvalidInput))
+	assert.Equal(t, []storage.FieldError{
+		{Field: "priority", Message: "must be one of none, low, medium, high, urgent"},
+		{Field: "tags[1]", Message: "must be non-empty, without spaces or commas and at most 50 characters"},
+		{Field: "tags[2]", Message: "must be non-empty, without spaces or commas and at most 50 characters"},
+		{Field: "tags[3]", Message: "must be non-empty, without spaces or commas and at most 50 characters"},
+	}, storage.Classify(err).Fields)
+
+	err = storage.ValidateTodo(&storage.Todo{Description: "Over-tagged", Tags: tags})
+	assert.Equal(t, "tags", storage.Classify(err).Fields[0].Field)
+}
+
+func TestPriorityJSON(t *testing.T) {
+	var todo storage.Todo
+	require.NoError(t, json.Unmarshal([]byte(`{"description": "x", "priority": "Urgent"}`), &todo))
+	assert.Equal(t, storage.PriorityUrgent, todo.Priority)
+
+	data, err := json.Marshal(&storage.Todo{Description: "x", Priority: storage.PriorityLow})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"priority":"low"`)
+
+	data, err = json.Marshal(&storage.Todo{Description: "x"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "priority")
+
+	assert.Error(t, json.Unmarshal([]byte(`{"priority": "someday"}`), &todo))
+	assert.Error(t, json.Unmarshal([]byte(`{"priority": 2}`), &todo))
+}
+
+func TestListTodos_TagAndOverdueFilters(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	for _, todo := range []*storage.Todo{
+		{Description: "Late", DueDate: &past, Tags: []string{"work"}, Priority: storage.PriorityLow},
+		{Description: "Done late", DueDate: &past, Completed: true, Priority: storage.PriorityUrgent},
+		{Description: "Upcoming", DueDate: &future, Tags: []string{"home", "work"}, Priority: storage.PriorityHigh},
+		{Description: "Someday"},
+	} {
+		_, err := store.CreateTodo(ctx, todo)
+		require.NoError(t, err)
+	}
+
+	descriptions := func(filter storage.TodoFilter, sort storage.TodoSort) []string {
+		filter.Now = now
+		page, err := store.ListTodos(ctx, storage.ListOptions{Filter: filter, Sort: sort})
+		require.NoError(t, err)
+		var result []string
+		for _, todo := range page.Todos {
+			result = append(result, todo.Description)
+		}
+		return result
+	}
+
+	yes, no := true, false
+	assert.Equal(t, []string{"Late", "Upcoming"}, descriptions(storage.TodoFilter{Tag: "WORK"}, storage.TodoSort{}))
+	assert.Equal(t, []string{"Late"}, descriptions(storage.TodoFilter{Overdue: &yes}, storage.TodoSort{}))
+	assert.Equal(t, []string{"Done late", "Upcoming", "Someday"}, descriptions(storage.TodoFilter{Overdue: &no}, storage.TodoSort{}))
+	assert.Equal(t, []string{"Done late", "Upcoming", "Late", "Someday"},
+		descriptions(storage.TodoFilter{}, storage.TodoSort{Field: storage.SortByPriority, Direction: storage.SortDescending}))
+}
+
+func TestPatchTodo_PlanningFields(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	todo, err := store.CreateTodo(ctx, &storage.Todo{Description: "Patchable", Tags: []string{"a"}})
+	require.NoError(t, err)
+
+	patch, err := storage.NewJSONPatch([]byte(`[
+		{"op": "add", "path": "/tags/-", "value": "B"},
+		{"op": "add", "path": "/priority", "value": "medium"},
+		{"op": "add", "path": "/due_date", "value": "2024-02-01T00:00:00Z"}
+	]`))
+	require.NoError(t, err)
+	patched, err := store.PatchTodoByID(ctx, todo.ID, patch)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, patched.Tags)
+	assert.Equal(t, storage.PriorityMedium, patched.Priority)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), *patched.DueDate)
+
+	patch, err = storage.NewMergePatch([]byte(`{"created_at": "2000-01-01T00:00:00Z"}`))
+	require.NoError(t, err)
+	_, err = store.PatchTodoByID(ctx, todo.ID, patch)
+	require.True(t, errors.Is(err, storage.ErrInvalidInput))
+	assert.Equal(t, "created_at", storage.Classify(err).Fields[0].Field)
+}
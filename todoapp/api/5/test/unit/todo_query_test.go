@@ -0,0 +1,57 @@
+package unit_test
+
+import (
+	"context"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTodoSort(t *testing.T) {
+	sort, err := storage.ParseTodoSort("-description")
+	require.NoError(t, err)
+	assert.Equal(t, storage.TodoSort{Field: storage.SortByDescription, Direction: storage.SortDescending}, sort)
+
+	sort, err = storage.ParseTodoSort("")
+	require.NoError(t, err)
+	assert.Equal(t, storage.SortByID, sort.Field)
+
+	_, err = storage.ParseTodoSort("password")
+	assert.Error(t, err)
+}
+
+// TestListTodosFilterAndSort lists incomplete todos containing "deploy", newest first
+func TestListTodosFilterAndSort(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	for _, description := range []string{"Deploy api", "Write docs", "deploy worker", "Deploy web"} {
+		_, err := store.AddTodo(ctx, description)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.UpdateTodoByID(ctx, 4, &storage.Todo{ID: 4, Description: "Deploy web", Completed: true}))
+
+	completed := false
+	opts := storage.ListOptions{
+		Filter: storage.TodoFilter{Completed: &completed, Contains: "DEPLOY"},
+		Sort:   storage.TodoSort{Field: storage.SortByID, Direction: storage.SortDescending},
+		Limit:  1,
+	}
+	first, err := store.ListTodos(ctx, opts)
+	require.NoError(t, err)
+	require.Len(t, first.Todos, 1)
+	assert.Equal(t, 3, first.Todos[0].ID)
+
+	opts.Cursor = first.NextCursor
+	second, err := store.ListTodos(ctx, opts)
+	require.NoError(t, err)
+	require.Len(t, second.Todos, 1)
+	assert.Equal(t, 1, second.Todos[0].ID)
+	assert.Empty(t, second.NextCursor)
+
+	// A cursor only makes sense for the sort order it was issued for
+	opts.Sort = storage.TodoSort{Field: storage.SortByDescription}
+	_, err = store.ListTodos(ctx, opts)
+	assert.Error(t, err)
+}
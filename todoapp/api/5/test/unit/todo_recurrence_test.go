@@ -0,0 +1,131 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRRule_Next(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 9, 30, 0, 0, time.UTC) }
+
+	for _, test := range []struct {
+		rule  string
+		after time.Time
+		next  time.Time // Zero when the rule has no more occurrences
+	}{
+		{"FREQ=DAILY", day(1, 31), day(2, 1)},
+		{"FREQ=DAILY;INTERVAL=2", day(1, 1), day(1, 3)},
+		{"FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", day(1, 5), day(1, 8)},
+		{"FREQ=WEEKLY", day(1, 3), day(1, 10)},
+		{"FREQ=WEEKLY;BYDAY=MO,TH", day(1, 1), day(1, 4)},
+		{"FREQ=WEEKLY;BYDAY=MO,TH", day(1, 4), day(1, 8)},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", day(1, 4), day(1, 15)},
+		{"FREQ=MONTHLY", day(1, 15), day(2, 15)},
+		{"FREQ=MONTHLY", day(1, 31), day(3, 31)},
+		{"FREQ=MONTHLY;BYDAY=2TU", day(1, 1), day(1, 9)},
+		{"FREQ=MONTHLY;BYDAY=-1FR", day(1, 26), day(2, 23)},
+		{"RRULE:FREQ=DAILY;UNTIL=20240102", day(1, 1), day(1, 2)},
+		{"FREQ=DAILY;UNTIL=20240102", day(1, 2), time.Time{}},
+	} {
+		t.Run(test.rule, func(t *testing.T) {
+			rule, err := storage.ParseRRule(test.rule)
+			require.NoError(t, err)
+			next, ok := rule.Next(test.after)
+			assert.Equal(t, !test.next.IsZero(), ok)
+			assert.Equal(t, test.next, next)
+		})
+	}
+}
+
+func TestParseRRule(t *testing.T) {
+	rule, err := storage.ParseRRule("rrule:freq=weekly;byday=mo,fr;interval=1;count=3")
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=3", rule.String())
+
+	for _, text := range []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=DAILY;BYHOUR=9",
+	} {
+		_, err := storage.ParseRRule(text)
+		assert.Error(t, err, text)
+	}
+
+	err = storage.ValidateTodo(&storage.Todo{Description: "Water plants", Recurrence: "FREQ=HOURLY"})
+	var todoErr *storage.TodoError
+	require.True(t, errors.As(err, &todoErr))
+	require.Len(t, todoErr.Fields, 1)
+	assert.Equal(t, "recurrence", todoErr.Fields[0].Field)
+}
+
+func TestUpdateTodo_CompletingRecurringTodo(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	todoList := storage.NewTodoListWithOptions(storage.Options{Clock: clock})
+	todoList.DisableLogging()
+	ctx := context.Background()
+
+	due := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	todo, err := todoList.CreateTodo(ctx, &storage.Todo{
+		Description: "Stand-up",
+		DueDate:     &due,
+		Tags:        []string{"work"},
+		Recurrence:  "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=2",
+	})
+	require.NoError(t, err)
+
+	todo.Completed = true
+	require.NoError(t, todoList.UpdateTodoByID(ctx, todo.ID, todo))
+	todos, err := todoList.GetAllTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+	next := todos[1]
+	assert.False(t, next.Completed)
+	assert.Equal(t, "Stand-up", next.Description)
+	assert.Equal(t, []string{"work"}, next.Tags)
+	assert.Equal(t, time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC), *next.DueDate)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=1", next.Recurrence)
+
+	// Saving a completed todo again, or completing the last occurrence, creates nothing
+	require.NoError(t, todoList.UpdateTodoByID(ctx, todo.ID, todo))
+	next.Completed = true
+	require.NoError(t, todoList.UpdateTodoByID(ctx, next.ID, next))
+	todos, err = todoList.GetAllTodos(ctx)
+	require.NoError(t, err)
+	assert.Len(t, todos, 2)
+}
+
+func TestUpdateTodo_RecurringTodoWithoutDueDate(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	todoList := storage.NewTodoListWithOptions(storage.Options{Clock: clock})
+	todoList.DisableLogging()
+	ctx := context.Background()
+
+	todo, err := todoList.CreateTodo(ctx, &storage.Todo{Description: "Water plants", Recurrence: "FREQ=DAILY;INTERVAL=3"})
+	require.NoError(t, err)
+
+	// The next occurrence is counted from the time the todo is completed
+	clock.Advance(36 * time.Hour)
+	_, err = todoList.PatchTodoByID(ctx, todo.ID, func(todo *storage.Todo) error {
+		todo.Completed = true
+		return nil
+	})
+	require.NoError(t, err)
+	todos, err := todoList.GetAllTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+	assert.Equal(t, time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC), *todos[1].DueDate)
+	assert.Equal(t, "FREQ=DAILY;INTERVAL=3", todos[1].Recurrence)
+}
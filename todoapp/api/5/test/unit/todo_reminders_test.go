@@ -0,0 +1,247 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"todoapp/5/reminder"
+	"todoapp/5/storage"
+	"todoapp/5/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier keeps the notifications it is given.
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []*reminder.Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification *reminder.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func (n *recordingNotifier) descriptions() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	descriptions := []string{}
+	for _, notification := range n.notifications {
+		descriptions = append(descriptions, notification.Todo.Description)
+	}
+	return descriptions
+}
+
+func newReminderScheduler(t *testing.T) (*storage.TodoList, *reminder.Scheduler, *recordingNotifier, *fakeClock) {
+	t.Helper()
+	clock := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	todoList := storage.NewTodoListWithOptions(storage.Options{Clock: clock})
+	todoList.DisableLogging()
+	notifier := &recordingNotifier{}
+	scheduler := reminder.NewScheduler(todoList.Store, notifier)
+	scheduler.Clock = clock
+	scheduler.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return todoList, scheduler, notifier, clock
+}
+
+func TestScheduler_FiresDueRemindersOnce(t *testing.T) {
+	todoList, scheduler, notifier, clock := newReminderScheduler(t)
+	ctx := context.Background()
+
+	todo, err := todoList.AddTodo(ctx, "Call the dentist")
+	require.NoError(t, err)
+	first, err := todoList.AddReminder(ctx, todo.ID, clock.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = todoList.AddReminder(ctx, todo.ID, clock.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+
+	fired, err := scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, fired, "nothing is due yet")
+
+	clock.Advance(90 * time.Minute)
+	fired, err = scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fired)
+	fired, err = scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, fired, "a reminder fires once")
+	assert.Equal(t, []string{"Call the dentist"}, notifier.descriptions())
+	assert.Equal(t, first.ID, notifier.notifications[0].Reminder.ID)
+
+	reminders, err := todoList.ListReminders(ctx, todo.ID)
+	require.NoError(t, err)
+	require.Len(t, reminders, 2)
+	assert.Equal(t, clock.Now(), *reminders[0].FiredAt)
+	assert.Nil(t, reminders[1].FiredAt)
+}
+
+func TestScheduler_SkipsCompletedAndTrashedTodos(t *testing.T) {
+	todoList, scheduler, notifier, clock := newReminderScheduler(t)
+	ctx := context.Background()
+
+	for _, description := range []string{"Done", "Trashed", "Open"} {
+		todo, err := todoList.AddTodo(ctx, description)
+		require.NoError(t, err)
+		_, err = todoList.AddReminder(ctx, todo.ID, clock.Now())
+		require.NoError(t, err)
+	}
+	require.NoError(t, todoList.UpdateTodoByID(ctx, 1, &storage.Todo{Description: "Done", Completed: true}))
+	require.NoError(t, todoList.DeleteTodoByID(ctx, 2))
+
+	fired, err := scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fired)
+	assert.Equal(t, []string{"Open"}, notifier.descriptions())
+
+	// The reminder of the trashed todo waits for it to be restored
+	_, err = todoList.RestoreTodoByID(ctx, 2)
+	require.NoError(t, err)
+	fired, err = scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fired)
+	assert.Equal(t, []string{"Open", "Trashed"}, notifier.descriptions())
+}
+
+// flakyNotifier fails the first failures notifications it is given.
+type flakyNotifier struct {
+	failures int
+	calls    int
+}
+
+func (n *flakyNotifier) Notify(ctx context.Context, notification *reminder.Notification) error {
+	n.calls++
+	if n.calls <= n.failures {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestScheduler_RetriesFailedDeliveries(t *testing.T) {
+	todoList, scheduler, notifier, clock := newReminderScheduler(t)
+	flaky := &flakyNotifier{failures: 1}
+	scheduler.Notifiers = append(scheduler.Notifiers, flaky)
+	ctx := context.Background()
+
+	todo, err := todoList.AddTodo(ctx, "Pay rent")
+	require.NoError(t, err)
+	_, err = todoList.AddReminder(ctx, todo.ID, clock.Now())
+	require.NoError(t, err)
+
+	fired, err := scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, fired, "a failed delivery doesn't count")
+
+	// The claim holds off other runs until the lease runs out
+	fired, err = scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, fired)
+	assert.Equal(t, 1, flaky.calls)
+
+	clock.Advance(scheduler.Lease)
+	fired, err = scheduler.FireDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fired)
+	assert.Equal(t, 2, flaky.calls)
+	assert.Equal(t, []string{"Pay rent", "Pay rent"}, notifier.descriptions(), "retries go to every notifier")
+
+	reminders, err := todoList.ListReminders(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, clock.Now(), *reminders[0].FiredAt)
+	assert.Equal(t, 2, reminders[0].Attempts)
+}
+
+func TestScheduler_GivesUpAfterMaxAttempts(t *testing.T) {
+	todoList, scheduler, _, clock := newReminderScheduler(t)
+	flaky := &flakyNotifier{failures: 100}
+	scheduler.Notifiers = []reminder.Notifier{flaky}
+	scheduler.MaxAttempts = 3
+	ctx := context.Background()
+
+	todo, err := todoList.AddTodo(ctx, "Unreachable")
+	require.NoError(t, err)
+	_, err = todoList.AddReminder(ctx, todo.ID, clock.Now())
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err := scheduler.FireDue(ctx)
+		require.NoError(t, err)
+		clock.Advance(scheduler.Lease)
+	}
+	assert.Equal(t, 3, flaky.calls)
+	reminders, err := todoList.ListReminders(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, reminders[0].FiredAt)
+}
+
+func TestReminders_Errors(t *testing.T) {
+	todoList, _, _, clock := newReminderScheduler(t)
+	ctx := context.Background()
+
+	_, err := todoList.AddReminder(ctx, 42, clock.Now())
+	assert.True(t, errors.Is(err, storage.ErrTodoNotFound))
+
+	todo, err := todoList.AddTodo(ctx, "Renew passport")
+	require.NoError(t, err)
+	_, err = todoList.AddReminder(ctx, todo.ID, time.Time{})
+	assert.True(t, errors.Is(err, storage.ErrInvalidInput))
+
+	added, err := todoList.AddReminder(ctx, todo.ID, clock.Now())
+	require.NoError(t, err)
+	assert.True(t, errors.Is(todoList.DeleteReminder(ctx, todo.ID+1, added.ID), storage.ErrReminderNotFound))
+	require.NoError(t, todoList.DeleteReminder(ctx, todo.ID, added.ID))
+	assert.True(t, errors.Is(todoList.DeleteReminder(ctx, todo.ID, added.ID), storage.ErrReminderNotFound))
+}
+
+func TestNotifiers(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	notification := &reminder.Notification{
+		Reminder: &storage.Reminder{ID: 3, TodoID: 7, At: at, FiredAt: &at},
+		Todo:     &storage.Todo{ID: 7, Description: "Water plants"},
+	}
+
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+	require.NoError(t, reminder.NewWebhookNotifier(server.URL, "s3cret").Notify(ctx, notification))
+	assert.Equal(t, reminder.EventType, header.Get(webhook.EventHeader))
+	assert.True(t, webhook.Verify("s3cret", header.Get(webhook.SignatureHeader), header.Get(webhook.TimestampHeader),
+		body, time.Now(), time.Minute))
+	var received reminder.Notification
+	require.NoError(t, json.Unmarshal(body, &received))
+	assert.Equal(t, "Water plants", received.Todo.Description)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	assert.Error(t, reminder.NewWebhookNotifier(failing.URL, "s3cret").Notify(ctx, notification))
+
+	path := filepath.Join(t.TempDir(), "reminders.jsonl")
+	file := &reminder.FileNotifier{Path: path}
+	require.NoError(t, file.Notify(ctx, notification))
+	require.NoError(t, file.Notify(ctx, notification))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"description":"Water plants"`)
+}
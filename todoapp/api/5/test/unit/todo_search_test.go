@@ -0,0 +1,48 @@
+package unit_test
+
+import (
+	"context"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemorySearchTodos(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+	for _, description := range []string{
+		"Deploy the API",
+		"Write deployment notes for the deploy",
+		"Buy milk",
+		"Review API docs",
+	} {
+		_, err := store.AddTodo(ctx, description)
+		require.NoError(t, err)
+	}
+
+	results, err := store.SearchTodos(ctx, "deploy", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	// The todo mentioning deploy twice ranks first, prefixes such as "deployment" match too
+	assert.Equal(t, 2, results[0].Todo.ID)
+	assert.Equal(t, "Write <mark>deployment</mark> notes for the <mark>deploy</mark>", results[0].Snippet)
+	assert.Greater(t, results[0].Rank, results[1].Rank)
+
+	// Every term must match
+	results, err = store.SearchTodos(ctx, "api deploy", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 1, results[0].Todo.ID)
+
+	// The index follows updates and deletes
+	require.NoError(t, store.UpdateTodoByID(ctx, 1, &storage.Todo{ID: 1, Description: "Ship the API"}))
+	require.NoError(t, store.DeleteTodoByID(ctx, 2))
+	results, err = store.SearchTodos(ctx, "deploy", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	_, err = store.SearchTodos(ctx, "  !! ", 10)
+	assert.Error(t, err)
+}
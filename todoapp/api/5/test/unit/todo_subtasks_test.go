@@ -0,0 +1,170 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSubtaskList creates a list holding "Release" with the subtasks "Build" and "Test",
+// which has a subtask of its own, "Unit".
+func newSubtaskList(t *testing.T, options storage.SubtaskOptions) (*storage.TodoList, map[string]*storage.Todo) {
+	t.Helper()
+	todoList := storage.NewTodoListWithOptions(storage.Options{Subtasks: options})
+	todoList.DisableLogging()
+	ctx := context.Background()
+
+	todos := map[string]*storage.Todo{}
+	for _, todo := range []struct{ description, parent string }{
+		{"Release", ""}, {"Build", "Release"}, {"Test", "Release"}, {"Unit", "Test"},
+	} {
+		newTodo := &storage.Todo{Description: todo.description}
+		if parent, ok := todos[todo.parent]; ok {
+			newTodo.ParentID = parent.ID
+		}
+		created, err := todoList.CreateTodo(ctx, newTodo)
+		require.NoError(t, err)
+		todos[todo.description] = created
+	}
+	return todoList, todos
+}
+
+func TestGetTodoTree(t *testing.T) {
+	todoList, todos := newSubtaskList(t, storage.SubtaskOptions{})
+	ctx := context.Background()
+
+	tree, err := todoList.GetTodoTree(ctx, todos["Release"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Release", tree.Description)
+	require.Len(t, tree.Subtasks, 2)
+	assert.Equal(t, "Build", tree.Subtasks[0].Description)
+	assert.Empty(t, tree.Subtasks[0].Subtasks)
+	require.Len(t, tree.Subtasks[1].Subtasks, 1)
+	assert.Equal(t, "Unit", tree.Subtasks[1].Subtasks[0].Description)
+
+	_, err = todoList.GetTodoTree(ctx, 42)
+	assert.True(t, errors.Is(err, storage.ErrTodoNotFound))
+}
+
+func TestSubtasks_RejectInvalidParents(t *testing.T) {
+	todoList, todos := newSubtaskList(t, storage.SubtaskOptions{})
+	ctx := context.Background()
+
+	for name, parentID := range map[string]int{
+		"itself":     todos["Test"].ID,
+		"descendant": todos["Unit"].ID,
+		"missing":    42,
+	} {
+		update := &storage.Todo{Description: "Test", ParentID: parentID}
+		err := todoList.UpdateTodoByID(ctx, todos["Test"].ID, update)
+		require.True(t, errors.Is(err, storage.ErrInvalidInput), name)
+		assert.Equal(t, "parent_id", storage.Classify(err).Fields[0].Field, name)
+	}
+
+	other, err := todoList.CreateList(ctx, &storage.List{Name: "Other"})
+	require.NoError(t, err)
+	_, err = todoList.CreateTodo(ctx, &storage.Todo{Description: "Elsewhere", ListID: other.ID, ParentID: todos["Release"].ID})
+	assert.True(t, errors.Is(err, storage.ErrInvalidInput), "subtasks live in their parent's list")
+
+	// Moving a parent to another list would strand its subtasks
+	err = todoList.UpdateTodoByID(ctx, todos["Test"].ID, &storage.Todo{Description: "Test", ListID: other.ID})
+	assert.True(t, errors.Is(err, storage.ErrHasSubtasks))
+}
+
+func TestSubtasks_DeleteRules(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("cascade", func(t *testing.T) {
+		todoList, todos := newSubtaskList(t, storage.SubtaskOptions{OnDelete: storage.DeleteCascade})
+		require.NoError(t, todoList.DeleteTodoByID(ctx, todos["Test"].ID))
+		trash, err := todoList.ListTrash(ctx)
+		require.NoError(t, err)
+		assert.Len(t, trash, 2)
+
+		// Restoring a subtask of a trashed todo brings it back at the top level
+		restored, err := todoList.RestoreTodoByID(ctx, todos["Unit"].ID)
+		require.NoError(t, err)
+		assert.Zero(t, restored.ParentID)
+	})
+
+	t.Run("promote", func(t *testing.T) {
+		todoList, todos := newSubtaskList(t, storage.SubtaskOptions{OnDelete: storage.DeletePromote})
+		require.NoError(t, todoList.DeleteTodoByID(ctx, todos["Test"].ID))
+		unit, err := todoList.GetTodoByID(ctx, todos["Unit"].ID)
+		require.NoError(t, err)
+		assert.Equal(t, todos["Release"].ID, unit.ParentID)
+	})
+
+	t.Run("restrict", func(t *testing.T) {
+		todoList, todos := newSubtaskList(t, storage.SubtaskOptions{OnDelete: storage.DeleteRestrict})
+		err := todoList.DeleteTodoByID(ctx, todos["Test"].ID)
+		assert.True(t, errors.Is(err, storage.ErrHasSubtasks))
+		require.NoError(t, todoList.DeleteTodoByID(ctx, todos["Unit"].ID))
+		require.NoError(t, todoList.DeleteTodoByID(ctx, todos["Test"].ID))
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		todoList, todos := newSubtaskList(t, storage.SubtaskOptions{OnDelete: storage.DeleteCascade})
+		_, err := todoList.ApplyBatch(ctx, []storage.BatchOp{{Op: storage.BatchDelete, ID: todos["Release"].ID}})
+		require.NoError(t, err)
+		trash, err := todoList.ListTrash(ctx)
+		require.NoError(t, err)
+		assert.Len(t, trash, 4)
+
+		// Every trashed subtask is recorded
+		changes, err := todoList.TodoHistory(ctx, todos["Unit"].ID)
+		require.NoError(t, err)
+		assert.Equal(t, storage.ActionDelete, changes[len(changes)-1].Action)
+	})
+
+	t.Run("failed delete", func(t *testing.T) {
+		todoList, todos := newSubtaskList(t, storage.SubtaskOptions{OnDelete: storage.DeleteCascade})
+		err := todoList.DeleteTodoByIDAtVersion(ctx, todos["Test"].ID, todos["Test"].Version+1)
+		require.True(t, errors.Is(err, storage.ErrVersionConflict))
+
+		// The subtasks stay where they were when the parent's delete fails
+		tree, err := todoList.GetTodoTree(ctx, todos["Test"].ID)
+		require.NoError(t, err)
+		assert.Len(t, tree.Subtasks, 1)
+	})
+}
+
+func TestSubtasks_RollUp(t *testing.T) {
+	ctx := context.Background()
+	complete := func(todoList *storage.TodoList, todo *storage.Todo, completed bool) {
+		update := *todo
+		update.Version = 0
+		update.Completed = completed
+		require.NoError(t, todoList.UpdateTodoByID(ctx, todo.ID, &update))
+	}
+	completed := func(todoList *storage.TodoList, todo *storage.Todo) bool {
+		current, err := todoList.GetTodoByID(ctx, todo.ID)
+		require.NoError(t, err)
+		return current.Completed
+	}
+
+	todoList, todos := newSubtaskList(t, storage.SubtaskOptions{RollUp: true})
+	complete(todoList, todos["Build"], true)
+	assert.False(t, completed(todoList, todos["Release"]))
+
+	// Completing the last open subtask completes every ancestor in turn
+	complete(todoList, todos["Unit"], true)
+	assert.True(t, completed(todoList, todos["Test"]))
+	assert.True(t, completed(todoList, todos["Release"]))
+
+	// Adding an open subtask reopens them
+	_, err := todoList.CreateTodo(ctx, &storage.Todo{Description: "Integration", ParentID: todos["Test"].ID})
+	require.NoError(t, err)
+	assert.False(t, completed(todoList, todos["Test"]))
+	assert.False(t, completed(todoList, todos["Release"]))
+
+	// Without roll-up the parent is left alone
+	todoList, todos = newSubtaskList(t, storage.SubtaskOptions{})
+	complete(todoList, todos["Build"], true)
+	complete(todoList, todos["Unit"], true)
+	assert.False(t, completed(todoList, todos["Test"]))
+}
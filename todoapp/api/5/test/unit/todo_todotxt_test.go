@@ -0,0 +1,121 @@
+package unit_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoTxtCodec_RoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	completed := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	dueAt := time.Date(2024, 1, 12, 9, 30, 0, 0, time.UTC)
+	todos := []*storage.Todo{
+		{
+			ID:          1,
+			Description: "Plan trip to Lisbon",
+			Priority:    storage.PriorityUrgent,
+			Tags:        []string{"+trip", "phone"},
+			DueDate:     &due,
+			ListID:      2,
+			Recurrence:  "FREQ=MONTHLY;BYDAY=-1FR",
+			CreatedAt:   created,
+		},
+		{
+			ParentID:    1,
+			Description: "Book flights, see http://example.com",
+			Completed:   true,
+			CompletedAt: &completed,
+			Priority:    storage.PriorityHigh,
+			DueDate:     &dueAt,
+			ListID:      2,
+			CreatedAt:   created,
+		},
+		{Description: "Water plants", Priority: storage.PriorityLow},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, storage.TodoTxtCodec{}.Encode(&out, todos))
+	assert.Equal(t, strings.Join([]string{
+		"(A) 2024-01-01 Plan trip to Lisbon +trip @phone due:2024-01-10 list:2 id:1 rrule:FREQ=MONTHLY;BYDAY=-1FR",
+		"x 2024-01-05 2024-01-01 Book flights, see http://example.com due:2024-01-12T09:30:00Z pri:B list:2 parent:1",
+		"(D) Water plants",
+	}, "\n")+"\n", out.String())
+
+	decoded, err := storage.TodoTxtCodec{}.Decode(&out)
+	require.NoError(t, err)
+	assert.Equal(t, todos, decoded)
+}
+
+func TestTodoTxtCodec_DecodeOtherTools(t *testing.T) {
+	file := "\ufeff(B) 2024-02-01 Call Mom @phone +Family due:2024-02-03 rec:+2w\n" +
+		"\n" +
+		"x 2024-02-02 Pay rent\n" +
+		"(Q) Read, at 10:30 +books owner:ana\n"
+
+	todos, err := storage.TodoTxtCodec{}.Decode(strings.NewReader(file))
+	require.NoError(t, err)
+	require.Len(t, todos, 3)
+
+	assert.Equal(t, "Call Mom", todos[0].Description)
+	assert.Equal(t, storage.PriorityHigh, todos[0].Priority)
+	assert.Equal(t, []string{"phone", "+Family"}, todos[0].Tags)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), todos[0].CreatedAt)
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2", todos[0].Recurrence)
+
+	assert.True(t, todos[1].Completed)
+	assert.Equal(t, time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC), *todos[1].CompletedAt)
+	assert.True(t, todos[1].CreatedAt.IsZero())
+
+	assert.Equal(t, storage.PriorityLow, todos[2].Priority)
+	assert.Equal(t, "Read, at 10:30 owner:ana", todos[2].Description, "unknown extensions stay in the description")
+
+	for _, invalid := range []string{
+		"Call Mom due:soon",
+		"Call Mom pri:1",
+		"Call Mom parent:first",
+		"Call Mom rec:weekly",
+	} {
+		_, err := storage.TodoTxtCodec{}.Decode(strings.NewReader(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestDownloadUpload_TodoTxt(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewTodoListWithOptions(storage.Options{})
+	source.DisableLogging()
+	parent, err := source.CreateTodo(ctx, &storage.Todo{Description: "Release", Priority: storage.PriorityHigh})
+	require.NoError(t, err)
+	_, err = source.CreateTodo(ctx, &storage.Todo{Description: "Write notes", ParentID: parent.ID, Tags: []string{"docs"}})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "todo.txt")
+	require.NoError(t, source.Download(ctx, path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Write notes @docs parent:1")
+
+	target := storage.NewTodoListWithOptions(storage.Options{})
+	target.DisableLogging()
+	_, err = target.AddTodo(ctx, "Existing")
+	require.NoError(t, err)
+	require.NoError(t, target.Upload(ctx, path))
+
+	todos, err := target.GetAllTodos(ctx)
+	require.NoError(t, err)
+	require.Len(t, todos, 3)
+	assert.Equal(t, "Release", todos[1].Description)
+	assert.Equal(t, storage.PriorityHigh, todos[1].Priority)
+	assert.Equal(t, todos[1].ID, todos[2].ParentID)
+	assert.Equal(t, []string{"docs"}, todos[2].Tags)
+}
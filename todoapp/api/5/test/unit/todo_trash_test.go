@@ -0,0 +1,89 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashRestoreAndPurge(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	store := storage.NewInMemoryStore()
+	store.Clock = clock
+	todoList := storage.NewTodoListWithOptions(storage.Options{Store: store, Clock: clock})
+	todoList.DisableLogging()
+	ctx := context.Background()
+
+	keep, _ := todoList.AddTodo(ctx, "Keep me")
+	trash, _ := todoList.AddTodo(ctx, "Trash me")
+	require.NoError(t, todoList.DeleteTodoByID(ctx, trash.ID))
+
+	// Trashed todos disappear from the regular reads
+	_, err := todoList.GetTodoByID(ctx, trash.ID)
+	assert.True(t, errors.Is(err, storage.ErrTodoNotFound))
+	all, _ := todoList.GetAllTodos(ctx)
+	assert.Len(t, all, 1)
+	page, _ := todoList.ListTodos(ctx, storage.ListOptions{})
+	assert.Len(t, page.Todos, 1)
+	assert.True(t, errors.Is(todoList.DeleteTodoByID(ctx, trash.ID), storage.ErrTodoNotFound))
+
+	trashed, err := todoList.ListTrash(ctx)
+	require.NoError(t, err)
+	require.Len(t, trashed, 1)
+	assert.Equal(t, clock.Now(), *trashed[0].DeletedAt)
+
+	clock.Advance(time.Minute)
+	restored, err := todoList.RestoreTodoByID(ctx, trash.ID)
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, trash.Version+1, restored.Version, "tags from before the delete no longer match")
+	assert.Equal(t, clock.Now(), restored.UpdatedAt)
+	_, err = todoList.GetTodoByID(ctx, trash.ID)
+	assert.NoError(t, err)
+
+	// Only trashed todos can be purged
+	assert.True(t, errors.Is(todoList.PurgeTodoByID(ctx, keep.ID), storage.ErrTodoNotFound))
+	require.NoError(t, todoList.DeleteTodoByID(ctx, keep.ID))
+	require.NoError(t, todoList.PurgeTodoByID(ctx, keep.ID))
+	trashed, _ = todoList.ListTrash(ctx)
+	assert.Empty(t, trashed)
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	store := storage.NewInMemoryStore()
+	store.Clock = clock
+	todoList := storage.NewTodoListWithOptions(storage.Options{Store: store, Clock: clock})
+	todoList.DisableLogging()
+	ctx := context.Background()
+
+	old, _ := todoList.AddTodo(ctx, "Deleted long ago")
+	require.NoError(t, todoList.DeleteTodoByID(ctx, old.ID))
+	clock.Advance(20 * 24 * time.Hour)
+	recent, _ := todoList.AddTodo(ctx, "Deleted recently")
+	require.NoError(t, todoList.DeleteTodoByID(ctx, recent.ID))
+	clock.Advance(15 * 24 * time.Hour)
+
+	sub := todoList.Events.Subscribe()
+	defer sub.Close()
+	purged, err := todoList.PurgeExpiredTrash(ctx, 30*24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	trashed, _ := todoList.ListTrash(ctx)
+	require.Len(t, trashed, 1)
+	assert.Equal(t, recent.ID, trashed[0].ID)
+
+	// Swept todos are recorded and published like purged ones
+	changes, err := todoList.TodoHistory(ctx, old.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.ActionPurge, changes[len(changes)-1].Action)
+	event := <-sub.Events()
+	assert.Equal(t, storage.EventPurged, event.Type)
+	assert.Equal(t, old.ID, event.TodoID)
+}
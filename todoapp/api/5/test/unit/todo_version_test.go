@@ -0,0 +1,82 @@
+package unit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"todoapp/5/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptimisticConcurrency(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	ctx := context.Background()
+
+	todo, err := store.AddTodo(ctx, "Shared todo")
+	require.NoError(t, err)
+	assert.Equal(t, 1, todo.Version)
+
+	// Two clients read version 1, the first write wins
+	first := &storage.Todo{ID: todo.ID, Description: "First writer", Version: 1}
+	require.NoError(t, store.UpdateTodoByID(ctx, todo.ID, first))
+	assert.Equal(t, 2, first.Version)
+
+	second := &storage.Todo{ID: todo.ID, Description: "Second writer", Version: 1}
+	err = store.UpdateTodoByID(ctx, todo.ID, second)
+	assert.True(t, errors.Is(err, storage.ErrVersionConflict))
+
+	stored, _ := store.GetTodoByID(ctx, todo.ID)
+	assert.Equal(t, "First writer", stored.Description)
+
+	// Version 0 is an unconditional update
+	require.NoError(t, store.UpdateTodoByID(ctx, todo.ID, &storage.Todo{ID: todo.ID, Description: "Blind write"}))
+	stored, _ = store.GetTodoByID(ctx, todo.ID)
+	assert.Equal(t, 3, stored.Version)
+
+	assert.True(t, errors.Is(store.DeleteTodoByIDAtVersion(ctx, todo.ID, 2), storage.ErrVersionConflict))
+	assert.NoError(t, store.DeleteTodoByIDAtVersion(ctx, todo.ID, 3))
+}
+
+// contendedStore is a store whose todos are always changed by another writer
+// between a read and a write.
+type contendedStore struct {
+	*storage.InMemoryStore
+	writes int
+}
+
+func (s *contendedStore) UpdateTodoByID(ctx context.Context, id int, updatedTodo *storage.Todo) error {
+	s.writes++
+	return storage.NewVersionConflictError(id, updatedTodo.Version, updatedTodo.Version+1)
+}
+
+func (s *contendedStore) DeleteTodoTree(ctx context.Context, id, version int, rule storage.DeleteRule) ([]storage.SubtaskChange, error) {
+	s.writes++
+	return nil, storage.NewVersionConflictError(id, version, version+1)
+}
+
+func TestUnconditionalWritesRetryABoundedNumberOfTimes(t *testing.T) {
+	store := &contendedStore{InMemoryStore: storage.NewInMemoryStore()}
+	todoList := storage.NewTodoListWithOptions(storage.Options{Store: store})
+	todoList.DisableLogging()
+	ctx := context.Background()
+	todo, err := store.AddTodo(ctx, "Contended")
+	require.NoError(t, err)
+
+	err = todoList.UpdateTodoByID(ctx, todo.ID, &storage.Todo{Description: "Never written"})
+	assert.True(t, errors.Is(err, storage.ErrVersionConflict))
+	assert.Less(t, store.writes, 10)
+	store.writes = 0
+	err = todoList.DeleteTodoByID(ctx, todo.ID)
+	assert.True(t, errors.Is(err, storage.ErrVersionConflict))
+	assert.Less(t, store.writes, 10)
+
+	// A cancelled request stops retrying
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	store.writes = 0
+	err = todoList.UpdateTodoByID(cancelled, todo.ID, &storage.Todo{Description: "Never written"})
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Zero(t, store.writes)
+}
@@ -0,0 +1,256 @@
+package unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"todoapp/5/storage"
+	"todoapp/5/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a webhook endpoint that checks signatures and answers with the queued statuses,
+// then 200 once they run out.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	events   []*storage.Event
+	requests []*http.Request
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	rec := &receiver{t: t, statuses: statuses}
+	server := httptest.NewServer(rec)
+	t.Cleanup(server.Close)
+	return rec, server
+}
+
+func (rec *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	assert.True(rec.t, webhook.Verify(rec.secret, r.Header.Get(webhook.SignatureHeader),
+		r.Header.Get(webhook.TimestampHeader), body, time.Now(), time.Minute), "invalid signature")
+	rec.requests = append(rec.requests, r)
+	if len(rec.statuses) > 0 {
+		status := rec.statuses[0]
+		rec.statuses = rec.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+	var event storage.Event
+	require.NoError(rec.t, json.Unmarshal(body, &event))
+	rec.events = append(rec.events, &event)
+}
+
+func (rec *receiver) received() []*storage.Event {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]*storage.Event{}, rec.events...)
+}
+
+// startDispatcher wires a dispatcher with fast retries to a new todo list, after applying
+// the options.
+func startDispatcher(t *testing.T, options ...func(*webhook.Dispatcher)) (*storage.TodoList, *webhook.Registry, *webhook.Dispatcher) {
+	bus := storage.NewEventBus(10)
+	todoList := storage.NewTodoListWithOptions(storage.Options{Events: bus})
+	todoList.DisableLogging()
+
+	registry := webhook.NewRegistry()
+	registry.AllowInternal = true // The receivers listen on loopback
+	dispatcher := webhook.NewDispatcher(registry)
+	dispatcher.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	dispatcher.MaxAttempts = 3
+	dispatcher.BaseBackoff = time.Millisecond
+	for _, option := range options {
+		option(dispatcher)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := dispatcher.Start(ctx, bus)
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return todoList, registry, dispatcher
+}
+
+func TestWebhookRegistry(t *testing.T) {
+	registry := webhook.NewRegistry()
+
+	_, err := registry.Create(webhook.Subscription{URL: "ftp://example.com", Events: []storage.EventType{"renamed"}})
+	require.ErrorIs(t, err, storage.ErrInvalidInput)
+	assert.Len(t, storage.Classify(err).Fields, 2)
+
+	for _, local := range []string{"http://127.0.0.1:8080/hook", "http://[::1]/hook", "https://LOCALHOST./hook",
+		"http://api.localhost/hook", "http://169.254.169.254/latest/meta-data", "http://0.0.0.0/hook", "http:///hook",
+		"http://10.0.0.8/hook", "http://172.16.5.4/hook", "https://192.168.1.1/hook", "http://[fd00::1]/hook", "http://[::ffff:10.1.2.3]/hook"} {
+		_, err = registry.Create(webhook.Subscription{URL: local})
+		assert.ErrorIs(t, err, storage.ErrInvalidInput, local)
+	}
+
+	created, err := registry.Create(webhook.Subscription{URL: "https://example.com/hook"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.Len(t, created.Secret, 64, "a secret is generated")
+
+	listed := registry.List()
+	require.Len(t, listed, 1)
+	assert.Equal(t, created.ID, listed[0].ID)
+	assert.Empty(t, listed[0].Secret, "secrets are only shown on creation")
+
+	require.NoError(t, registry.Delete(created.ID))
+	assert.ErrorIs(t, registry.Delete(created.ID), storage.ErrWebhookNotFound)
+	assert.Empty(t, registry.List())
+}
+
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Unix(1700000000, 0)
+	signature := webhook.Sign("secret", now.Unix(), body)
+
+	assert.True(t, webhook.Verify("secret", signature, "1700000000", body, now, time.Minute))
+	assert.False(t, webhook.Verify("other", signature, "1700000000", body, now, time.Minute))
+	assert.False(t, webhook.Verify("secret", signature, "1700000000", []byte(`{"id":2}`), now, time.Minute))
+	assert.False(t, webhook.Verify("secret", signature, "1700000000", body, now.Add(time.Hour), time.Minute), "stale deliveries are rejected")
+}
+
+func TestWebhookDeliversSignedEvents(t *testing.T) {
+	todoList, registry, _ := startDispatcher(t)
+	all, allServer := newReceiver(t)
+	deletes, deletesServer := newReceiver(t)
+
+	sub, err := registry.Create(webhook.Subscription{URL: allServer.URL})
+	require.NoError(t, err)
+	all.secret = sub.Secret
+	sub, err = registry.Create(webhook.Subscription{URL: deletesServer.URL, Events: []storage.EventType{storage.EventDeleted}, Secret: "s3cret"})
+	require.NoError(t, err)
+	deletes.secret = "s3cret"
+
+	ctx := context.Background()
+	todo, err := todoList.AddTodo(ctx, "Hooked")
+	require.NoError(t, err)
+	require.NoError(t, todoList.DeleteTodoByID(ctx, todo.ID))
+
+	require.Eventually(t, func() bool { return len(all.received()) == 2 && len(deletes.received()) == 1 }, time.Second, 5*time.Millisecond)
+	types := []storage.EventType{}
+	for _, event := range all.received() {
+		types = append(types, event.Type)
+	}
+	assert.ElementsMatch(t, []storage.EventType{storage.EventCreated, storage.EventDeleted}, types)
+	assert.Equal(t, todo.ID, deletes.received()[0].TodoID)
+	assert.Equal(t, string(storage.EventDeleted), deletes.requests[0].Header.Get(webhook.EventHeader))
+}
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	todoList, registry, dispatcher := startDispatcher(t)
+	rec, server := newReceiver(t, http.StatusInternalServerError, http.StatusTooManyRequests)
+	sub, err := registry.Create(webhook.Subscription{URL: server.URL})
+	require.NoError(t, err)
+	rec.secret = sub.Secret
+
+	_, err = todoList.AddTodo(context.Background(), "Flaky")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(rec.received()) == 1 }, time.Second, 5*time.Millisecond)
+	deliveries := dispatcher.Deliveries(sub.ID)
+	require.Len(t, deliveries, 3)
+	for i, delivery := range deliveries {
+		assert.Equal(t, i+1, delivery.Attempt)
+		assert.Equal(t, deliveries[0].ID, delivery.ID, "attempts share the delivery ID")
+	}
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].StatusCode)
+	assert.True(t, deliveries[2].Succeeded())
+	assert.Equal(t, deliveries[0].ID, rec.requests[2].Header.Get(webhook.DeliveryHeader))
+	assert.Empty(t, dispatcher.DeadLetters())
+}
+
+func TestWebhookDeadLetters(t *testing.T) {
+	todoList, registry, dispatcher := startDispatcher(t)
+	// Retries run out
+	down, downServer := newReceiver(t, 503, 503, 503)
+	sub, err := registry.Create(webhook.Subscription{URL: downServer.URL, Events: []storage.EventType{storage.EventCreated}})
+	require.NoError(t, err)
+	down.secret = sub.Secret
+	// Client errors are not retried
+	rejecting, rejectingServer := newReceiver(t, http.StatusGone)
+	rejected, err := registry.Create(webhook.Subscription{URL: rejectingServer.URL, Events: []storage.EventType{storage.EventCreated}})
+	require.NoError(t, err)
+	rejecting.secret = rejected.Secret
+
+	_, err = todoList.AddTodo(context.Background(), "Undeliverable")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(dispatcher.DeadLetters()) == 2 }, time.Second, 5*time.Millisecond)
+	attempts := map[string]int{}
+	for _, letter := range dispatcher.DeadLetters() {
+		attempts[letter.SubscriptionID] = letter.Attempts
+		assert.Equal(t, storage.EventCreated, letter.Event.Type)
+		assert.NotEmpty(t, letter.LastError)
+	}
+	assert.Equal(t, map[string]int{sub.ID: 3, rejected.ID: 1}, attempts)
+	assert.Len(t, dispatcher.Deliveries(sub.ID), 3)
+	assert.Len(t, dispatcher.Deliveries(rejected.ID), 1)
+}
+
+func TestWebhookRefusesInternalAddresses(t *testing.T) {
+	bus := storage.NewEventBus(10)
+	todoList := storage.NewTodoListWithOptions(storage.Options{Events: bus})
+	todoList.DisableLogging()
+	_, server := newReceiver(t)
+
+	// A subscription whose host resolves to loopback is refused when connecting
+	registry := webhook.NewRegistry()
+	registry.AllowInternal = true
+	sub, err := registry.Create(webhook.Subscription{URL: server.URL})
+	require.NoError(t, err)
+	registry.AllowInternal = false
+
+	dispatcher := webhook.NewDispatcher(registry)
+	dispatcher.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	dispatcher.MaxAttempts = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	done := dispatcher.Start(ctx, bus)
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	_, err = todoList.AddTodo(context.Background(), "Internal")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(dispatcher.DeadLetters()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Contains(t, dispatcher.Deliveries(sub.ID)[0].Error, "refusing to deliver to internal address")
+}
+
+func TestWebhookRetriesDontHoldUpOthers(t *testing.T) {
+	todoList, registry, dispatcher := startDispatcher(t, func(d *webhook.Dispatcher) {
+		d.Workers = 1
+		d.BaseBackoff = time.Hour
+	})
+	failing, failingServer := newReceiver(t, 503)
+	sub, err := registry.Create(webhook.Subscription{URL: failingServer.URL})
+	require.NoError(t, err)
+	failing.secret = sub.Secret
+	healthy, healthyServer := newReceiver(t)
+	other, err := registry.Create(webhook.Subscription{URL: healthyServer.URL})
+	require.NoError(t, err)
+	healthy.secret = other.Secret
+
+	// The only worker moves on while the failed delivery waits for its retry
+	_, err = todoList.AddTodo(context.Background(), "Meanwhile")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(healthy.received()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Len(t, dispatcher.Deliveries(sub.ID), 1)
+	assert.Empty(t, dispatcher.DeadLetters())
+}
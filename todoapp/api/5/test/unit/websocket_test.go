@@ -0,0 +1,292 @@
+package unit_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"todoapp/5/websocket"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEchoServer starts a WebSocket server that sends every message back.
+func newEchoServer(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), err.(*websocket.HandshakeError).Status)
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, data)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestWebSocketRoundTrip(t *testing.T) {
+	conn, response, err := websocket.Dial(context.Background(), newEchoServer(t), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, response.StatusCode)
+
+	large := bytes.Repeat([]byte("x"), 70000) // needs the 64-bit length encoding
+	for _, message := range [][]byte{[]byte("hello"), bytes.Repeat([]byte("y"), 300), large} {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, message))
+		messageType, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, websocket.TextMessage, messageType)
+		assert.Equal(t, message, data)
+	}
+
+	require.NoError(t, conn.WriteClose(websocket.CloseNormal, "done"))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.True(t, errors.As(err, &closeErr))
+	assert.Equal(t, websocket.CloseNormal, closeErr.Code)
+	conn.Close()
+}
+
+func TestWebSocketHandshakeRejected(t *testing.T) {
+	url := newEchoServer(t)
+	response, err := http.Get("http" + strings.TrimPrefix(url, "ws"))
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusUpgradeRequired, response.StatusCode)
+}
+
+// rawConn performs the opening handshake by hand so the test can write frames directly.
+func rawConn(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "ws://"))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	request := "GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	_, err = conn.Write([]byte(request))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, response.StatusCode)
+	// The example key and accept value of RFC 6455, section 1.3
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", response.Header.Get("Sec-WebSocket-Accept"))
+	return conn, reader
+}
+
+// writeRawFrame writes a masked client frame.
+func writeRawFrame(t *testing.T, conn net.Conn, fin bool, opcode byte, payload []byte) {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{first, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := conn.Write(frame)
+	require.NoError(t, err)
+}
+
+// readRawFrame reads a short unmasked server frame.
+func readRawFrame(t *testing.T, reader *bufio.Reader) (byte, []byte) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(reader, header)
+	require.NoError(t, err)
+	require.Zero(t, header[1]&0x80, "server frames are not masked")
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		ext := make([]byte, 2)
+		_, err = io.ReadFull(reader, ext)
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(ext))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	require.NoError(t, err)
+	return header[0] & 0x0f, payload
+}
+
+func TestWebSocketFragmentsAndControlFrames(t *testing.T) {
+	conn, reader := rawConn(t, newEchoServer(t))
+
+	// A ping between fragments is answered right away
+	writeRawFrame(t, conn, false, 0x1, []byte("frag"))
+	writeRawFrame(t, conn, true, 0x9, []byte("ping"))
+	writeRawFrame(t, conn, true, 0x0, []byte("mented"))
+
+	opcode, payload := readRawFrame(t, reader)
+	assert.Equal(t, byte(0xA), opcode)
+	assert.Equal(t, "ping", string(payload))
+
+	opcode, payload = readRawFrame(t, reader)
+	assert.Equal(t, byte(0x1), opcode)
+	assert.Equal(t, "fragmented", string(payload))
+
+	// Invalid UTF-8 in a text message closes the connection with 1007
+	writeRawFrame(t, conn, true, 0x1, []byte{0xff, 0xfe})
+	opcode, payload = readRawFrame(t, reader)
+	assert.Equal(t, byte(0x8), opcode)
+	assert.Equal(t, uint16(websocket.CloseInvalidPayload), binary.BigEndian.Uint16(payload))
+}
+
+func TestWebSocketRejectsUnmaskedClientFrames(t *testing.T) {
+	conn, reader := rawConn(t, newEchoServer(t))
+
+	_, err := conn.Write([]byte{0x81, 0x02, 'h', 'i'})
+	require.NoError(t, err)
+	opcode, payload := readRawFrame(t, reader)
+	assert.Equal(t, byte(0x8), opcode)
+	assert.Equal(t, uint16(websocket.CloseProtocolError), binary.BigEndian.Uint16(payload))
+}
+
+func TestWebSocketOriginCheck(t *testing.T) {
+	upgrader := &websocket.Upgrader{AllowedOrigins: []string{"https://app.example.com"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), err.(*websocket.HandshakeError).Status)
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	for origin, status := range map[string]int{
+		"":                         http.StatusSwitchingProtocols, // not a browser
+		server.URL:                 http.StatusSwitchingProtocols, // same origin
+		"https://app.example.com":  http.StatusSwitchingProtocols,
+		"https://evil.example.com": http.StatusForbidden,
+		"null":                     http.StatusForbidden,
+	} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, response, err := websocket.Dial(context.Background(), url, header)
+		if conn != nil {
+			conn.Close()
+		}
+		require.NotNil(t, response, origin)
+		assert.Equal(t, status, response.StatusCode, origin)
+		assert.Equal(t, status != http.StatusSwitchingProtocols, err != nil, origin)
+	}
+}
+
+func TestWebSocketReadTimeout(t *testing.T) {
+	closed := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.ReadTimeout = 50 * time.Millisecond
+		_, _, err = conn.ReadMessage()
+		closed <- err
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// A silent peer is given up on
+	select {
+	case err := <-closed:
+		var netErr net.Error
+		require.True(t, errors.As(err, &netErr))
+		assert.True(t, netErr.Timeout())
+	case <-time.After(2 * time.Second):
+		t.Fatal("the read did not time out")
+	}
+}
+
+func TestWebSocketWriteTimeout(t *testing.T) {
+	written := make(chan error, 1)
+	closed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		require.NoError(t, err)
+		conn.WriteTimeout = time.Minute
+		go func() {
+			large := bytes.Repeat([]byte("x"), 1<<20)
+			for {
+				if err := conn.WriteMessage(websocket.BinaryMessage, large); err != nil {
+					written <- err
+					return
+				}
+			}
+		}()
+		// Close doesn't wait for the write stuck on a peer that stopped reading
+		time.Sleep(100 * time.Millisecond)
+		conn.Close()
+		close(closed)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close waited for the stuck write")
+	}
+	select {
+	case err := <-written:
+		assert.Error(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("the stuck write did not fail")
+	}
+}
+
+func TestWebSocketWriteDeadline(t *testing.T) {
+	written := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.WriteTimeout = 50 * time.Millisecond
+		large := bytes.Repeat([]byte("x"), 1<<20)
+		for {
+			if err := conn.WriteMessage(websocket.BinaryMessage, large); err != nil {
+				written <- err
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// A peer that doesn't read makes writes time out instead of blocking forever
+	select {
+	case err := <-written:
+		var netErr net.Error
+		require.True(t, errors.As(err, &netErr))
+		assert.True(t, netErr.Timeout())
+	case <-time.After(5 * time.Second):
+		t.Fatal("the write did not time out")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"todoapp/5/storage"
+)
+
+// pathTodoID parses the {id} wildcard of the matched route pattern.
+func pathTodoID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, storage.NewInvalidInputError("Invalid todo ID")
+	}
+	return id, nil
+}
+
+func listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("trash"))
+	defer cancel()
+
+	todos, err := todoList.ListTrash(ctx)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todos)
+}
+
+func restoreTodoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTodoID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("restore"))
+	defer cancel()
+
+	todo, err := todoList.RestoreTodoByID(ctx, id)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todo)
+}
+
+func purgeTodoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathTodoID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeouts.For("purge"))
+	defer cancel()
+
+	if err := todoList.PurgeTodoByID(ctx, id); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,388 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"todoapp/5/storage"
+)
+
+// Dispatcher defaults
+const (
+	DefaultMaxAttempts = 6
+	DefaultBaseBackoff = time.Second
+	DefaultMaxBackoff  = 5 * time.Minute
+	DefaultWorkers     = 4
+	DefaultLogSize     = 1000 // Delivery attempts and dead letters kept in memory
+	queueSize          = 1000
+)
+
+// Delivery is one attempt at posting an event to a subscription.
+type Delivery struct {
+	ID             string        `json:"id"` // Shared by all attempts of the same event to the same subscription
+	SubscriptionID string        `json:"subscription_id"`
+	EventID        int64         `json:"event_id"`
+	Attempt        int           `json:"attempt"`
+	StatusCode     int           `json:"status_code,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	At             time.Time     `json:"at"`
+	Duration       time.Duration `json:"duration"`
+}
+
+// Succeeded reports whether the receiver acknowledged the delivery with a 2xx status.
+func (d *Delivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// DeadLetter is an event that could not be delivered to a subscription.
+type DeadLetter struct {
+	DeliveryID     string         `json:"delivery_id"`
+	SubscriptionID string         `json:"subscription_id"`
+	Event          *storage.Event `json:"event"`
+	Attempts       int            `json:"attempts"`
+	LastError      string         `json:"last_error"`
+	At             time.Time      `json:"at"`
+}
+
+// job is an event waiting to be delivered to one subscription.
+type job struct {
+	deliveryID     string
+	subscriptionID string
+	event          *storage.Event
+	body           []byte // The encoded event, once the first attempt was made
+	attempts       int
+	lastError      string
+}
+
+// Dispatcher posts the events of an EventBus to the subscriptions in its Registry.
+// Each event is delivered to every subscription that wants it. Network errors, 5xx and
+// 429 responses are retried with exponential backoff up to MaxAttempts; other responses
+// and exhausted retries move the event to the dead letters. Workers only make attempts,
+// retries wait on timers so a failing subscriber doesn't hold up the others.
+type Dispatcher struct {
+	Registry    *Registry
+	Client      *http.Client
+	Clock       storage.Clock // Time source for signatures and the logs, the system clock when nil
+	Logger      *slog.Logger
+	MaxAttempts int
+	BaseBackoff time.Duration // Wait before the second attempt, doubled for every further one
+	MaxBackoff  time.Duration
+	Workers     int
+	LogSize     int
+
+	mu          sync.Mutex
+	deliveries  []*Delivery // most recent attempts, oldest first
+	deadLetters []*DeadLetter
+	jobs        chan *job
+	retries     map[*job]*time.Timer // jobs waiting for their next attempt
+	stopped     bool                 // jobs is closed, nothing may be queued anymore
+}
+
+// NewDispatcher creates a dispatcher for the registry's subscriptions with the default settings.
+// Its client refuses to connect to internal addresses unless the registry allows them, so
+// a subscription host that resolves to one is rejected too. It ignores proxies, which would
+// connect on its behalf past that check.
+func NewDispatcher(registry *Registry) *Dispatcher {
+	d := &Dispatcher{
+		Registry:    registry,
+		Logger:      slog.Default(),
+		MaxAttempts: DefaultMaxAttempts,
+		BaseBackoff: DefaultBaseBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		Workers:     DefaultWorkers,
+		LogSize:     DefaultLogSize,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, Control: d.checkAddress}).DialContext
+	d.Client = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	return d
+}
+
+// checkAddress refuses connections to internal addresses, once the host has been resolved.
+func (d *Dispatcher) checkAddress(network, address string, _ syscall.RawConn) error {
+	if d.Registry != nil && d.Registry.AllowInternal {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip != nil && internalIP(ip) {
+		return fmt.Errorf("refusing to deliver to internal address %s", host)
+	}
+	return nil
+}
+
+// Start delivers the events published on bus in background goroutines until ctx is
+// cancelled. The returned channel is closed once every worker has stopped; deliveries
+// still waiting for a retry at that point are dead-lettered.
+func (d *Dispatcher) Start(ctx context.Context, bus *storage.EventBus) <-chan struct{} {
+	d.jobs = make(chan *job, queueSize)
+	d.retries = map[*job]*time.Timer{}
+	done := make(chan struct{})
+
+	var workers sync.WaitGroup
+	for i := 0; i < max(d.Workers, 1); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range d.jobs {
+				d.deliver(ctx, job)
+			}
+		}()
+	}
+
+	// Subscribe before returning so no event published after Start is missed
+	sub := bus.Subscribe()
+	go func() {
+		defer close(done)
+		defer workers.Wait()
+		defer d.stop()
+		defer func() { sub.Close() }()
+
+		var lastID int64
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-sub.Events():
+				if !ok {
+					// Dropped by the bus for falling behind, pick up where we stopped
+					var replay []*storage.Event
+					var complete bool
+					sub, replay, complete = bus.Resume(lastID)
+					if !complete {
+						d.Logger.Warn("Webhook dispatcher missed events", "after", lastID)
+					}
+					for _, event := range replay {
+						lastID = event.ID
+						d.enqueue(event)
+					}
+					continue
+				}
+				lastID = event.ID
+				d.enqueue(event)
+			}
+		}
+	}()
+	return done
+}
+
+// enqueue queues the event for every subscription that wants it.
+func (d *Dispatcher) enqueue(event *storage.Event) {
+	for _, subscription := range d.Registry.matching(event.Type) {
+		d.queue(&job{deliveryID: randomHex(8), subscriptionID: subscription.ID, event: event})
+	}
+}
+
+// queue hands a job to the workers. The queue never blocks the event feed or the retry
+// timers: when it is full, or the dispatcher has stopped, the job is dead-lettered.
+func (d *Dispatcher) queue(j *job) {
+	d.mu.Lock()
+	reason := j.lastError
+	if !d.stopped {
+		select {
+		case d.jobs <- j:
+			d.mu.Unlock()
+			return
+		default:
+			reason = "delivery queue full"
+		}
+	}
+	d.mu.Unlock()
+	d.deadLetter(j, j.attempts, reason)
+}
+
+// retry queues the job again once the backoff for its failed attempts has passed.
+func (d *Dispatcher) retry(j *job) {
+	d.mu.Lock()
+	if !d.stopped {
+		d.retries[j] = time.AfterFunc(d.backoff(j.attempts), func() {
+			d.mu.Lock()
+			_, waiting := d.retries[j]
+			delete(d.retries, j)
+			d.mu.Unlock()
+			if waiting {
+				d.queue(j)
+			}
+		})
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+	d.deadLetter(j, j.attempts, j.lastError)
+}
+
+// stop closes the queue and dead-letters the jobs still waiting for a retry.
+func (d *Dispatcher) stop() {
+	d.mu.Lock()
+	d.stopped = true
+	close(d.jobs)
+	var abandoned []*job
+	for j, timer := range d.retries {
+		timer.Stop()
+		abandoned = append(abandoned, j)
+	}
+	d.retries = map[*job]*time.Timer{}
+	d.mu.Unlock()
+
+	for _, j := range abandoned {
+		d.deadLetter(j, j.attempts, j.lastError)
+	}
+}
+
+// deliver makes the next attempt at a job, and schedules a retry when it fails in a way
+// that may pass and attempts are left.
+func (d *Dispatcher) deliver(ctx context.Context, j *job) {
+	if j.body == nil {
+		body, err := json.Marshal(j.event)
+		if err != nil {
+			d.deadLetter(j, 0, err.Error())
+			return
+		}
+		j.body = body
+	}
+	// Deliveries to a deleted subscription are abandoned
+	subscription, ok := d.Registry.get(j.subscriptionID)
+	if !ok {
+		return
+	}
+
+	j.attempts++
+	delivery := d.post(ctx, subscription, j, j.attempts, j.body)
+	d.logDelivery(delivery)
+	switch {
+	case delivery.Succeeded():
+	case !retryable(delivery) || j.attempts >= max(d.MaxAttempts, 1):
+		d.deadLetter(j, j.attempts, delivery.Error)
+	default:
+		j.lastError = delivery.Error
+		d.retry(j)
+	}
+}
+
+// post makes one signed delivery attempt.
+func (d *Dispatcher) post(ctx context.Context, subscription *Subscription, j *job, attempt int, body []byte) *Delivery {
+	start := nowFrom(d.Clock)
+	delivery := &Delivery{
+		ID:             j.deliveryID,
+		SubscriptionID: subscription.ID,
+		EventID:        j.event.ID,
+		Attempt:        attempt,
+		At:             start,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	timestamp := start.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todoapp-webhooks/1")
+	req.Header.Set(EventHeader, string(j.event.Type))
+	req.Header.Set(DeliveryHeader, j.deliveryID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	delivery.Duration = nowFrom(d.Clock).Sub(start)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	delivery.StatusCode = resp.StatusCode
+	if !delivery.Succeeded() {
+		delivery.Error = fmt.Sprintf("receiver responded %s", resp.Status)
+	}
+	return delivery
+}
+
+// retryable reports whether a failed attempt may succeed when repeated.
+func retryable(delivery *Delivery) bool {
+	return delivery.StatusCode == 0 ||
+		delivery.StatusCode == http.StatusTooManyRequests ||
+		delivery.StatusCode >= 500
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(failed int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < failed && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if d.MaxBackoff > 0 && wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+	return wait
+}
+
+func (d *Dispatcher) logDelivery(delivery *Delivery) {
+	if !delivery.Succeeded() {
+		d.Logger.Warn("Webhook delivery failed", "subscription", delivery.SubscriptionID,
+			"event", delivery.EventID, "attempt", delivery.Attempt, "error", delivery.Error)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deliveries = appendBounded(d.deliveries, delivery, d.LogSize)
+}
+
+func (d *Dispatcher) deadLetter(j *job, attempts int, lastError string) {
+	d.Logger.Error("Webhook delivery dead-lettered", "subscription", j.subscriptionID,
+		"event", j.event.ID, "attempts", attempts, "error", lastError)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadLetters = appendBounded(d.deadLetters, &DeadLetter{
+		DeliveryID:     j.deliveryID,
+		SubscriptionID: j.subscriptionID,
+		Event:          j.event,
+		Attempts:       attempts,
+		LastError:      lastError,
+		At:             nowFrom(d.Clock),
+	}, d.LogSize)
+}
+
+// Deliveries returns the logged attempts for a subscription, oldest first.
+func (d *Dispatcher) Deliveries(subscriptionID string) []*Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	deliveries := []*Delivery{}
+	for _, delivery := range d.deliveries {
+		if delivery.SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries
+}
+
+// DeadLetters returns the events that could not be delivered, oldest first.
+func (d *Dispatcher) DeadLetters() []*DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*DeadLetter{}, d.deadLetters...)
+}
+
+// appendBounded appends to a log, dropping its oldest entries beyond size.
+func appendBounded[T any](log []T, entry T, size int) []T {
+	if size <= 0 {
+		size = DefaultLogSize
+	}
+	log = append(log, entry)
+	if len(log) > size {
+		log = log[len(log)-size:]
+	}
+	return log
+}
@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	SignatureHeader = "X-Todo-Signature" // sha256=<hex HMAC of "<timestamp>.<body>">
+	TimestampHeader = "X-Todo-Timestamp" // Unix seconds at which the attempt was signed
+	EventHeader     = "X-Todo-Event"
+	DeliveryHeader  = "X-Todo-Delivery" // Same for every attempt of one delivery
+)
+
+// Sign returns the SignatureHeader value for a body sent at timestamp. The timestamp is
+// part of the signed content so receivers can reject replayed requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a received delivery and that it was signed within
+// tolerance of now. It is meant for receivers, including the tests.
+func Verify(secret, signature, timestamp string, body []byte, now time.Time, tolerance time.Duration) bool {
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(sent, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	expected := Sign(secret, sent, body)
+	return strings.HasPrefix(signature, "sha256=") && hmac.Equal([]byte(signature), []byte(expected))
+}
@@ -0,0 +1,202 @@
+// Package webhook pushes todo change events to subscribed HTTP endpoints.
+// Every delivery is signed with the subscription's secret, failed deliveries are
+// retried with exponential backoff and the ones that never succeed are dead-lettered.
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"todoapp/5/storage"
+)
+
+// Subscription asks for the events of the listed types to be posted to URL.
+// An empty Events list subscribes to every type.
+type Subscription struct {
+	ID        string              `json:"id"`
+	URL       string              `json:"url"`
+	Events    []storage.EventType `json:"events,omitempty"`
+	Secret    string              `json:"secret,omitempty"` // Only returned when the subscription is created
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// wants reports whether the subscription receives events of the type.
+func (s *Subscription) wants(eventType storage.EventType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, wanted := range s.Events {
+		if wanted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted returns a copy of the subscription without its secret.
+func (s *Subscription) redacted() *Subscription {
+	copied := *s
+	copied.Secret = ""
+	return &copied
+}
+
+var eventTypes = map[storage.EventType]bool{
+	storage.EventCreated:  true,
+	storage.EventUpdated:  true,
+	storage.EventDeleted:  true,
+	storage.EventRestored: true,
+	storage.EventPurged:   true,
+}
+
+// internalIP reports whether an address is on the server's own host or private network,
+// which subscriptions may not reach unless the registry allows it.
+func internalIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4 // ::ffff:10.0.0.1 is 10.0.0.1
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// Registry holds the webhook subscriptions in memory.
+type Registry struct {
+	Clock storage.Clock // Time source for CreatedAt, the system clock when nil
+	// AllowInternal accepts loopback, private, link-local and unspecified hosts, which are
+	// refused by default so subscriptions can't probe the server's own network.
+	AllowInternal bool
+
+	mu            sync.RWMutex
+	subscriptions map[string]*Subscription
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{subscriptions: map[string]*Subscription{}}
+}
+
+// Create validates and adds a subscription. A secret is generated when it has none;
+// the returned subscription is the only place the secret is exposed.
+func (r *Registry) Create(subscription Subscription) (*Subscription, error) {
+	var fields []storage.FieldError
+	if u, err := url.Parse(subscription.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		fields = append(fields, storage.FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	} else if !r.AllowInternal && internalHost(u.Hostname()) {
+		fields = append(fields, storage.FieldError{Field: "url", Message: "must not point to a loopback, private, link-local or unspecified address"})
+	}
+	for _, eventType := range subscription.Events {
+		if !eventTypes[eventType] {
+			fields = append(fields, storage.FieldError{Field: "events", Message: fmt.Sprintf("unknown event type %q", eventType)})
+		}
+	}
+	if len(fields) > 0 {
+		return nil, storage.NewValidationError(fields...)
+	}
+
+	if subscription.Secret == "" {
+		subscription.Secret = randomHex(32)
+	}
+	subscription.ID = "wh_" + randomHex(8)
+	subscription.CreatedAt = nowFrom(r.Clock)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscriptions[subscription.ID] = &subscription
+	copied := subscription
+	return &copied, nil
+}
+
+// internalHost reports whether a URL host is localhost or an internal IP address. Names
+// that resolve to one are refused when the dispatcher connects.
+func internalHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && internalIP(ip)
+}
+
+// List returns the subscriptions, oldest first, without their secrets.
+func (r *Registry) List() []*Subscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subscriptions := make([]*Subscription, 0, len(r.subscriptions))
+	for _, subscription := range r.subscriptions {
+		subscriptions = append(subscriptions, subscription.redacted())
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if !subscriptions[i].CreatedAt.Equal(subscriptions[j].CreatedAt) {
+			return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+		}
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+	return subscriptions
+}
+
+// Get returns a subscription without its secret.
+func (r *Registry) Get(id string) (*Subscription, error) {
+	subscription, ok := r.get(id)
+	if !ok {
+		return nil, NewNotFoundError(id)
+	}
+	return subscription.redacted(), nil
+}
+
+// Delete removes a subscription. Deliveries still being retried for it are abandoned.
+func (r *Registry) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.subscriptions[id]; !ok {
+		return NewNotFoundError(id)
+	}
+	delete(r.subscriptions, id)
+	return nil
+}
+
+// get returns the subscription with its secret, for signing deliveries.
+func (r *Registry) get(id string) (*Subscription, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subscription, ok := r.subscriptions[id]
+	return subscription, ok
+}
+
+// matching returns the subscriptions that want events of the type.
+func (r *Registry) matching(eventType storage.EventType) []*Subscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var matches []*Subscription
+	for _, subscription := range r.subscriptions {
+		if subscription.wants(eventType) {
+			matches = append(matches, subscription)
+		}
+	}
+	return matches
+}
+
+// NewNotFoundError reports an unknown subscription ID.
+func NewNotFoundError(id string) *storage.TodoError {
+	return &storage.TodoError{
+		Code:    storage.ErrWebhookNotFound,
+		Message: fmt.Sprintf("Webhook %s not found", id),
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func nowFrom(clock storage.Clock) time.Time {
+	if clock == nil {
+		clock = storage.SystemClock
+	}
+	return clock.Now().UTC()
+}
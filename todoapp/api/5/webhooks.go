@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"todoapp/5/storage"
+	"todoapp/5/webhook"
+)
+
+// webhooks holds the webhook subscriptions the dispatcher delivers change events to
+var webhooks *webhook.Registry
+
+var dispatcher *webhook.Dispatcher
+
+func createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var request webhook.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeProblem(w, r, storage.NewInvalidInputError("Invalid request payload"))
+		return
+	}
+
+	subscription, err := webhooks.Create(request)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/webhooks/"+subscription.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+func getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, err := webhooks.Get(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscription)
+}
+
+func listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks.List())
+}
+
+func deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if err := webhooks.Delete(r.PathValue("id")); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func webhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	subscription, err := webhooks.Get(r.PathValue("id"))
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dispatcher.Deliveries(subscription.ID))
+}
+
+func deadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dispatcher.DeadLetters())
+}
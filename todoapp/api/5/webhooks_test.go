@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"todoapp/5/storage"
+	"todoapp/5/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhooks(t *testing.T) {
+	server := newTestServer(t)
+	webhooks = webhook.NewRegistry()
+
+	resp := do(t, http.MethodPost, server.URL+"/webhooks", `{"url": "https://example.com/hook"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created webhook.Subscription
+	decode(t, resp, &created)
+	assert.NotEmpty(t, created.Secret)
+
+	// The Location of a new subscription can be fetched, without its secret
+	resp = do(t, http.MethodGet, server.URL+resp.Header.Get("Location"), "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var fetched webhook.Subscription
+	decode(t, resp, &fetched)
+	assert.Equal(t, created.ID, fetched.ID)
+	assert.Empty(t, fetched.Secret)
+
+	resp = do(t, http.MethodGet, server.URL+"/webhooks/wh_missing", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = do(t, http.MethodPost, server.URL+"/webhooks", `{"url": "http://169.254.169.254/latest/meta-data"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	var problem ProblemDetails
+	decode(t, resp, &problem)
+	assert.Equal(t, storage.ErrInvalidInput, problem.Code)
+	require.NotEmpty(t, problem.Errors)
+	assert.Equal(t, "url", problem.Errors[0].Field)
+}
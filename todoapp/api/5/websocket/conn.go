@@ -0,0 +1,340 @@
+// Package websocket implements the subset of RFC 6455 the todo server needs:
+// the opening handshake for servers and clients, message framing with fragmentation,
+// masking, ping/pong and the closing handshake. Extensions and subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the opcode of a data or control frame
+type MessageType int
+
+// Opcodes defined by RFC 6455, section 5.2
+const (
+	continuationFrame MessageType = 0
+	TextMessage       MessageType = 1
+	BinaryMessage     MessageType = 2
+	CloseMessage      MessageType = 8
+	PingMessage       MessageType = 9
+	PongMessage       MessageType = 10
+)
+
+// Close codes from RFC 6455, section 7.4.1
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+	CloseTryAgainLater   = 1013
+)
+
+// DefaultMaxMessageSize is the default limit on the size of a received message
+const DefaultMaxMessageSize = 1 << 20
+
+// DefaultWriteTimeout is how long a write may wait for a peer that doesn't read
+const DefaultWriteTimeout = 10 * time.Second
+
+// maxControlPayload is the largest payload of a control frame
+const maxControlPayload = 125
+
+// CloseError is returned by ReadMessage once the connection has been closed
+// with a close frame, by either side.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: closed with code %d: %s", e.Code, e.Reason)
+}
+
+// Conn is a WebSocket connection. ReadMessage must only be called from one goroutine
+// at a time; the write methods are safe for concurrent use.
+type Conn struct {
+	MaxMessageSize int64 // Larger messages close the connection with CloseMessageTooBig
+
+	// ReadTimeout, when set, fails reads once nothing, not even a pong, arrived for that
+	// long. Pinging the peer more often keeps a live connection open.
+	ReadTimeout time.Duration
+	// WriteTimeout fails a write the peer hasn't taken in for that long, DefaultWriteTimeout
+	// when zero.
+	WriteTimeout time.Duration
+
+	conn     net.Conn
+	reader   *bufio.Reader
+	isServer bool
+
+	writeMu   sync.Mutex
+	closeSent bool
+}
+
+func newConn(conn net.Conn, reader *bufio.Reader, isServer bool) *Conn {
+	if reader == nil {
+		reader = bufio.NewReader(conn)
+	}
+	return &Conn{MaxMessageSize: DefaultMaxMessageSize, conn: conn, reader: reader, isServer: isServer}
+}
+
+// frame is one decoded frame
+type frame struct {
+	fin     bool
+	opcode  MessageType
+	payload []byte
+}
+
+// readFrame reads and unmasks one frame, enforcing the framing rules of section 5.
+func (c *Conn) readFrame(limit int64) (*frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return nil, err
+	}
+	f := &frame{fin: header[0]&0x80 != 0, opcode: MessageType(header[0] & 0x0f)}
+	if header[0]&0x70 != 0 {
+		return nil, c.fail(CloseProtocolError, "reserved bits set without a negotiated extension")
+	}
+	masked := header[1]&0x80 != 0
+	if masked != c.isServer {
+		return nil, c.fail(CloseProtocolError, "client frames must be masked and server frames must not")
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			return nil, c.fail(CloseProtocolError, "invalid payload length")
+		}
+	}
+
+	if f.opcode >= CloseMessage {
+		if !f.fin || length > maxControlPayload {
+			return nil, c.fail(CloseProtocolError, "control frames must be final and at most 125 bytes")
+		}
+	} else if length > limit {
+		return nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return nil, err
+		}
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, f.payload); err != nil {
+		return nil, err
+	}
+	if masked {
+		maskBytes(mask, f.payload)
+	}
+	return f, nil
+}
+
+// ReadMessage returns the next text or binary message, reassembling fragmented ones.
+// Pings are answered and pongs ignored while waiting. A close frame is answered and
+// reported as a *CloseError.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var messageType MessageType
+	var message []byte
+	for {
+		if c.ReadTimeout > 0 {
+			if err := c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout)); err != nil {
+				return 0, nil, err
+			}
+		}
+		f, err := c.readFrame(c.MaxMessageSize - int64(len(message)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch f.opcode {
+		case PingMessage:
+			if err := c.writeFrame(PongMessage, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(f.payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "new message started before the previous one finished")
+			}
+			messageType = f.opcode
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "continuation frame without a message")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", f.opcode))
+		}
+
+		message = append(message, f.payload...)
+		if f.fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidPayload, "text message is not valid UTF-8")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// handleClose answers a close frame from the peer and returns it as a CloseError.
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+	if len(payload) == 1 {
+		return c.fail(CloseProtocolError, "invalid close payload")
+	}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(CloseInvalidPayload, "close reason is not valid UTF-8")
+		}
+	}
+	code := closeErr.Code
+	if code == CloseNoStatus {
+		code = CloseNormal
+	}
+	c.WriteClose(code, "")
+	return closeErr
+}
+
+// fail closes the connection after a protocol violation by the peer.
+func (c *Conn) fail(code int, reason string) error {
+	c.WriteClose(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// WriteMessage sends a text or binary message in a single frame.
+func (c *Conn) WriteMessage(messageType MessageType, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return errors.New("websocket: WriteMessage only sends text or binary messages")
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// Ping sends a ping, the peer answers with a pong carrying the same data.
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: ping payload too long")
+	}
+	return c.writeFrame(PingMessage, data)
+}
+
+// WriteClose starts the closing handshake. Only the first close frame is sent;
+// the connection stays open until Close so the peer's answer can still be read.
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+	return c.writeFrame(CloseMessage, payload)
+}
+
+// Close sends a normal close frame, unless one was already sent or another write is in
+// progress, and closes the connection. A write stuck on a peer that stopped reading
+// fails once the connection is closed.
+func (c *Conn) Close() error {
+	if c.writeMu.TryLock() {
+		if !c.closeSent {
+			c.closeSent = true
+			c.writeLocked(CloseMessage, []byte{CloseNormal >> 8, CloseNormal & 0xff})
+		}
+		c.writeMu.Unlock()
+	}
+	return c.conn.Close()
+}
+
+// writeFrame writes one final frame, masking it when this is the client side.
+func (c *Conn) writeFrame(opcode MessageType, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return net.ErrClosed
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+	return c.writeLocked(opcode, payload)
+}
+
+// writeLocked writes a frame within the write timeout, writeMu must be held.
+func (c *Conn) writeLocked(opcode MessageType, payload []byte) error {
+	timeout := c.WriteTimeout
+	if timeout <= 0 {
+		timeout = DefaultWriteTimeout
+	}
+	if err := c.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | byte(opcode)
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	if !c.isServer {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		header[1] |= 0x80
+		header = append(header, mask[:]...)
+		masked := make([]byte, len(payload))
+		copy(masked, payload)
+		maskBytes(mask, masked)
+		payload = masked
+	}
+
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// maskBytes applies the masking algorithm of section 5.3, which is its own inverse.
+func maskBytes(mask [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}